@@ -0,0 +1,192 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+// Command interpose runs an interposer between warp9 clients and an
+// upstream object server.
+//
+//	interpose -mode cache -up tcp!device!9090 -addr :9090 -ttl 5s
+//	interpose -mode balance -up tcp!r1!9090,tcp!r2!9090 -policy least
+//	interpose -mode failover -up tcp!p!9090,tcp!s1!9090,tcp!s2!9090 -quorum 2
+//	interpose -mode filter -up tcp!internal!9090 -rules /etc/warp/public.policy
+//	interpose -mode transform -up tcp!device!9090 -xform '/*.cbor=cbor2json,/users/*=redact:password'
+//	interpose -mode audit -up tcp!device!9090 -log /var/log/warp/audit.log
+//	interpose -mode faults -up tcp!device!9090 -faults flaky.rules -seed 42
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/lavaorg/warp/interpose"
+	"github.com/lavaorg/warp/warp9"
+	"github.com/lavaorg/warp/wkit"
+)
+
+func main() {
+	mode := flag.String("mode", "cache", "interposer to run: cache, balance, failover, filter, transform, audit or faults")
+	addr := flag.String("addr", "127.0.0.1:9090", "network address to serve on")
+	ntype := flag.String("net", "tcp", "network type to serve on")
+	up := flag.String("up", "", "upstream server dial strings, net!host!port, separated by commas; failover: the primary first")
+	aname := flag.String("aname", "", "attach name on the upstream server")
+	uid := flag.Uint("uid", 1, "user id used to attach to the upstream server")
+	ttl := flag.Duration("ttl", interpose.DefaultTTL, "cache: time an entry is used before it is checked")
+	maxdata := flag.Int("maxdata", interpose.DefaultMaxData, "cache: largest object whose data is cached")
+	policy := flag.String("policy", "rr", "balance: rr (round robin) or least (least outstanding requests)")
+	quorum := flag.Int("quorum", 0, "failover: servers that must make a change, 0 for all")
+	rules := flag.String("rules", "", "filter: file holding the access policy")
+	xform := flag.String("xform", "", "transform: glob=codec pairs separated by commas; codecs are cbor2json, json2cbor, gzip and redact:field:...")
+	logname := flag.String("log", "", "audit: file the records are written to; none if empty")
+	logsize := flag.Int64("logsize", 16<<20, "audit: size at which the log file is rotated")
+	logkeep := flag.Int("logkeep", 4, "audit: rotated log files kept")
+	faultfile := flag.String("faults", "", "faults: file holding the initial fault rules")
+	seed := flag.Int64("seed", 1, "faults: seed of the fault chances")
+	debug := flag.Int("debug", 0, "warp9 debug level")
+	flag.Parse()
+
+	user := warp9.Identity.User(uint32(*uid))
+	mount := func(ds string) *wkit.MountPoint {
+		unet, uaddr, err := warp9.ParseDialString(ds)
+		if err != nil {
+			log.Fatalf("interpose: %v", err)
+		}
+		mt, err := wkit.MountPointDial(unet, uaddr, *aname, 0, user)
+		if err != nil {
+			log.Fatalf("interpose: mount %s: %v", ds, err)
+		}
+		if err := mt.AutoRemount(wkit.DefaultRemount); err != nil {
+			log.Fatalf("interpose: %v", err)
+		}
+		return mt
+	}
+	mountAll := func() []*wkit.MountPoint {
+		var mts []*wkit.MountPoint
+		for _, ds := range strings.Split(*up, ",") {
+			mts = append(mts, mount(ds))
+		}
+		return mts
+	}
+
+	var srv *wkit.ServerController
+	switch *mode {
+	case "cache":
+		c := interpose.NewCache("cache", *debug, mount(*up), *ttl, *maxdata)
+		if !c.Start() {
+			log.Fatal("interpose: unable to start server")
+		}
+		srv = c.ServerController
+	case "balance":
+		bp := interpose.RoundRobin
+		switch *policy {
+		case "rr":
+		case "least":
+			bp = interpose.LeastOutstanding
+		default:
+			log.Fatalf("interpose: unknown policy %q", *policy)
+		}
+		b := interpose.NewBalancer("balance", *debug, mountAll(), bp)
+		if !b.Start() {
+			log.Fatal("interpose: unable to start server")
+		}
+		srv = b.ServerController
+	case "failover":
+		mts := mountAll()
+		f := interpose.NewFailover("failover", *debug, mts[0], mts[1:], *quorum)
+		if !f.Start() {
+			log.Fatal("interpose: unable to start server")
+		}
+		srv = f.ServerController
+	case "filter":
+		p, err := interpose.LoadPolicyFile(*rules)
+		if err != nil {
+			log.Fatalf("interpose: %v", err)
+		}
+		f := interpose.NewFilter("filter", *debug, mount(*up), p)
+		if !f.Start() {
+			log.Fatal("interpose: unable to start server")
+		}
+		srv = f.ServerController
+	case "transform":
+		x := interpose.NewTransform("transform", *debug, mount(*up))
+		for _, pair := range strings.Split(*xform, ",") {
+			glob, codec, ok := cut(pair, "=")
+			if !ok {
+				log.Fatalf("interpose: bad transform %q", pair)
+			}
+			if err := x.Handle(glob, transformer(codec)); err != nil {
+				log.Fatalf("interpose: %s: %v", glob, err)
+			}
+		}
+		if !x.Start() {
+			log.Fatal("interpose: unable to start server")
+		}
+		srv = x.ServerController
+	case "audit":
+		audit := interpose.NewAudit(nil)
+		if *logname != "" {
+			rf, err := interpose.OpenRotatingFile(*logname, *logsize, *logkeep)
+			if err != nil {
+				log.Fatalf("interpose: %v", err)
+			}
+			defer rf.Close()
+			audit.Log = rf
+		}
+		a := interpose.NewAuditor("audit", *debug, mount(*up), audit)
+		if !a.Start() {
+			log.Fatal("interpose: unable to start server")
+		}
+		srv = a.ServerController
+	case "faults":
+		faults := interpose.NewFaults(*seed)
+		if *faultfile != "" {
+			f, err := os.Open(*faultfile)
+			if err != nil {
+				log.Fatalf("interpose: %v", err)
+			}
+			rules, err := interpose.ParseFaultRules(f)
+			f.Close()
+			if err != nil {
+				log.Fatalf("interpose: %v", err)
+			}
+			faults.Add(rules...)
+		}
+		fi := interpose.NewFaultInjector("faults", *debug, mount(*up), faults)
+		if !fi.Start() {
+			log.Fatal("interpose: unable to start server")
+		}
+		srv = fi.ServerController
+	default:
+		log.Fatalf("interpose: unknown mode %q", *mode)
+	}
+
+	log.Printf("interpose: %s serving on %s!%s", *mode, *ntype, *addr)
+	if err := srv.StartNetListener(*ntype, *addr); err != nil {
+		log.Fatalf("interpose: %v", err)
+	}
+}
+
+// the transformer named by codec
+func transformer(codec string) interpose.Transformer {
+	name, args, _ := cut(codec, ":")
+	switch name {
+	case "cbor2json":
+		return interpose.CBORToJSON
+	case "json2cbor":
+		return interpose.Reverse(interpose.CBORToJSON)
+	case "gzip":
+		return interpose.Gzip
+	case "redact":
+		return interpose.Redact(strings.Split(args, ":")...)
+	}
+	log.Fatalf("interpose: unknown codec %q", codec)
+	return nil
+}
+
+// strings.Cut, which needs go1.18
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,60 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+// Command nspace runs a namespace gateway: it mounts the remote object
+// servers listed in a mount table, or a namespace description, and serves
+// the merged namespace.
+//
+//	nspace -table mounts.json -addr :9090
+//	nspace -ns namespace -addr :9090
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/lavaorg/warp/nspace"
+	"github.com/lavaorg/warp/warp9"
+	"github.com/lavaorg/warp/wkit"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:9090", "network address to serve on")
+	ntype := flag.String("net", "tcp", "network type to serve on")
+	tablefile := flag.String("table", "", "JSON mount table")
+	nsfile := flag.String("ns", "", "namespace description")
+	probe := flag.Duration("probe", wkit.DefaultRemount.Probe, "interval between probes of the remote servers; 0 disables probing")
+	minBackoff := flag.Duration("minbackoff", wkit.DefaultRemount.MinBackoff, "first delay before remounting a server")
+	maxBackoff := flag.Duration("maxbackoff", wkit.DefaultRemount.MaxBackoff, "longest delay between remount attempts")
+	uid := flag.Uint("uid", 1, "user id used to attach to the remote servers")
+	debug := flag.Int("debug", 0, "warp9 debug level")
+	flag.Parse()
+
+	var table *nspace.Table
+	if *tablefile != "" {
+		var err error
+		table, err = nspace.LoadTableFile(*tablefile)
+		if err != nil {
+			log.Fatalf("nspace: %v", err)
+		}
+	}
+
+	user := warp9.Identity.User(uint32(*uid))
+	g, err := nspace.NewGateway("nspace", *debug, table, user)
+	if err != nil {
+		log.Fatalf("nspace: %v", err)
+	}
+	g.Remount = wkit.RemountPolicy{Probe: *probe, MinBackoff: *minBackoff, MaxBackoff: *maxBackoff}
+	if *nsfile != "" {
+		if err := g.LoadNamespaceFile(*nsfile); err != nil {
+			log.Fatalf("nspace: %v", err)
+		}
+	}
+	if !g.Start() {
+		log.Fatal("nspace: unable to start server")
+	}
+	log.Printf("nspace: serving on %s!%s", *ntype, *addr)
+	if err := g.StartNetListener(*ntype, *addr); err != nil {
+		log.Fatalf("nspace: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+// Command relay runs a Warp9 relay: object servers dial in to the
+// registration address and clients reach them by mounting the client
+// address with the server's name as the aname.
+//
+//	relay -addr :9090 -reg :9091
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+
+	"github.com/lavaorg/warp/relay"
+	"github.com/lavaorg/warp/warp9"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:9090", "network address to serve clients on")
+	regaddr := flag.String("reg", "127.0.0.1:9091", "network address servers dial in to")
+	ntype := flag.String("net", "tcp", "network type")
+	uid := flag.Uint("uid", 1, "user id used to attach to the servers")
+	debug := flag.Int("debug", 0, "warp9 debug level")
+	flag.Parse()
+
+	r := relay.NewRelay("relay", *debug, warp9.Identity.User(uint32(*uid)))
+	if !r.Start() {
+		log.Fatal("relay: unable to start server")
+	}
+	lreg, err := net.Listen(*ntype, *regaddr)
+	if err != nil {
+		log.Fatalf("relay: %v", err)
+	}
+	lcl, err := net.Listen(*ntype, *addr)
+	if err != nil {
+		log.Fatalf("relay: %v", err)
+	}
+	go func() {
+		log.Fatalf("relay: registration: %v", r.ServeServers(lreg))
+	}()
+	log.Printf("relay: clients on %s!%s, servers on %s!%s", *ntype, *addr, *ntype, *regaddr)
+	if err := r.ServeClients(lcl); err != nil {
+		log.Fatalf("relay: %v", err)
+	}
+}
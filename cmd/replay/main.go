@@ -0,0 +1,57 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+// Command replay replays the sessions recorded by tap against a server
+// and reports the replies that differ from those recorded. It exits with
+// status 1 if any do.
+//
+//	replay -up tcp!device!9090 -ignore '*time,*qid.path' session.tap
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/lavaorg/warp/tap"
+	"github.com/lavaorg/warp/warp9"
+)
+
+func main() {
+	up := flag.String("up", "", "server dial string, net!host!port")
+	paced := flag.Bool("paced", false, "send the messages at the recorded pace, without waiting for replies")
+	ignore := flag.String("ignore", "*time", "fields not compared, as path.Match patterns separated by commas")
+	timeout := flag.Duration("timeout", tap.DefaultTimeout, "time to wait for a reply")
+	flag.Parse()
+
+	unet, uaddr, err := warp9.ParseDialString(*up)
+	if err != nil {
+		log.Fatalf("replay: %v", err)
+	}
+	var in io.Reader = os.Stdin
+	if flag.NArg() > 0 {
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			log.Fatalf("replay: %v", err)
+		}
+		defer f.Close()
+		in = f
+	}
+	rp := &tap.Replayer{Net: unet, Addr: uaddr, Paced: *paced, Timeout: *timeout}
+	if *ignore != "" {
+		rp.Ignore = strings.Split(*ignore, ",")
+	}
+	divs, err := rp.Replay(tap.NewReader(in))
+	if err != nil {
+		log.Fatalf("replay: %v", err)
+	}
+	for _, d := range divs {
+		fmt.Println(d)
+	}
+	if len(divs) > 0 {
+		os.Exit(1)
+	}
+}
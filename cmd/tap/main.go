@@ -0,0 +1,43 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+// Command tap runs a proxy between warp9 clients and a server, logging
+// every message of their sessions.
+//
+//	tap -addr :9090 -up tcp!device!9090 -log session.tap
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/lavaorg/warp/tap"
+	"github.com/lavaorg/warp/warp9"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:9090", "network address to serve on")
+	ntype := flag.String("net", "tcp", "network type to serve on")
+	up := flag.String("up", "", "server dial string, net!host!port")
+	logname := flag.String("log", "", "file the log is appended to; standard output if empty")
+	flag.Parse()
+
+	unet, uaddr, err := warp9.ParseDialString(*up)
+	if err != nil {
+		log.Fatalf("tap: %v", err)
+	}
+	out := os.Stdout
+	if *logname != "" {
+		out, err = os.OpenFile(*logname, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			log.Fatalf("tap: %v", err)
+		}
+		defer out.Close()
+	}
+	p := tap.NewProxy(unet, uaddr, tap.NewWriter(out))
+	log.Printf("tap: %s!%s to %s", *ntype, *addr, *up)
+	if err := p.ListenAndServe(*ntype, *addr); err != nil {
+		log.Fatalf("tap: %v", err)
+	}
+}
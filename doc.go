@@ -30,6 +30,7 @@ The Warp framework will provide:
 
    Warp9 -- a remote object access protocol
    nspace -- an intelligent gateway dynamic namespace for mounting remote servers
+   interpose -- ready to run interposers between clients and servers
    client frameworks
    simple single level servers
    multi-level servers
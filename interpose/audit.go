@@ -0,0 +1,181 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package interpose
+
+import (
+	"encoding/json"
+	"io"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/lavaorg/warp/warp9"
+	"github.com/lavaorg/warp/wkit"
+)
+
+// An AuditRecord describes one audited request.
+type AuditRecord struct {
+	Time    time.Time     `json:"time"`
+	User    uint32        `json:"uid"`
+	Addr    string        `json:"addr"` // remote address of the connection
+	Op      string        `json:"op"`
+	Path    string        `json:"path"`            // from the root attached to; for attach, the aname
+	Bytes   uint32        `json:"bytes,omitempty"` // read or written
+	Result  string        `json:"result"`          // "ok" or the error returned
+	Latency time.Duration `json:"latency_ns"`
+}
+
+// the requests audited
+var auditOps = map[uint8]string{
+	warp9.Tattach: "attach",
+	warp9.Topen:   "open",
+	warp9.Tcreate: "create",
+	warp9.Tread:   "read",
+	warp9.Twrite:  "write",
+	warp9.Tremove: "remove",
+	warp9.Twstat:  "wstat",
+}
+
+// An Audit records the attaches, opens, creates, reads, writes, removes
+// and wstats served by a warp9 server. Each record is written to Log as
+// a line of JSON and published as an event by Events, so auditors can
+// subscribe over warp9.
+//
+// An Audit is a request interceptor: it implements the
+// warp9.SrvReqProcessOps of a server whose ops embed it, such as
+//
+//	type audited struct {
+//		*wkit.ServerController
+//		*interpose.Audit
+//	}
+//
+//	srv.Start(audited{srv, audit})
+//
+// Reads of event objects are not audited: each record is an event, and
+// auditing their reading would never end.
+type Audit struct {
+	Log    io.Writer       // where records are written; nil for none
+	Events *wkit.EventItem // publishes the records; place it in a tree
+
+	mu    sync.Mutex
+	start map[*warp9.SrvReq]time.Time
+	wmu   sync.Mutex
+}
+
+// NewAudit returns an audit writing its records to log.
+func NewAudit(log io.Writer) *Audit {
+	return &Audit{
+		Log:    log,
+		Events: wkit.NewEventItem("audit"),
+		start:  make(map[*warp9.SrvReq]time.Time),
+	}
+}
+
+// SrvReqProcess notes when an audited request starts and processes it.
+func (a *Audit) SrvReqProcess(req *warp9.SrvReq) {
+	if _, ok := auditOps[req.Tc.Type]; ok {
+		a.mu.Lock()
+		a.start[req] = time.Now()
+		a.mu.Unlock()
+	}
+	req.Process()
+}
+
+// SrvReqRespond records an audited request as it is responded to.
+func (a *Audit) SrvReqRespond(req *warp9.SrvReq) {
+	a.mu.Lock()
+	t0, ok := a.start[req]
+	delete(a.start, req)
+	a.mu.Unlock()
+	if ok {
+		a.record(req, t0)
+	}
+	req.PostProcess()
+}
+
+func (a *Audit) record(req *warp9.SrvReq, t0 time.Time) {
+	tc, rc := req.Tc, req.Rc
+	if req.Fid != nil {
+		if _, ok := req.Fid.Aux.(*wkit.EventItem); ok {
+			return
+		}
+	}
+	rec := AuditRecord{
+		Time:    t0,
+		Op:      auditOps[tc.Type],
+		Result:  "ok",
+		Latency: time.Since(t0),
+	}
+	if addr := req.Conn.RemoteAddr(); addr != nil {
+		rec.Addr = addr.String()
+	}
+	if req.Fid != nil {
+		rec.Path = req.Fid.Path
+		if req.Fid.User != nil {
+			rec.User = req.Fid.User.Id()
+		}
+	}
+	switch tc.Type {
+	case warp9.Tattach:
+		rec.Path, rec.User = tc.Aname, tc.Uid
+	case warp9.Tcreate:
+		rec.Path = path.Join(rec.Path, tc.Name)
+	}
+	switch {
+	case rc == nil:
+	case rc.Type == warp9.Rerror:
+		rec.Result = "error"
+		if rc.Error != nil {
+			rec.Result = rc.Error.Error()
+		}
+	case rc.Type == warp9.Rread || rc.Type == warp9.Rwrite:
+		rec.Bytes = rc.Count
+	}
+	a.Record(rec)
+}
+
+// Record writes rec to Log and publishes it.
+func (a *Audit) Record(rec AuditRecord) {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		warp9.Error("audit: %v", err)
+		return
+	}
+	line = append(line, '\n')
+	if a.Log != nil {
+		a.wmu.Lock()
+		_, err = a.Log.Write(line)
+		a.wmu.Unlock()
+		if err != nil {
+			warp9.Error("audit: %v", err)
+		}
+	}
+	if a.Events != nil {
+		a.Events.Publish(wkit.Event(line))
+	}
+}
+
+// An Auditor is an interposer serving the tree of an upstream mount
+// unchanged and auditing the requests to it. The audit's Events are in
+// the tree attached with the aname "audit".
+type Auditor struct {
+	*Proxy
+	*Audit
+}
+
+// NewAuditor returns an auditing interposer serving the tree of up.
+func NewAuditor(id string, debuglevel int, up wkit.Directory, audit *Audit) *Auditor {
+	a := &Auditor{Proxy: NewProxy(id, debuglevel, up), Audit: audit}
+	tree := wkit.NewDirItem("audit")
+	tree.AddItem(audit.Events)
+	if err := a.AddTree("audit", tree, nil); err != nil {
+		warp9.Error("auditor: events not served: %v", err)
+	}
+	return a
+}
+
+// Start starts the warp9 server of the auditor.
+func (a *Auditor) Start() bool {
+	return a.ServerController.Start(a)
+}
@@ -0,0 +1,240 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package interpose
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lavaorg/warp/warp9"
+	"github.com/lavaorg/warp/wkit"
+)
+
+// the records written to log
+func records(t *testing.T, log []byte) []AuditRecord {
+	t.Helper()
+	var recs []AuditRecord
+	sc := bufio.NewScanner(bytes.NewReader(log))
+	for sc.Scan() {
+		var rec AuditRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatalf("%q: %v", sc.Text(), err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+// a log written by the server's goroutines and read by the test
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.b.Write(p)
+}
+
+func (sb *syncBuffer) Bytes() []byte {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return append([]byte(nil), sb.b.Bytes()...)
+}
+
+func TestAuditor(t *testing.T) {
+	u, addr := serveUpstream(t, "device", tree("/", map[string]string{"temp": "21"}))
+	mt, err := wkit.MountPointDial("tcp", addr, "", 0, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mt.Unmount()
+
+	var log syncBuffer
+	audit := NewAudit(&log)
+	a := NewAuditor("audit", 0, mt, audit)
+	if !a.Start() {
+		t.Fatal("unable to start auditor")
+	}
+	l := listen(t)
+	defer l.Close()
+	go a.StartListener(l)
+
+	// subscribe to the records before making them
+	ec9, err := warp9.Mount("tcp", l.Addr().String(), "audit", 8192, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ec9.Unmount()
+	events, err := ec9.Open("/audit", warp9.OREAD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Close()
+
+	c9, err := warp9.Mount("tcp", l.Addr().String(), "", 8192, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c9.Unmount()
+	// a read costs the upstream stats of its walk, and no more
+	stats := u.Count("stat")
+	fid, err := c9.Walk("/temp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c9.Clunk(fid)
+	walk := u.Count("stat") - stats
+	stats = u.Count("stat")
+	if s := get(c9, "/temp"); s != "21" {
+		t.Errorf("read: %q", s)
+	}
+	if n := u.Count("stat") - stats; n != walk {
+		t.Errorf("%d upstream stats for a read, %d for a walk", n, walk)
+	}
+	if _, err := c9.Open("/missing", warp9.OREAD); err == nil {
+		t.Error("opened a missing object")
+	}
+	obj, err := c9.Open("/temp", warp9.OWRITE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := obj.WriteAt([]byte("22"), 0); err != nil {
+		t.Fatal(err)
+	}
+	obj.Close()
+
+	var ops []string
+	for _, rec := range records(t, log.Bytes()) {
+		if rec.User != user.Id() || !strings.HasPrefix(rec.Addr, "127.0.0.1:") || rec.Latency < 0 {
+			t.Errorf("record: %+v", rec)
+		}
+		ops = append(ops, rec.Op+" "+rec.Path+" "+rec.Result)
+		switch rec.Op {
+		case "read":
+			if rec.Bytes != 2 {
+				t.Errorf("read bytes: %d", rec.Bytes)
+			}
+		case "write":
+			if rec.Bytes != 2 {
+				t.Errorf("write bytes: %d", rec.Bytes)
+			}
+		}
+	}
+	// the events are not audited
+	want := []string{
+		"attach audit ok",
+		"attach  ok", "open /temp ok", "read /temp ok",
+		"open /temp ok", "write /temp ok",
+	}
+	if len(ops) < len(want) || strings.Join(ops[:len(want)], "\n") != strings.Join(want, "\n") {
+		t.Errorf("records:\n%s", strings.Join(ops, "\n"))
+	}
+
+	// the subscriber sees the records made since it opened
+	buf := make([]byte, 4096)
+	n, err := events.ReadAt(buf, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec AuditRecord
+	if err := json.Unmarshal(buf[:n], &rec); err != nil || rec.Op != "attach" || rec.Path != "" {
+		t.Errorf("event: %s, %v", buf[:n], err)
+	}
+}
+
+// the audit as the interceptor of a server
+type audited struct {
+	*wkit.ServerController
+	*Audit
+}
+
+func TestAuditInterceptor(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "audit.log")
+	rf, err := OpenRotatingFile(name, 600, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+	srv := wkit.NewServer("device", 0, tree("/", map[string]string{"temp": "21"}))
+	if !srv.Start(audited{srv, NewAudit(rf)}) {
+		t.Fatal("unable to start server")
+	}
+	c9 := mountInterposer(t, srv)
+	if _, err := c9.Open("/missing", warp9.OREAD); err == nil {
+		t.Error("opened a missing object")
+	}
+	for i := 0; i < 10; i++ {
+		get(c9, "/temp")
+	}
+
+	// the log was rotated, keeping two old files, each whole records
+	if _, err := os.Stat(name + ".3"); err == nil {
+		t.Error("kept a third old file")
+	}
+	var all []AuditRecord
+	for _, f := range []string{name + ".2", name + ".1", name} {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b) > 600 {
+			t.Errorf("%s: %d bytes", f, len(b))
+		}
+		all = append(all, records(t, b)...)
+	}
+	if len(all) == 0 || all[len(all)-1].Op != "read" || all[len(all)-1].Path != "/temp" {
+		t.Errorf("last record: %+v", all[len(all)-1])
+	}
+	if b, _ := os.ReadFile(name + ".2"); !bytes.Contains(b, []byte(`"op":"open"`)) {
+		t.Errorf("oldest file: %s", b)
+	}
+}
+
+func TestRotateFailure(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "audit.log")
+	rf, err := OpenRotatingFile(name, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+	if _, err := rf.Write([]byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// a directory in the way of the old file fails the rotation; the
+	// records go on to the current file
+	if err := os.MkdirAll(filepath.Join(name+".1", "x"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rf.Write([]byte("second\n")); err == nil {
+		t.Error("rotation did not fail")
+	}
+	if _, err := rf.Write([]byte("third\n")); err == nil {
+		t.Error("rotation did not fail")
+	}
+	if b, _ := os.ReadFile(name); string(b) != "first\nsecond\nthird\n" {
+		t.Errorf("after failed rotations: %q", b)
+	}
+
+	// once it is out of the way the file is rotated
+	if err := os.RemoveAll(name + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rf.Write([]byte("fourth\n")); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(name); string(b) != "fourth\n" {
+		t.Errorf("after rotation: %q", b)
+	}
+}
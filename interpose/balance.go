@@ -0,0 +1,220 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package interpose
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/lavaorg/warp/warp9"
+	"github.com/lavaorg/warp/wkit"
+)
+
+// BalancePolicy selects the replica given to a new session.
+type BalancePolicy int
+
+const (
+	RoundRobin       BalancePolicy = iota // the healthy replicas in turn
+	LeastOutstanding                      // the healthy replica with the fewest requests in progress
+)
+
+// A Balancer is an interposer spreading client sessions over replicas of
+// one object server. Each attach is given a healthy replica; every fid
+// walked from it stays on that replica, so fid state such as open modes
+// and offsets is never split between servers.
+//
+// A replica is healthy while its mount is up. The replicas are watched
+// with AutoRemount using Remount: one that stops answering its probes is
+// not given new sessions until it has been remounted. Sessions already on
+// a failed replica fail and must attach again.
+type Balancer struct {
+	*wkit.ServerController
+	Policy  BalancePolicy
+	Remount wkit.RemountPolicy // how the replicas are probed and remounted
+
+	mu       sync.Mutex
+	replicas []*replica
+	next     int                        // where the round robin starts
+	fids     map[*warp9.SrvFid]*replica // the replica of each fid
+}
+
+// one replica and the load on it
+type replica struct {
+	mt          *wkit.MountPoint
+	outstanding int // requests in progress
+	fids        int // fids pinned to the replica
+}
+
+// NewBalancer returns a load balancing interposer over the replicas.
+func NewBalancer(id string, debuglevel int, replicas []*wkit.MountPoint, policy BalancePolicy) *Balancer {
+	b := &Balancer{
+		Policy:  policy,
+		Remount: wkit.DefaultRemount,
+		fids:    make(map[*warp9.SrvFid]*replica),
+	}
+	for _, mt := range replicas {
+		b.replicas = append(b.replicas, &replica{mt: mt})
+	}
+	b.ServerController = wkit.NewServer(id, debuglevel, wkit.NewDirItem("/"))
+	return b
+}
+
+// Start starts watching the replicas and the warp9 server of the balancer.
+func (b *Balancer) Start() bool {
+	for _, r := range b.replicas {
+		err := r.mt.AutoRemount(b.Remount)
+		if err != nil && !errors.Is(err, warp9.ErrorCode(warp9.Einuse)) {
+			warp9.Error("balancer: replica %s not watched: %v", r.mt.Name(), err)
+		}
+	}
+	return b.ServerController.Start(b)
+}
+
+// pick the replica for a new session; the round robin goes on from the
+// one after it
+func (b *Balancer) pick() *replica {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var best *replica
+	n := len(b.replicas)
+	next := b.next
+	for i := 0; i < n; i++ {
+		j := (b.next + i) % n
+		r := b.replicas[j]
+		if state, _, _ := r.mt.State(); state != wkit.MountUp {
+			continue
+		}
+		if best == nil || b.Policy == LeastOutstanding && r.outstanding < best.outstanding {
+			best, next = r, (j+1)%n
+		}
+		if b.Policy == RoundRobin {
+			break
+		}
+	}
+	b.next = next
+	return best
+}
+
+// pin fid to r
+func (b *Balancer) pin(fid *warp9.SrvFid, r *replica) {
+	b.mu.Lock()
+	if old := b.fids[fid]; old != nil {
+		old.fids--
+	}
+	b.fids[fid] = r
+	r.fids++
+	b.mu.Unlock()
+}
+
+// release the pin of fid
+func (b *Balancer) unpin(fid *warp9.SrvFid) {
+	b.mu.Lock()
+	if r := b.fids[fid]; r != nil {
+		r.fids--
+		delete(b.fids, fid)
+	}
+	b.mu.Unlock()
+}
+
+// count a request on the replica of fid until the returned func is called
+func (b *Balancer) track(fid *warp9.SrvFid) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r := b.fids[fid]
+	if r == nil {
+		return func() {}
+	}
+	r.outstanding++
+	return func() {
+		b.mu.Lock()
+		r.outstanding--
+		b.mu.Unlock()
+	}
+}
+
+// Attach gives the session the root of a healthy replica.
+func (b *Balancer) Attach(req *warp9.SrvReq) {
+	if aname := req.Tc.Aname; aname != "" && aname != "/" {
+		req.RespondError(warp9.ErrorMsg(warp9.Enotexist, aname))
+		return
+	}
+	if req.Afid != nil {
+		req.RespondError(warp9.ErrorCode(warp9.Enoauth))
+		return
+	}
+	r := b.pick()
+	if r == nil {
+		req.RespondError(warp9.ErrorMsg(warp9.Econn, "no replica available"))
+		return
+	}
+	root, err := r.mt.AttachRoot()
+	if err != nil {
+		req.RespondError(err)
+		return
+	}
+	b.pin(req.Fid, r)
+	req.Fid.Aux = root
+	qid := root.GetQid()
+	req.RespondRattach(&qid)
+}
+
+// Walk keeps the new fid on the replica of the fid walked from. The fid
+// is pinned before the walk is answered, so a clunk right after it finds
+// the pin; if the walk fails the new fid is destroyed, releasing it.
+func (b *Balancer) Walk(req *warp9.SrvReq) {
+	defer b.track(req.Fid)()
+	b.mu.Lock()
+	r := b.fids[req.Fid]
+	b.mu.Unlock()
+	if r != nil && req.Newfid != req.Fid {
+		b.pin(req.Newfid, r)
+	}
+	b.ServerController.Walk(req)
+}
+
+// FidDestroy releases the fid's pin.
+func (b *Balancer) FidDestroy(fid *warp9.SrvFid) {
+	b.ServerController.FidDestroy(fid)
+	b.unpin(fid)
+}
+
+func (b *Balancer) Open(req *warp9.SrvReq) {
+	defer b.track(req.Fid)()
+	b.ServerController.Open(req)
+}
+
+func (b *Balancer) Create(req *warp9.SrvReq) {
+	defer b.track(req.Fid)()
+	b.ServerController.Create(req)
+}
+
+func (b *Balancer) Read(req *warp9.SrvReq) {
+	defer b.track(req.Fid)()
+	b.ServerController.Read(req)
+}
+
+func (b *Balancer) Write(req *warp9.SrvReq) {
+	defer b.track(req.Fid)()
+	b.ServerController.Write(req)
+}
+
+func (b *Balancer) Clunk(req *warp9.SrvReq) {
+	defer b.track(req.Fid)()
+	b.ServerController.Clunk(req)
+}
+
+func (b *Balancer) Remove(req *warp9.SrvReq) {
+	defer b.track(req.Fid)()
+	b.ServerController.Remove(req)
+}
+
+func (b *Balancer) Stat(req *warp9.SrvReq) {
+	defer b.track(req.Fid)()
+	b.ServerController.Stat(req)
+}
+
+func (b *Balancer) Wstat(req *warp9.SrvReq) {
+	defer b.track(req.Fid)()
+	b.ServerController.Wstat(req)
+}
@@ -0,0 +1,115 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package interpose
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lavaorg/warp/warp9"
+	"github.com/lavaorg/warp/wkit"
+)
+
+// mount an upstream replica whose id object holds name
+func replicaMount(t *testing.T, name string) *wkit.MountPoint {
+	_, addr := serveUpstream(t, name, tree("/", map[string]string{"id": name}))
+	mt, err := wkit.MountPointDial("tcp", addr, "", 0, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(mt.Unmount)
+	return mt
+}
+
+func TestBalancer(t *testing.T) {
+	ra, rb := replicaMount(t, "a"), replicaMount(t, "b")
+	b := NewBalancer("lb", 0, []*wkit.MountPoint{ra, rb}, RoundRobin)
+	b.Remount = wkit.RemountPolicy{Probe: 10 * time.Millisecond, MinBackoff: time.Hour}
+	if !b.Start() {
+		t.Fatal("unable to start balancer")
+	}
+
+	// sessions alternate; a session's fids stay on its replica
+	var ids []string
+	for i := 0; i < 4; i++ {
+		c9 := mountInterposer(t, b.ServerController)
+		id := get(c9, "/id")
+		if again := get(c9, "/id"); again != id {
+			t.Errorf("session moved from %s to %s", id, again)
+		}
+		ids = append(ids, id)
+	}
+	if s := strings.Join(ids, " "); s != "a b a b" {
+		t.Errorf("round robin: %s", s)
+	}
+
+	// a failed replica gets no new sessions
+	ra.Unmount()
+	for i := 0; i < 3; i++ {
+		if id := get(mountInterposer(t, b.ServerController), "/id"); id != "b" {
+			t.Errorf("session on %q with a down", id)
+		}
+	}
+
+	// every fid walked to is pinned once, and released when clunked or
+	// when its walk fails
+	c9 := mountInterposer(t, b.ServerController)
+	for i := 0; i < 20; i++ {
+		fid, err := c9.Walk("/id")
+		if err != nil {
+			t.Fatal(err)
+		}
+		c9.Clunk(fid)
+		if _, err := c9.Walk("/missing"); err == nil {
+			t.Fatal("walked to a missing object")
+		}
+	}
+	b.mu.Lock()
+	pinned := 0
+	for _, r := range b.replicas {
+		pinned += r.fids
+	}
+	if pinned != len(b.fids) {
+		t.Errorf("%d pins counted for %d fids", pinned, len(b.fids))
+	}
+	b.mu.Unlock()
+
+	rb.Unmount()
+	l := listen(t)
+	defer l.Close()
+	go b.StartListener(l)
+	if _, err := warp9.Mount("tcp", l.Addr().String(), "", 8192, user); err == nil {
+		t.Error("attached with no replica up")
+	}
+}
+
+// with a replica down the others still take turns
+func TestRoundRobinDown(t *testing.T) {
+	mts := []*wkit.MountPoint{replicaMount(t, "a"), replicaMount(t, "b"), replicaMount(t, "c")}
+	b := NewBalancer("lb", 0, mts, RoundRobin)
+	mts[0].Unmount()
+	for i := 0; i < 6; i++ {
+		if r := b.pick(); r == nil || r.mt != mts[1+i%2] {
+			t.Fatalf("pick %d: replica %v", i, r)
+		}
+	}
+}
+
+func TestLeastOutstanding(t *testing.T) {
+	mts := []*wkit.MountPoint{replicaMount(t, "a"), replicaMount(t, "b"), replicaMount(t, "c")}
+	b := NewBalancer("lb", 0, mts, LeastOutstanding)
+	b.replicas[0].outstanding = 3
+	b.replicas[1].outstanding = 1
+	b.replicas[2].outstanding = 2
+	for i := 0; i < 3; i++ {
+		if r := b.pick(); r != b.replicas[1] {
+			t.Errorf("picked %s", r.mt.Name())
+		}
+	}
+	mts[1].Unmount()
+	if r := b.pick(); r != b.replicas[2] {
+		t.Errorf("picked %s with b down", r.mt.Name())
+	}
+}
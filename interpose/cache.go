@@ -0,0 +1,469 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package interpose
+
+import (
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lavaorg/warp/warp9"
+	"github.com/lavaorg/warp/wkit"
+)
+
+// Defaults for a Cache.
+const (
+	DefaultTTL     = 5 * time.Second
+	DefaultMaxData = 64 * 1024
+)
+
+// A Cache is an interposer that serves the tree of an upstream mount and
+// keeps the results of stats, directory reads and object reads. Many
+// downstream clients then share the one upstream connection, and read
+// mostly objects are fetched once.
+//
+// A cached entry is used for TTL; after that the object is stat'ed again
+// and its data is kept only if its Qid.Version is set and unchanged. A
+// version of 0 gives nothing to check against, and a directory's version
+// need not change with its entries, so those are always fetched again.
+// Writes, creates, removes and wstats go to the upstream server and drop
+// the entries they affect. Objects longer than MaxData, and append only
+// or exclusive objects, are read from the upstream server each time.
+//
+// TTL may be set before the cache is started; SetTTL changes it after.
+type Cache struct {
+	*wkit.ServerController
+	TTL     time.Duration // how long an entry is used before it is checked; kept under mu
+	MaxData int           // largest object whose data is cached
+
+	up      *wkit.MountPoint
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+	stats   CacheStats
+}
+
+// CacheStats counts the requests served from the cache and those that
+// went to the upstream server.
+type CacheStats struct {
+	Hits, Misses uint64
+}
+
+// the cached state of one upstream object
+type cacheEntry struct {
+	dir  *warp9.Dir // nil if only known through its listing
+	at   time.Time  // when dir was fetched or last checked
+	list []byte     // packed directory entries
+	data []byte
+	hold bool // data is cached
+}
+
+// NewCache returns a cache interposer serving the tree of up.
+func NewCache(id string, debuglevel int, up *wkit.MountPoint, ttl time.Duration, maxdata int) *Cache {
+	c := &Cache{
+		TTL:     ttl,
+		MaxData: maxdata,
+		up:      up,
+		entries: make(map[string]*cacheEntry),
+	}
+	root := &cacheItem{node: node{Dir: *up.GetDir(), path: "/"}, c: c}
+	c.ServerController = wkit.NewServer(id, debuglevel, root)
+	return c
+}
+
+// Start starts the warp9 server of the cache.
+func (c *Cache) Start() bool {
+	return c.ServerController.Start(c.ServerController)
+}
+
+// Stats returns the hit and miss counts.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// SetTTL changes how long an entry is used before it is checked.
+func (c *Cache) SetTTL(ttl time.Duration) {
+	c.mu.Lock()
+	c.TTL = ttl
+	c.mu.Unlock()
+}
+
+// Purge drops every entry.
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	c.entries = make(map[string]*cacheEntry)
+	c.mu.Unlock()
+}
+
+// the fresh entry of p, counting a hit or a miss; called with c.mu held
+func (c *Cache) fresh(p string) *cacheEntry {
+	e := c.entries[p]
+	if e == nil || e.dir == nil || time.Since(e.at) >= c.TTL {
+		c.stats.Misses++
+		return nil
+	}
+	c.stats.Hits++
+	return e
+}
+
+// walk the upstream mount to p
+func (c *Cache) walk(p string) (wkit.Item, error) {
+	return c.up.Walk(splitNames(p))
+}
+
+// stat p, from the cache while the entry is fresh
+func (c *Cache) stat(p string) (*warp9.Dir, error) {
+	c.mu.Lock()
+	if e := c.fresh(p); e != nil {
+		d := *e.dir
+		c.mu.Unlock()
+		return &d, nil
+	}
+	c.mu.Unlock()
+
+	item, err := c.walk(p)
+	if err != nil {
+		c.drop(p)
+		return nil, err
+	}
+	d, err := item.Stat()
+	item.Clunk()
+	if err != nil {
+		c.drop(p)
+		return nil, err
+	}
+
+	c.mu.Lock()
+	e := c.entries[p]
+	if e == nil {
+		e = &cacheEntry{}
+		c.entries[p] = e
+	} else if !sameVersion(e.dir, d) {
+		e.list, e.data, e.hold = nil, nil, false
+	} else {
+		e.list = nil
+	}
+	nd := *d
+	e.dir, e.at = &nd, time.Now()
+	c.mu.Unlock()
+	return d, nil
+}
+
+// the packed entries of directory p
+func (c *Cache) list(p string) ([]byte, error) {
+	if _, err := c.stat(p); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if e := c.entries[p]; e != nil && e.list != nil {
+		list := e.list
+		c.mu.Unlock()
+		return list, nil
+	}
+	c.mu.Unlock()
+
+	list, err := c.readAll(p, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	// the listing also gives the children's stats
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e := c.entries[p]; e != nil {
+		e.list = list
+	}
+	for b := list; len(b) > 0; {
+		d, rest, _, err := warp9.UnpackDir(b)
+		if err != nil {
+			break
+		}
+		b = rest
+		cp := path.Join(p, d.Name)
+		if e := c.entries[cp]; e == nil {
+			c.entries[cp] = &cacheEntry{dir: d, at: now}
+		} else if !sameVersion(e.dir, d) {
+			e.dir, e.at, e.list, e.data, e.hold = d, now, nil, nil, false
+		}
+	}
+	return list, nil
+}
+
+// true if old and d are the same version of an object; an object without
+// a version is never known to be unchanged
+func sameVersion(old, d *warp9.Dir) bool {
+	return old != nil && d.Qid.Version != 0 && old.Qid.Version == d.Qid.Version && old.Qid.Path == d.Qid.Path
+}
+
+// the data of p; ok is false if it is not to be cached
+func (c *Cache) data(p string) (data []byte, ok bool, err error) {
+	d, err := c.stat(p)
+	if err != nil {
+		return nil, false, err
+	}
+	if d.Qid.Type&(warp9.QTAPPEND|warp9.QTEXCL|warp9.QTAUTH) != 0 || d.Length > uint64(c.MaxData) {
+		return nil, false, nil
+	}
+	c.mu.Lock()
+	if e := c.entries[p]; e != nil && e.hold {
+		data = e.data
+		c.mu.Unlock()
+		return data, true, nil
+	}
+	c.mu.Unlock()
+
+	data, err = c.readAll(p, c.MaxData)
+	if err != nil || data == nil {
+		return nil, false, err
+	}
+	c.mu.Lock()
+	if e := c.entries[p]; e != nil {
+		e.data, e.hold = data, true
+	}
+	c.mu.Unlock()
+	return data, true, nil
+}
+
+// read the whole of the upstream object p, as readWhole
+func (c *Cache) readAll(p string, max int) ([]byte, error) {
+	item, err := c.walk(p)
+	if err != nil {
+		return nil, err
+	}
+	defer item.Clunk()
+	iounit, err := item.Open(warp9.OREAD)
+	if err != nil {
+		return nil, err
+	}
+	return readWhole(item, iounit, max)
+}
+
+// forget p
+func (c *Cache) drop(p string) {
+	c.mu.Lock()
+	delete(c.entries, p)
+	c.mu.Unlock()
+}
+
+// forget p and the listing and stat of its directory
+func (c *Cache) changed(p string) {
+	c.mu.Lock()
+	delete(c.entries, p)
+	if p != "/" {
+		delete(c.entries, path.Dir(p))
+	}
+	c.mu.Unlock()
+}
+
+func splitNames(p string) []string {
+	var names []string
+	for _, n := range strings.Split(p, "/") {
+		if n != "" && n != "." {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+// A cacheItem is a fid's view of an upstream object. It is served from the
+// cache until it is opened for writing or its data cannot be cached; then
+// the upstream object is walked to and used directly.
+type cacheItem struct {
+	node
+	c      *Cache
+	up     wkit.Item // the upstream object, once needed
+	buf    []byte    // the data or listing read through this fid
+	cached bool      // reads are served from buf
+}
+
+// the upstream object, walked to on first use
+func (ci *cacheItem) upstream() (wkit.Item, error) {
+	if ci.up == nil {
+		up, err := ci.c.walk(ci.path)
+		if err != nil {
+			return nil, err
+		}
+		ci.up = up
+	}
+	return ci.up, nil
+}
+
+func (ci *cacheItem) GetItem() wkit.Item {
+	return ci
+}
+
+func (ci *cacheItem) IsDirectory() wkit.Directory {
+	if ci.Qid.Type&warp9.QTDIR == 0 {
+		return nil
+	}
+	return ci
+}
+
+// Walked returns a copy for the new fid.
+func (ci *cacheItem) Walked() (wkit.Item, error) {
+	return &cacheItem{node: ci.node, c: ci.c}, nil
+}
+
+// Open serves reads from the cache when it can. Opening for writing, or
+// an object whose data is not cached, opens the upstream object.
+func (ci *cacheItem) Open(mode byte) (uint32, error) {
+	if mode&3 == warp9.OREAD && mode&(warp9.OTRUNC|warp9.ORCLOSE) == 0 {
+		if ci.Qid.Type&warp9.QTDIR != 0 {
+			ci.buf, ci.cached = nil, true
+			return 0, nil
+		}
+		data, ok, err := ci.c.data(ci.path)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			ci.buf, ci.cached = data, true
+			return 0, nil
+		}
+	}
+	up, err := ci.upstream()
+	if err != nil {
+		return 0, err
+	}
+	iounit, err := up.Open(mode)
+	if err != nil {
+		return 0, err
+	}
+	if mode&3 != warp9.OREAD || mode&warp9.OTRUNC != 0 {
+		ci.c.changed(ci.path)
+	}
+	return iounit, nil
+}
+
+func (ci *cacheItem) Read(obuf []byte, off uint64, rcount uint32) (uint32, error) {
+	if !ci.cached {
+		up, err := ci.upstream()
+		if err != nil {
+			return 0, err
+		}
+		return up.Read(obuf, off, rcount)
+	}
+	if ci.buf == nil && ci.Qid.Type&warp9.QTDIR != 0 {
+		list, err := ci.c.list(ci.path)
+		if err != nil {
+			return 0, err
+		}
+		ci.buf = list
+	}
+
+	return wkit.ReadBuf(obuf, ci.buf, off, rcount), nil
+}
+
+func (ci *cacheItem) Write(ibuf []byte, off uint64, count uint32) (uint32, error) {
+	if ci.cached {
+		return 0, warp9.ErrorCode(warp9.Ebaduse)
+	}
+	up, err := ci.upstream()
+	if err != nil {
+		return 0, err
+	}
+	n, err := up.Write(ibuf, off, count)
+	ci.c.changed(ci.path)
+	return n, err
+}
+
+func (ci *cacheItem) Clunk() error {
+	if ci.up == nil {
+		return nil
+	}
+	err := ci.up.Clunk()
+	ci.up = nil
+	return err
+}
+
+func (ci *cacheItem) Remove() error {
+	up, err := ci.upstream()
+	if err != nil {
+		return err
+	}
+	err = up.Remove()
+	ci.c.changed(ci.path)
+	ci.up = nil
+	return err
+}
+
+// Stat returns the object's Dir, from the cache while it is fresh.
+func (ci *cacheItem) Stat() (*warp9.Dir, error) {
+	d, err := ci.c.stat(ci.path)
+	if err != nil {
+		return nil, err
+	}
+	if ci.path == "/" {
+		d.Name = ci.Dir.Name
+	}
+	ci.Dir = *d
+	return d, nil
+}
+
+func (ci *cacheItem) WStat(dir *warp9.Dir) error {
+	up, err := ci.upstream()
+	if err != nil {
+		return err
+	}
+	err = up.WStat(dir)
+	ci.c.changed(ci.path)
+	return err
+}
+
+//
+// Directory interface
+//
+
+// Walk resolves the names from the cache, stat'ing the upstream object
+// when its entry is not fresh.
+func (ci *cacheItem) Walk(names []string) (wkit.Item, error) {
+	p, rest, above := ci.follow(names)
+	if above {
+		// ".." above the upstream root leaves the cache
+		return ci.walkTop(rest)
+	}
+	d, err := ci.c.stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if p == "/" {
+		d.Name = ci.c.up.Name()
+	}
+	n := node{Dir: *d, path: p, parent: ci.parentOf(ci, p), top: ci.top}
+	return &cacheItem{node: n, c: ci.c}, nil
+}
+
+func (ci *cacheItem) RemoveItem(item wkit.Item) error {
+	p := path.Join(ci.path, item.GetDir().Name)
+	up, err := ci.c.walk(p)
+	if err != nil {
+		return err
+	}
+	err = up.Remove()
+	ci.c.changed(p)
+	return err
+}
+
+// Create creates the object upstream; the new object is used directly.
+func (ci *cacheItem) Create(name string, perm uint32, mode uint8) (wkit.Item, error) {
+	up, err := ci.upstream()
+	if err != nil {
+		return nil, err
+	}
+	cr, ok := up.(wkit.Creator)
+	if !ok {
+		return nil, warp9.ErrorCode(warp9.Eperm)
+	}
+	item, err := cr.Create(name, perm, mode)
+	if err != nil {
+		return nil, err
+	}
+	p := path.Join(ci.path, name)
+	ci.c.changed(p)
+	n := node{Dir: *item.GetDir(), path: p, parent: ci, top: ci.top}
+	return &cacheItem{node: n, c: ci.c, up: item}, nil
+}
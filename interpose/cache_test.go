@@ -0,0 +1,267 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package interpose
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lavaorg/warp/warp9"
+	"github.com/lavaorg/warp/wkit"
+)
+
+var user = warp9.Identity.User(1)
+
+func listen(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+// an upstream server counting the requests it serves
+type upstream struct {
+	*wkit.ServerController
+	sync.Mutex
+	reqs map[string]int
+}
+
+func (u *upstream) count(op string) {
+	u.Lock()
+	u.reqs[op]++
+	u.Unlock()
+}
+
+func (u *upstream) Count(op string) int {
+	u.Lock()
+	defer u.Unlock()
+	return u.reqs[op]
+}
+
+func (u *upstream) Walk(req *warp9.SrvReq) { u.count("walk"); u.ServerController.Walk(req) }
+func (u *upstream) Read(req *warp9.SrvReq) { u.count("read"); u.ServerController.Read(req) }
+func (u *upstream) Stat(req *warp9.SrvReq) { u.count("stat"); u.ServerController.Stat(req) }
+
+// serve root as an upstream server, returning it and its address
+func serveUpstream(t *testing.T, id string, root wkit.Directory) (*upstream, string) {
+	u := &upstream{ServerController: wkit.NewServer(id, 0, root), reqs: make(map[string]int)}
+	if !u.Start(u) {
+		t.Fatal("unable to start upstream")
+	}
+	l := listen(t)
+	t.Cleanup(func() { l.Close() })
+	go u.StartListener(l)
+	return u, l.Addr().String()
+}
+
+// a tree of a directory holding the named objects
+func tree(name string, files map[string]string) wkit.Directory {
+	root := wkit.NewDirItem(name)
+	for n, data := range files {
+		item := wkit.NewItem(n)
+		item.SetMode(0666)
+		item.SetBuffer([]byte(data))
+		root.AddItem(item)
+	}
+	return root
+}
+
+// serve the interposer srv, returning a client mounted on it
+func mountInterposer(t *testing.T, srv *wkit.ServerController) *warp9.Clnt {
+	return mountTree(t, srv, "")
+}
+
+// serve the interposer srv, returning a client attached to its tree aname
+func mountTree(t *testing.T, srv *wkit.ServerController, aname string) *warp9.Clnt {
+	l := listen(t)
+	t.Cleanup(func() { l.Close() })
+	go srv.StartListener(l)
+	c9, err := warp9.Mount("tcp", l.Addr().String(), aname, 8192, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c9.Unmount)
+	return c9
+}
+
+func get(c9 *warp9.Clnt, p string) string {
+	data, _, err := c9.Get(p, 0)
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+func TestCache(t *testing.T) {
+	u, addr := serveUpstream(t, "device", tree("/", map[string]string{"temp": "21", "name": "dev1"}))
+	mt, err := wkit.MountPointDial("tcp", addr, "", 0, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mt.Unmount()
+	c := NewCache("cache", 0, mt, time.Hour, 1024)
+	if !c.Start() {
+		t.Fatal("unable to start cache")
+	}
+	c9 := mountInterposer(t, c.ServerController)
+	c9b := mountInterposer(t, c.ServerController)
+
+	if s := get(c9, "/temp"); s != "21" {
+		t.Fatalf("temp: %q", s)
+	}
+	reads := u.Count("read")
+	if s := get(c9b, "/temp"); s != "21" {
+		t.Errorf("second client: %q", s)
+	}
+	for i := 0; i < 2; i++ {
+		if dirs, err := c9.ReadDir("/"); err != nil || len(dirs) != 2 {
+			t.Fatalf("listing: %v, %v", dirs, err)
+		}
+	}
+	if _, err := c9.Stat("/name"); err != nil {
+		t.Fatal(err)
+	}
+	if n := u.Count("read") - reads; n != 2 {
+		t.Errorf("%d upstream reads for one listing", n)
+	}
+	if st := c.Stats(); st.Hits == 0 || st.Misses == 0 {
+		t.Errorf("stats: %+v", st)
+	}
+
+	// writes pass through and drop the entry
+	obj, err := c9.Open("/temp", warp9.OWRITE|warp9.OTRUNC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := obj.Write([]byte("22")); err != nil {
+		t.Fatal(err)
+	}
+	obj.Close()
+	if s := get(c9b, "/temp"); s != "22" {
+		t.Errorf("after write: %q", s)
+	}
+
+	// a change made upstream is seen once the entry is checked again
+	direct, err := warp9.Mount("tcp", addr, "", 8192, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer direct.Unmount()
+	if obj, err = direct.Open("/temp", warp9.OWRITE|warp9.OTRUNC); err != nil {
+		t.Fatal(err)
+	}
+	obj.Write([]byte("23"))
+	obj.Close()
+	if s := get(c9, "/temp"); s != "22" {
+		t.Errorf("within the ttl: %q", s)
+	}
+	c.SetTTL(0)
+	if s := get(c9, "/temp"); s != "23" {
+		t.Errorf("after the ttl: %q", s)
+	}
+}
+
+// a directory creating objects in memory; like most, its version does
+// not change with its entries
+type memDir struct {
+	wkit.Directory
+}
+
+func (d *memDir) Walk(names []string) (wkit.Item, error) {
+	if len(names) == 0 {
+		return d, nil
+	}
+	return d.Directory.Walk(names)
+}
+
+func (d *memDir) Create(name string, perm uint32, mode uint8) (wkit.Item, error) {
+	item := wkit.NewItem(name)
+	item.SetMode(perm)
+	d.AddItem(item)
+	return item, nil
+}
+
+func TestCacheUnversioned(t *testing.T) {
+	root := &memDir{tree("/", map[string]string{"temp": "21"})}
+	_, addr := serveUpstream(t, "device", root)
+	mt, err := wkit.MountPointDial("tcp", addr, "", 0, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mt.Unmount()
+	c := NewCache("cache", 0, mt, time.Hour, 1024)
+	if !c.Start() {
+		t.Fatal("unable to start cache")
+	}
+	c9 := mountInterposer(t, c.ServerController)
+	names := func() string {
+		dirs, err := c9.ReadDir("/")
+		if err != nil {
+			return err.Error()
+		}
+		var s []string
+		for _, d := range dirs {
+			s = append(s, d.Name)
+		}
+		sort.Strings(s)
+		return strings.Join(s, " ")
+	}
+	if got := names(); got != "temp" {
+		t.Fatalf("listing: %q", got)
+	}
+	if s := get(c9, "/temp"); s != "21" {
+		t.Fatalf("temp: %q", s)
+	}
+
+	// another client creates an object, and the object changes without
+	// a new version
+	direct, err := warp9.Mount("tcp", addr, "", 8192, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer direct.Unmount()
+	obj, err := direct.Create("/new", 0644, warp9.OWRITE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj.Close()
+	root.Children()["temp"].(*wkit.OneItem).SetBuffer([]byte("22"))
+	if got := names(); got != "temp" {
+		t.Errorf("listing within the ttl: %q", got)
+	}
+
+	c.SetTTL(0)
+	if got := names(); got != "new temp" {
+		t.Errorf("listing after the ttl: %q", got)
+	}
+	if s := get(c9, "/temp"); s != "22" {
+		t.Errorf("unversioned object after the ttl: %q", s)
+	}
+
+	// objects created or walked to are held by their directory
+	top, err := c.GetRoot().Walked()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := top.IsDirectory()
+	item, err := dir.(wkit.Creator).Create("made", 0644, warp9.OWRITE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := item.Parent(); p != dir {
+		t.Errorf("created parent: %v", p)
+	}
+	item.Clunk()
+	if item, err = c.GetRoot().IsDirectory().Walk([]string{"made"}); err != nil {
+		t.Fatal(err)
+	}
+	if p := item.Parent(); p != c.GetRoot() {
+		t.Errorf("walked parent: %v", p)
+	}
+}
@@ -0,0 +1,258 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package interpose
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Conversion between JSON and CBOR (RFC 8949) for the values both can
+// hold. Byte strings become base64 JSON strings, map keys that are not
+// strings become their printed form and tags are dropped; indefinite
+// lengths are not supported.
+
+// the JSON text of the CBOR item in b
+func cborToJSON(b []byte) ([]byte, error) {
+	v, rest, err := cborDecode(b, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("cbor: %d bytes after the item", len(rest))
+	}
+	return json.Marshal(v)
+}
+
+// the CBOR item of the JSON text in b
+func jsonToCBOR(b []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := cborEncode(&out, v); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// the argument of the item head in b, and what follows it
+func cborHead(b []byte) (major byte, arg uint64, rest []byte, err error) {
+	if len(b) == 0 {
+		return 0, 0, nil, fmt.Errorf("cbor: short item")
+	}
+	major, info := b[0]>>5, b[0]&31
+	b = b[1:]
+	switch {
+	case info < 24:
+		return major, uint64(info), b, nil
+	case info <= 27:
+		n := 1 << (info - 24)
+		if len(b) < n {
+			return 0, 0, nil, fmt.Errorf("cbor: short item")
+		}
+		for _, c := range b[:n] {
+			arg = arg<<8 | uint64(c)
+		}
+		return major, arg, b[n:], nil
+	case info == 31:
+		return 0, 0, nil, fmt.Errorf("cbor: indefinite lengths not supported")
+	}
+	return 0, 0, nil, fmt.Errorf("cbor: bad item head %#x", major<<5|info)
+}
+
+// nesting allowed, against stack exhaustion by hostile input
+const cborMaxDepth = 512
+
+func cborDecode(b []byte, depth int) (interface{}, []byte, error) {
+	if depth > cborMaxDepth {
+		return nil, nil, fmt.Errorf("cbor: nested too deeply")
+	}
+	info := byte(0)
+	if len(b) > 0 {
+		info = b[0] & 31
+	}
+	major, arg, b, err := cborHead(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch major {
+	case 0:
+		return arg, b, nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, nil, fmt.Errorf("cbor: negative integer out of range")
+		}
+		return -1 - int64(arg), b, nil
+	case 2, 3:
+		if arg > uint64(len(b)) {
+			return nil, nil, fmt.Errorf("cbor: short string")
+		}
+		if major == 2 {
+			return append([]byte(nil), b[:arg]...), b[arg:], nil
+		}
+		return string(b[:arg]), b[arg:], nil
+	case 4:
+		if arg > uint64(len(b)) {
+			return nil, nil, fmt.Errorf("cbor: short array")
+		}
+		a := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			var v interface{}
+			if v, b, err = cborDecode(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+			a = append(a, v)
+		}
+		return a, b, nil
+	case 5:
+		if arg > uint64(len(b)) {
+			return nil, nil, fmt.Errorf("cbor: short map")
+		}
+		m := make(map[string]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			var k, v interface{}
+			if k, b, err = cborDecode(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+			if v, b, err = cborDecode(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+			ks, ok := k.(string)
+			if !ok {
+				ks = fmt.Sprint(k)
+			}
+			m[ks] = v
+		}
+		return m, b, nil
+	case 6:
+		return cborDecode(b, depth+1)
+	}
+
+	// major type 7: simple values and floats
+	switch info {
+	case 20:
+		return false, b, nil
+	case 21:
+		return true, b, nil
+	case 22, 23:
+		return nil, b, nil
+	case 25:
+		return halfFloat(uint16(arg)), b, nil
+	case 26:
+		return float64(math.Float32frombits(uint32(arg))), b, nil
+	case 27:
+		return math.Float64frombits(arg), b, nil
+	}
+	return nil, nil, fmt.Errorf("cbor: unsupported simple value %d", arg)
+}
+
+// the value of an IEEE 754 half precision float
+func halfFloat(h uint16) float64 {
+	exp, mant := int(h>>10&0x1f), float64(h&0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		return -f
+	}
+	return f
+}
+
+func cborPutHead(out *bytes.Buffer, major byte, n uint64) {
+	major <<= 5
+	switch {
+	case n < 24:
+		out.WriteByte(major | byte(n))
+	case n <= math.MaxUint8:
+		out.Write([]byte{major | 24, byte(n)})
+	case n <= math.MaxUint16:
+		out.WriteByte(major | 25)
+		binary.Write(out, binary.BigEndian, uint16(n))
+	case n <= math.MaxUint32:
+		out.WriteByte(major | 26)
+		binary.Write(out, binary.BigEndian, uint32(n))
+	default:
+		out.WriteByte(major | 27)
+		binary.Write(out, binary.BigEndian, n)
+	}
+}
+
+// write v, as decoded from JSON with UseNumber, as CBOR
+func cborEncode(out *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		out.WriteByte(0xf6)
+	case bool:
+		if v {
+			out.WriteByte(0xf5)
+		} else {
+			out.WriteByte(0xf4)
+		}
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			if i >= 0 {
+				cborPutHead(out, 0, uint64(i))
+			} else {
+				cborPutHead(out, 1, uint64(-1-i))
+			}
+			return nil
+		}
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			cborPutHead(out, 0, u)
+			return nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		out.WriteByte(0xfb)
+		binary.Write(out, binary.BigEndian, math.Float64bits(f))
+	case string:
+		cborPutHead(out, 3, uint64(len(v)))
+		out.WriteString(v)
+	case []interface{}:
+		cborPutHead(out, 4, uint64(len(v)))
+		for _, e := range v {
+			if err := cborEncode(out, e); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		cborPutHead(out, 5, uint64(len(v)))
+		for _, k := range keys {
+			cborPutHead(out, 3, uint64(len(k)))
+			out.WriteString(k)
+			if err := cborEncode(out, v[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cbor: cannot encode %T", v)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package interpose
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+
+	"github.com/lavaorg/warp/warp9"
+)
+
+// A Transformer converts the contents of objects between the form held
+// by the upstream server and the form the clients of a Transform see.
+type Transformer interface {
+	// Decode returns what clients read, given the upstream contents.
+	Decode(data []byte) ([]byte, error)
+	// Encode returns the upstream contents, given what a client wrote.
+	// A transformer that cannot be reversed returns Eperm.
+	Encode(data []byte) ([]byte, error)
+}
+
+// TransformFuncs makes a Transformer of two functions; a nil Encode
+// makes the objects read only.
+type TransformFuncs struct {
+	DecodeFunc func([]byte) ([]byte, error)
+	EncodeFunc func([]byte) ([]byte, error)
+}
+
+func (tf TransformFuncs) Decode(data []byte) ([]byte, error) {
+	return tf.DecodeFunc(data)
+}
+
+func (tf TransformFuncs) Encode(data []byte) ([]byte, error) {
+	if tf.EncodeFunc == nil {
+		return nil, warp9.ErrorCode(warp9.Eperm)
+	}
+	return tf.EncodeFunc(data)
+}
+
+// Reverse returns the transformer doing the opposite of x.
+func Reverse(x Transformer) Transformer {
+	return TransformFuncs{DecodeFunc: x.Encode, EncodeFunc: x.Decode}
+}
+
+// CBORToJSON shows CBOR objects to clients as JSON. Reverse(CBORToJSON)
+// shows JSON objects as CBOR.
+var CBORToJSON Transformer = TransformFuncs{DecodeFunc: cborToJSON, EncodeFunc: jsonToCBOR}
+
+// Gzip shows objects to clients gzip compressed; what clients write is
+// uncompressed on the way up.
+var Gzip Transformer = TransformFuncs{DecodeFunc: gzipData, EncodeFunc: gunzipData}
+
+func gzipData(data []byte) ([]byte, error) {
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func gunzipData(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// Redact returns a transformer removing the named fields, at any depth,
+// from JSON objects. The objects become read only.
+func Redact(fields ...string) Transformer {
+	drop := make(map[string]bool)
+	for _, f := range fields {
+		drop[f] = true
+	}
+	return TransformFuncs{DecodeFunc: func(data []byte) ([]byte, error) {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		return json.Marshal(redact(v, drop))
+	}}
+}
+
+func redact(v interface{}, drop map[string]bool) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if drop[k] {
+				delete(v, k)
+			} else {
+				v[k] = redact(e, drop)
+			}
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = redact(e, drop)
+		}
+	}
+	return v
+}
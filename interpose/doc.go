@@ -0,0 +1,29 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+/*
+Interpose holds ready to run interposers: object servers that mount one or
+more upstream servers and serve the same tree downstream, changing how it
+is reached without the clients or the upstream servers knowing.
+
+	Cache         serves stats, directory listings and read data from a cache
+	Balancer      spreads sessions over replicas of a server
+	Failover      keeps a tree on a primary and secondaries, failing over reads
+	Filter        hides paths and makes them read only, per user and group
+	Transform     converts object contents, e.g. CBOR to JSON, on the way through
+	Auditor       records who read or wrote what, to a log and as events
+	FaultInjector injects latency, errors, drops and resets, for testing
+
+An interposer is a wkit.ServerController; start it and serve it like any
+other server:
+
+	mt, err := wkit.MountPointDial("tcp", "device:9090", "", 0, user)
+	c := interpose.NewCache("cache", 0, mt, 5*time.Second, 64*1024)
+	c.Start()
+	c.StartNetListener("tcp", ":9090")
+
+The interposers reach the upstream servers as the user of their mounts;
+the permissions of the upstream servers apply to that user, not to the
+downstream clients.
+*/
+package interpose
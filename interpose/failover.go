@@ -0,0 +1,503 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package interpose
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/lavaorg/warp/warp9"
+	"github.com/lavaorg/warp/wkit"
+)
+
+// MaxDivergences is the number of divergences a Failover keeps.
+const MaxDivergences = 64
+
+// A Failover is an interposer serving one tree kept on several servers:
+// a primary and its secondaries, members 0, 1, ... in the order given.
+//
+// Writes, creates, removes and wstats go to every member; the request
+// succeeds when Quorum of them do. Reads, stats and walks go to the
+// primary and fail over to the next member when it errors; a fid stays on
+// the member that answered it.
+//
+// A member that fails a change that others made has diverged. The last
+// MaxDivergences divergences are kept and reported, with the state of the
+// members, by the object status in the tree attached with the aname
+// "status". They are not repaired; the members must be brought back in
+// step by other means.
+type Failover struct {
+	*wkit.ServerController
+	Quorum int // members that must make a change; 0 is all of them
+
+	members []*wkit.MountPoint
+	mu      sync.Mutex
+	diverge []Divergence
+	count   uint64 // divergences seen
+}
+
+// A Divergence is a change made on some members that failed on Member.
+type Divergence struct {
+	Time   time.Time
+	Member int
+	Op     string
+	Path   string
+	Err    error
+}
+
+// members left out of a change, neither making nor failing it
+var errNotOpen = errors.New("not open on the member")
+
+// NewFailover returns a failover interposer over primary and secondaries
+// requiring quorum members to make each change.
+func NewFailover(id string, debuglevel int, primary *wkit.MountPoint, secondaries []*wkit.MountPoint, quorum int) *Failover {
+	f := &Failover{
+		Quorum:  quorum,
+		members: append([]*wkit.MountPoint{primary}, secondaries...),
+	}
+	root := f.item(*primary.GetDir(), "/", nil, nil)
+	f.ServerController = wkit.NewServer(id, debuglevel, root)
+	status := wkit.NewDirItem("status")
+	status.AddItem(f.StatusItem("status"))
+	if err := f.AddTree("status", status, nil); err != nil {
+		warp9.Error("failover: status not served: %v", err)
+	}
+	return f
+}
+
+// Start starts the warp9 server of the failover.
+func (f *Failover) Start() bool {
+	return f.ServerController.Start(f.ServerController)
+}
+
+// Divergences returns the divergences kept, oldest first.
+func (f *Failover) Divergences() []Divergence {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Divergence(nil), f.diverge...)
+}
+
+// StatusItem returns an object reporting the quorum, a line per member
+// as given by wkit.MountPoint.StatusItem, and the divergences kept:
+//
+//	quorum q of n, d divergences
+//	member net!addr state since [error]
+//	time member op path error
+func (f *Failover) StatusItem(name string) *wkit.BytesItem {
+	return wkit.NewBytesItem(name, failoverStatus{f})
+}
+
+type failoverStatus struct {
+	f *Failover
+}
+
+func (fs failoverStatus) Bytes() []byte {
+	f := fs.f
+	var b bytes.Buffer
+	f.mu.Lock()
+	fmt.Fprintf(&b, "quorum %d of %d, %d divergences\n", f.quorum(), len(f.members), f.count)
+	f.mu.Unlock()
+	buf := make([]byte, 1024)
+	for i, mt := range f.members {
+		n, err := mt.StatusItem("").Read(buf, 0, uint32(len(buf)))
+		if err != nil {
+			fmt.Fprintf(&b, "%d %q\n", i, err.Error())
+			continue
+		}
+		fmt.Fprintf(&b, "%d %s", i, buf[:n])
+	}
+	for _, d := range f.Divergences() {
+		fmt.Fprintf(&b, "%s %d %s %s %q\n", d.Time.UTC().Format(time.RFC3339), d.Member, d.Op, d.Path, d.Err.Error())
+	}
+	return b.Bytes()
+}
+
+// the members needed for a change
+func (f *Failover) quorum() int {
+	if f.Quorum <= 0 || f.Quorum > len(f.members) {
+		return len(f.members)
+	}
+	return f.Quorum
+}
+
+// record that member i failed op on p
+func (f *Failover) diverged(i int, op, p string, err error) {
+	warp9.Error("failover: member %d diverged: %s %s: %v", i, op, p, err)
+	f.mu.Lock()
+	f.diverge = append(f.diverge, Divergence{Time: time.Now(), Member: i, Op: op, Path: p, Err: err})
+	if len(f.diverge) > MaxDivergences {
+		f.diverge = f.diverge[len(f.diverge)-MaxDivergences:]
+	}
+	f.count++
+	f.mu.Unlock()
+}
+
+// make a change with fn on every member. It is made when a quorum of
+// them succeed; otherwise the first error, the primary's first, is
+// returned. Members failing while others succeed have diverged.
+func (f *Failover) fanout(op, p string, fn func(i int) error) error {
+	errs := make([]error, len(f.members))
+	ok := 0
+	for i := range f.members {
+		if errs[i] = fn(i); errs[i] == nil {
+			ok++
+		}
+	}
+	var first error
+	for i, err := range errs {
+		if err == nil || err == errNotOpen {
+			continue
+		}
+		if ok > 0 {
+			f.diverged(i, op, p, err)
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	if ok >= f.quorum() {
+		return nil
+	}
+	if first == nil {
+		first = warp9.ErrorMsg(warp9.Eio, "no quorum")
+	}
+	return first
+}
+
+// walk member i to p
+func (f *Failover) walk(i int, p string) (wkit.Item, error) {
+	return f.members[i].Walk(splitNames(p))
+}
+
+// a new fid's view of p, held by parent and in a tree placed under top
+func (f *Failover) item(d warp9.Dir, p string, parent, top wkit.Directory) *failItem {
+	return &failItem{
+		node: node{Dir: d, path: p, parent: parent, top: top},
+		f:    f,
+		ups:  make([]wkit.Item, len(f.members)),
+		open: make([]bool, len(f.members)),
+	}
+}
+
+// A failItem is a fid's view of an object on the members. The object is
+// walked to on each member when first needed.
+type failItem struct {
+	node
+	f      *Failover
+	ups    []wkit.Item // the object on each member, once needed
+	cur    int         // the member reads go to
+	opened bool
+	mode   byte
+	write  bool   // opened for writing, on the members in open
+	open   []bool // members the object is open on
+}
+
+// the object on member i, walked to and opened as the fid is
+func (fi *failItem) member(i int) (wkit.Item, error) {
+	if fi.ups[i] == nil {
+		up, err := fi.f.walk(i, fi.path)
+		if err != nil {
+			return nil, err
+		}
+		fi.ups[i] = up
+	}
+	if fi.opened && !fi.open[i] {
+		if fi.write {
+			return nil, errNotOpen
+		}
+		if _, err := fi.ups[i].Open(fi.mode); err != nil {
+			return nil, err
+		}
+		fi.open[i] = true
+	}
+	return fi.ups[i], nil
+}
+
+// run fn on the member reads go to, failing over to the next ones while
+// it errors; the first error is returned if every member fails
+func (fi *failItem) read(fn func(up wkit.Item) error) error {
+	var first error
+	for i := fi.cur; i < len(fi.ups); i++ {
+		up, err := fi.member(i)
+		if err == nil {
+			if err = fn(up); err == nil {
+				if i != fi.cur {
+					warp9.Info("failover: %s: member %d answers for %d", fi.path, i, fi.cur)
+				}
+				fi.cur = i
+				return nil
+			}
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (fi *failItem) GetItem() wkit.Item {
+	return fi
+}
+
+func (fi *failItem) IsDirectory() wkit.Directory {
+	if fi.Qid.Type&warp9.QTDIR == 0 {
+		return nil
+	}
+	return fi
+}
+
+// Walked returns a copy for the new fid.
+func (fi *failItem) Walked() (wkit.Item, error) {
+	ni := fi.f.item(fi.Dir, fi.path, fi.parent, fi.top)
+	ni.cur = fi.cur
+	return ni, nil
+}
+
+// Open opens the object on the member reads go to or, to change it, on
+// every member.
+func (fi *failItem) Open(mode byte) (uint32, error) {
+	if mode&3 == warp9.OREAD && mode&(warp9.OTRUNC|warp9.ORCLOSE) == 0 {
+		var iounit uint32
+		err := fi.read(func(up wkit.Item) (err error) {
+			iounit, err = up.Open(mode)
+			return err
+		})
+		if err != nil {
+			return 0, err
+		}
+		fi.open[fi.cur] = true
+		fi.opened, fi.mode = true, mode
+		return iounit, nil
+	}
+
+	var iounit uint32
+	err := fi.f.fanout("open", fi.path, func(i int) error {
+		up, err := fi.member(i)
+		if err != nil {
+			return err
+		}
+		n, err := up.Open(mode)
+		if err != nil {
+			return err
+		}
+		if iounit == 0 || n != 0 && n < iounit {
+			iounit = n
+		}
+		fi.open[i] = true
+		return nil
+	})
+	if err != nil {
+		fi.closeAll()
+		return 0, err
+	}
+	fi.opened, fi.mode, fi.write = true, mode, true
+	for i := range fi.open {
+		if fi.open[i] {
+			fi.cur = i
+			break
+		}
+	}
+	return iounit, nil
+}
+
+func (fi *failItem) Read(obuf []byte, off uint64, rcount uint32) (uint32, error) {
+	var n uint32
+	err := fi.read(func(up wkit.Item) (err error) {
+		n, err = up.Read(obuf, off, rcount)
+		return err
+	})
+	return n, err
+}
+
+// Write writes to every member the object is open on.
+func (fi *failItem) Write(ibuf []byte, off uint64, count uint32) (uint32, error) {
+	if !fi.write {
+		return 0, warp9.ErrorCode(warp9.Ebaduse)
+	}
+	var n uint32
+	var done bool
+	err := fi.f.fanout("write", fi.path, func(i int) error {
+		up, err := fi.member(i)
+		if err != nil {
+			return err
+		}
+		w, err := up.Write(ibuf, off, count)
+		if err != nil {
+			return err
+		}
+		if !done {
+			n, done = w, true
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// release the object on every member
+func (fi *failItem) closeAll() error {
+	var first error
+	for i, up := range fi.ups {
+		if up == nil {
+			continue
+		}
+		if err := up.Clunk(); err != nil && first == nil {
+			first = err
+		}
+		fi.ups[i], fi.open[i] = nil, false
+	}
+	return first
+}
+
+// Clunk releases the object on the members; errors from members that
+// have gone away are not reported.
+func (fi *failItem) Clunk() error {
+	fi.closeAll()
+	return nil
+}
+
+// Remove removes the object from every member.
+func (fi *failItem) Remove() error {
+	err := fi.f.fanout("remove", fi.path, func(i int) error {
+		up := fi.ups[i]
+		if up == nil {
+			var err error
+			if up, err = fi.f.walk(i, fi.path); err != nil {
+				return err
+			}
+		}
+		fi.ups[i] = nil
+		return up.Remove()
+	})
+	fi.closeAll()
+	return err
+}
+
+// Stat returns the object's Dir from the member reads go to.
+func (fi *failItem) Stat() (*warp9.Dir, error) {
+	var d *warp9.Dir
+	err := fi.read(func(up wkit.Item) (err error) {
+		d, err = up.Stat()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if fi.path == "/" {
+		d.Name = fi.Dir.Name
+	}
+	fi.Dir = *d
+	return d, nil
+}
+
+// WStat changes the object's Dir on every member.
+func (fi *failItem) WStat(dir *warp9.Dir) error {
+	return fi.f.fanout("wstat", fi.path, func(i int) error {
+		up, err := fi.member(i)
+		if err == errNotOpen {
+			up, err = fi.f.walk(i, fi.path)
+			if err != nil {
+				return err
+			}
+			defer up.Clunk()
+		}
+		if err != nil {
+			return err
+		}
+		return up.WStat(dir)
+	})
+}
+
+//
+// Directory interface
+//
+
+// Walk resolves the names on the member reads go to, failing over to the
+// next ones while it errors.
+func (fi *failItem) Walk(names []string) (wkit.Item, error) {
+	p, rest, above := fi.follow(names)
+	if above {
+		// ".." above the members' root leaves them
+		return fi.walkTop(rest)
+	}
+	parent := fi.parentOf(fi, p)
+	var first error
+	for i := fi.cur; i < len(fi.ups); i++ {
+		up, err := fi.f.walk(i, p)
+		if err == nil {
+			var d *warp9.Dir
+			if d, err = up.Stat(); err == nil {
+				if p == "/" {
+					d.Name = fi.f.members[0].Name()
+				}
+				ni := fi.f.item(*d, p, parent, fi.top)
+				ni.ups[i], ni.cur = up, i
+				return ni, nil
+			}
+			up.Clunk()
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	return nil, first
+}
+
+func (fi *failItem) AddDirectory(d wkit.Directory) {}
+
+func (fi *failItem) AddItem(item wkit.Item) {}
+
+// Children returns nil; the directory is listed by reading it.
+func (fi *failItem) Children() map[string]wkit.Item {
+	return nil
+}
+
+func (fi *failItem) RemoveItem(item wkit.Item) error {
+	p := path.Join(fi.path, item.GetDir().Name)
+	return fi.f.fanout("remove", p, func(i int) error {
+		up, err := fi.f.walk(i, p)
+		if err != nil {
+			return err
+		}
+		return up.Remove()
+	})
+}
+
+// Create creates the object on every member; the new object is open on
+// the members that created it.
+func (fi *failItem) Create(name string, perm uint32, mode uint8) (wkit.Item, error) {
+	p := path.Join(fi.path, name)
+	ni := fi.f.item(fi.Dir, p, fi, fi.top)
+	err := fi.f.fanout("create", p, func(i int) error {
+		up, err := fi.member(i)
+		if err != nil {
+			return err
+		}
+		cr, ok := up.(wkit.Creator)
+		if !ok {
+			return warp9.ErrorCode(warp9.Eperm)
+		}
+		item, err := cr.Create(name, perm, mode)
+		if err != nil {
+			return err
+		}
+		if !ni.opened {
+			ni.Dir, ni.cur = *item.GetDir(), i
+		}
+		ni.ups[i], ni.open[i], ni.opened = item, true, true
+		return nil
+	})
+	if err != nil {
+		ni.closeAll()
+		return nil, err
+	}
+	ni.mode, ni.write = mode, true
+	return ni, nil
+}
@@ -0,0 +1,134 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package interpose
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lavaorg/warp/warp9"
+	"github.com/lavaorg/warp/wkit"
+)
+
+func TestFailover(t *testing.T) {
+	var dirs []string
+	var mts []*wkit.MountPoint
+	for _, id := range []string{"primary", "second", "third"} {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "conf"), []byte("a"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		exp, err := wkit.NewExportDir("/", dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, addr := serveUpstream(t, id, exp)
+		mt, err := wkit.MountPointDial("tcp", addr, "", 0, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer mt.Unmount()
+		dirs = append(dirs, dir)
+		mts = append(mts, mt)
+	}
+	f := NewFailover("failover", 0, mts[0], mts[1:], 2)
+	if !f.Start() {
+		t.Fatal("unable to start failover")
+	}
+	c9 := mountInterposer(t, f.ServerController)
+
+	put := func(p, data string) error {
+		obj, err := c9.Open(p, warp9.OWRITE|warp9.OTRUNC)
+		if err != nil {
+			return err
+		}
+		defer obj.Close()
+		_, err = obj.Write([]byte(data))
+		return err
+	}
+	onDisk := func(i int, name string) string {
+		data, err := os.ReadFile(filepath.Join(dirs[i], name))
+		if err != nil {
+			return err.Error()
+		}
+		return string(data)
+	}
+
+	// changes go to every member
+	if err := put("/conf", "b"); err != nil {
+		t.Fatal(err)
+	}
+	obj, err := c9.Create("/new", 0644, warp9.OWRITE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj.Write([]byte("n"))
+	obj.Close()
+	for i := range dirs {
+		if s := onDisk(i, "conf"); s != "b" {
+			t.Errorf("member %d conf: %q", i, s)
+		}
+		if s := onDisk(i, "new"); s != "n" {
+			t.Errorf("member %d new: %q", i, s)
+		}
+	}
+	if err := c9.Remove("/new"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dirs[1], "new")); !os.IsNotExist(err) {
+		t.Errorf("remove left new on a secondary: %v", err)
+	}
+	if d := f.Divergences(); len(d) != 0 {
+		t.Errorf("divergences: %v", d)
+	}
+
+	// objects created or walked to are held by their directory
+	root, err := f.GetRoot().Walked()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := root.IsDirectory()
+	item, err := dir.(wkit.Creator).Create("made", 0644, warp9.OWRITE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := item.Parent(); p != dir {
+		t.Errorf("created parent: %v", p)
+	}
+	item.Clunk()
+	if item, err = f.GetRoot().IsDirectory().Walk([]string{"made"}); err != nil {
+		t.Fatal(err)
+	}
+	if p := item.Parent(); p != f.GetRoot() {
+		t.Errorf("walked parent: %v", p)
+	}
+
+	// with a secondary gone a quorum still makes the change
+	mts[2].Unmount()
+	if err := put("/conf", "c"); err != nil {
+		t.Fatal(err)
+	}
+	if s := onDisk(0, "conf") + onDisk(1, "conf") + onDisk(2, "conf"); s != "ccb" {
+		t.Errorf("members: %q", s)
+	}
+	d := f.Divergences()
+	if len(d) == 0 || d[0].Member != 2 || d[0].Path != "/conf" {
+		t.Errorf("divergences: %v", d)
+	}
+	status := mountTree(t, f.ServerController, "status")
+	if s := get(status, "/status"); !strings.HasPrefix(s, "quorum 2 of 3") || !strings.Contains(s, " 2 open /conf ") {
+		t.Errorf("status:\n%s", s)
+	}
+
+	// reads fail over from the primary; without a quorum changes fail
+	mts[0].Unmount()
+	if s := get(c9, "/conf"); s != "c" {
+		t.Errorf("after failover: %q", s)
+	}
+	if err := put("/conf", "d"); err == nil {
+		t.Error("change made without a quorum")
+	}
+}
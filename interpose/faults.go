@@ -0,0 +1,516 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package interpose
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lavaorg/warp/warp9"
+	"github.com/lavaorg/warp/wkit"
+)
+
+// A FaultAction is what a FaultRule does to the requests it applies to.
+type FaultAction int
+
+const (
+	Delay    FaultAction = iota // hold the request for Latency, then serve it
+	Fail                        // respond with the error Code
+	Drop                        // neither serve nor answer the request; it waits for a flush
+	Truncate                    // serve a read of at most Count bytes
+	Reset                       // close the connection
+)
+
+func (a FaultAction) String() string {
+	switch a {
+	case Delay:
+		return "delay"
+	case Fail:
+		return "error"
+	case Drop:
+		return "drop"
+	case Truncate:
+		return "truncate"
+	case Reset:
+		return "reset"
+	}
+	return fmt.Sprintf("FaultAction(%d)", int(a))
+}
+
+// the requests that can be faulted, by name
+var faultOps = map[string]uint8{
+	"attach": warp9.Tattach,
+	"walk":   warp9.Twalk,
+	"open":   warp9.Topen,
+	"create": warp9.Tcreate,
+	"read":   warp9.Tread,
+	"write":  warp9.Twrite,
+	"clunk":  warp9.Tclunk,
+	"remove": warp9.Tremove,
+	"stat":   warp9.Tstat,
+	"wstat":  warp9.Twstat,
+}
+
+// the error codes that can be given by name
+var faultErrors = map[string]int16{
+	"perm":     warp9.Eperm,
+	"notexist": warp9.Enotexist,
+	"exist":    warp9.Eexist,
+	"notdir":   warp9.Enotdir,
+	"notempty": warp9.Enotempty,
+	"inuse":    warp9.Einuse,
+	"toolarge": warp9.Etoolarge,
+	"io":       warp9.Eio,
+	"eof":      warp9.Eeof,
+	"conn":     warp9.Econn,
+}
+
+// A FaultRule injects a fault into some of the requests of the kinds in
+// Ops on the paths matching Glob. The path of an attach is its aname,
+// as /aname; that of a walk the path walked to and of a create the path
+// created.
+type FaultRule struct {
+	Ops     []string // request names, such as read; "*" is every kind
+	Glob    string   // a path.Match pattern, also covering the paths below a match; "*" is every path
+	Prob    float64  // the chance, from 0 to 1, of a matching request being faulted
+	Action  FaultAction
+	Latency time.Duration // of a Delay
+	Code    int16         // error of a Fail
+	Count   uint32        // bytes of a Truncate
+	Hits    uint64        // requests faulted
+}
+
+// ParseFaultRule parses a rule of the form
+//
+//	ops glob prob action [arg]
+//
+// where ops is * or request names separated by commas, and action is one
+// of
+//
+//	delay duration	such as 250ms
+//	error code	a warp9 error code, or perm, notexist, exist, notdir,
+//			notempty, inuse, toolarge, io, eof or conn
+//	drop
+//	truncate count	for reads only
+//	reset
+//
+// For example
+//
+//	read,write /sensors 0.1 delay 2s
+//	* * 0.01 reset
+func ParseFaultRule(s string) (*FaultRule, error) {
+	f := strings.Fields(s)
+	if len(f) < 4 {
+		return nil, fmt.Errorf("usage: ops glob prob action [arg]")
+	}
+	r := &FaultRule{Ops: strings.Split(f[0], ","), Glob: f[1]}
+	for _, op := range r.Ops {
+		if _, ok := faultOps[op]; !ok && op != "*" {
+			return nil, fmt.Errorf("unknown request %q", op)
+		}
+	}
+	if r.Glob != "*" {
+		if !strings.HasPrefix(r.Glob, "/") {
+			return nil, fmt.Errorf("%q is not an absolute path", r.Glob)
+		}
+		if _, err := path.Match(r.Glob, ""); err != nil {
+			return nil, fmt.Errorf("%q: %v", r.Glob, err)
+		}
+	}
+	p, err := strconv.ParseFloat(f[2], 64)
+	if err != nil || p < 0 || p > 1 {
+		return nil, fmt.Errorf("bad probability %q", f[2])
+	}
+	r.Prob = p
+
+	args := f[4:]
+	narg := 0
+	switch f[3] {
+	case "delay":
+		r.Action, narg = Delay, 1
+		if len(args) == 1 {
+			if r.Latency, err = time.ParseDuration(args[0]); err != nil || r.Latency < 0 {
+				return nil, fmt.Errorf("bad duration %q", args[0])
+			}
+		}
+	case "error":
+		r.Action, narg = Fail, 1
+		if len(args) == 1 {
+			code, ok := faultErrors[args[0]]
+			if !ok {
+				n, err := strconv.ParseInt(args[0], 10, 16)
+				if err != nil || n == 0 {
+					return nil, fmt.Errorf("bad error %q", args[0])
+				}
+				code = int16(n)
+			}
+			r.Code = code
+		}
+	case "drop":
+		r.Action = Drop
+	case "truncate":
+		r.Action, narg = Truncate, 1
+		if len(r.Ops) != 1 || r.Ops[0] != "read" {
+			return nil, fmt.Errorf("truncate applies to reads only")
+		}
+		if len(args) == 1 {
+			n, err := strconv.ParseUint(args[0], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("bad count %q", args[0])
+			}
+			r.Count = uint32(n)
+		}
+	case "reset":
+		r.Action = Reset
+	default:
+		return nil, fmt.Errorf("unknown action %q", f[3])
+	}
+	if len(args) != narg {
+		return nil, fmt.Errorf("%s takes %d argument(s)", f[3], narg)
+	}
+	return r, nil
+}
+
+// ParseFaultRules reads rules, one to a line. Blank lines and text after
+// a '#' are ignored.
+func ParseFaultRules(rd io.Reader) ([]*FaultRule, error) {
+	var rules []*FaultRule
+	scan := bufio.NewScanner(rd)
+	for line := 1; scan.Scan(); line++ {
+		text := scan.Text()
+		if n := strings.IndexByte(text, '#'); n >= 0 {
+			text = text[:n]
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		r, err := ParseFaultRule(text)
+		if err != nil {
+			return nil, fmt.Errorf("fault line %d: %v", line, err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, scan.Err()
+}
+
+// String returns the rule in the form ParseFaultRule reads.
+func (r *FaultRule) String() string {
+	s := fmt.Sprintf("%s %s %s %s", strings.Join(r.Ops, ","), r.Glob,
+		strconv.FormatFloat(r.Prob, 'g', -1, 64), r.Action)
+	switch r.Action {
+	case Delay:
+		s += " " + r.Latency.String()
+	case Fail:
+		for name, code := range faultErrors {
+			if code == r.Code {
+				return s + " " + name
+			}
+		}
+		s += " " + strconv.Itoa(int(r.Code))
+	case Truncate:
+		s += " " + strconv.FormatUint(uint64(r.Count), 10)
+	}
+	return s
+}
+
+// true if the rule covers the request of type t on the path p
+func (r *FaultRule) matches(t uint8, p string) bool {
+	op := false
+	for _, o := range r.Ops {
+		if o == "*" || faultOps[o] == t {
+			op = true
+			break
+		}
+	}
+	return op && (r.Glob == "*" || globCovers(r.Glob, p))
+}
+
+// Faults injects faults into the requests served by a warp9 server,
+// following its rules: the first rule covering a request, and whose
+// chance comes up, faults it. The chances are drawn from a generator
+// seeded with Seed, so a client making the same requests in the same
+// order meets the same faults.
+//
+// Faults is a request interceptor, as Audit is, for a server whose ops
+// embed it; they must also pass flushes on, so dropped requests can be
+// flushed, and closed connections, so the requests dropped on them are
+// forgotten. Both the ServerController and Faults have a ConnClosed, so
+// the ops must define their own calling the two:
+//
+//	type faulty struct {
+//		*wkit.ServerController
+//		*interpose.Faults
+//	}
+//
+//	func (f faulty) Flush(req *warp9.SrvReq) { f.Faults.Flush(req) }
+//
+//	func (f faulty) ConnClosed(conn *warp9.Conn) {
+//		f.ServerController.ConnClosed(conn)
+//		f.Faults.ConnClosed(conn)
+//	}
+//
+//	srv.Start(faulty{srv, faults})
+//
+// The rules are changed at run time by writing commands to Ctl, an
+// object to place in a tree:
+//
+//	add rule	add a rule, in the form ParseFaultRule reads
+//	del n		remove the nth rule, counting from 1
+//	clear		remove all rules
+//	seed n		seed the chances with n
+//	rules		list the rules and their hits; read Ctl for the list
+//
+// Requests on Ctl itself are never faulted.
+type Faults struct {
+	Ctl *wkit.Command
+
+	mu      sync.Mutex
+	rules   []*FaultRule
+	rng     *rand.Rand
+	dropped map[*warp9.SrvReq]bool
+}
+
+// NewFaults returns a fault injector without rules, its chances seeded
+// with seed.
+func NewFaults(seed int64) *Faults {
+	f := &Faults{
+		rng:     rand.New(rand.NewSource(seed)),
+		dropped: make(map[*warp9.SrvReq]bool),
+	}
+	f.Ctl = wkit.NewCommand("ctl", map[string]wkit.CommandFct{
+		"add":   faultsAdd,
+		"del":   faultsDel,
+		"clear": faultsClear,
+		"seed":  faultsSeed,
+		"rules": faultsRules,
+	}, f)
+	return f
+}
+
+// Add appends rules.
+func (f *Faults) Add(rules ...*FaultRule) {
+	f.mu.Lock()
+	f.rules = append(f.rules, rules...)
+	f.mu.Unlock()
+}
+
+// Clear removes all rules.
+func (f *Faults) Clear() {
+	f.mu.Lock()
+	f.rules = nil
+	f.mu.Unlock()
+}
+
+// Seed restarts the chances from seed.
+func (f *Faults) Seed(seed int64) {
+	f.mu.Lock()
+	f.rng.Seed(seed)
+	f.mu.Unlock()
+}
+
+// Rules returns a copy of the rules.
+func (f *Faults) Rules() []FaultRule {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rules := make([]FaultRule, len(f.rules))
+	for i, r := range f.rules {
+		rules[i] = *r
+	}
+	return rules
+}
+
+// the path of the request, and false if it is not to be faulted
+func (f *Faults) reqPath(req *warp9.SrvReq) (string, bool) {
+	tc := req.Tc
+	faultable := false
+	for _, t := range faultOps {
+		faultable = faultable || t == tc.Type
+	}
+	if !faultable {
+		return "", false
+	}
+	if tc.Type == warp9.Tattach {
+		return path.Clean("/" + tc.Aname), true
+	}
+	fid := req.Conn.FidGet(tc.Fid)
+	if fid == nil {
+		return "", false
+	}
+	defer fid.DecRef()
+	if item, ok := fid.Aux.(*wkit.Command); ok && item == f.Ctl {
+		return "", false
+	}
+	switch tc.Type {
+	case warp9.Twalk:
+		return path.Join(append([]string{fid.Path}, tc.Wname...)...), true
+	case warp9.Tcreate:
+		return path.Join(fid.Path, tc.Name), true
+	}
+	return fid.Path, true
+}
+
+// the rule faulting the request, nil for none
+func (f *Faults) fault(req *warp9.SrvReq) *FaultRule {
+	p, ok := f.reqPath(req)
+	if !ok {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, r := range f.rules {
+		if r.matches(req.Tc.Type, p) && f.rng.Float64() < r.Prob {
+			r.Hits++
+			if r.Action == Drop {
+				f.dropped[req] = true
+			}
+			return r
+		}
+	}
+	return nil
+}
+
+// SrvReqProcess faults the request, if a rule says so, and processes it.
+func (f *Faults) SrvReqProcess(req *warp9.SrvReq) {
+	r := f.fault(req)
+	if r == nil {
+		req.Process()
+		return
+	}
+	switch r.Action {
+	case Delay:
+		time.Sleep(r.Latency)
+		req.Process()
+	case Fail:
+		req.RespondError(warp9.ErrorCode(r.Code))
+	case Drop:
+	case Truncate:
+		if req.Tc.Count > r.Count {
+			req.Tc.Count = r.Count
+		}
+		req.Process()
+	case Reset:
+		f.ConnClosed(req.Conn)
+		req.Conn.Close()
+	}
+}
+
+// SrvReqRespond post processes the request.
+func (f *Faults) SrvReqRespond(req *warp9.SrvReq) {
+	req.PostProcess()
+}
+
+// Flush answers a dropped request being flushed.
+func (f *Faults) Flush(req *warp9.SrvReq) {
+	f.mu.Lock()
+	dropped := f.dropped[req]
+	delete(f.dropped, req)
+	f.mu.Unlock()
+	if dropped {
+		req.Flush()
+	}
+}
+
+// ConnClosed forgets the requests dropped on conn; they will not be
+// flushed.
+func (f *Faults) ConnClosed(conn *warp9.Conn) {
+	f.mu.Lock()
+	for d := range f.dropped {
+		if d.Conn == conn {
+			delete(f.dropped, d)
+		}
+	}
+	f.mu.Unlock()
+}
+
+//
+// ctl object
+//
+
+func faultsAdd(ctx wkit.CmdCtx, cmd *wkit.Command, name string, args []byte) error {
+	r, err := ParseFaultRule(string(args))
+	if err != nil {
+		return warp9.ErrorMsg(warp9.Einval, err.Error())
+	}
+	ctx.(*Faults).Add(r)
+	cmd.SetBuffer([]byte("ok\n"))
+	return nil
+}
+
+func faultsDel(ctx wkit.CmdCtx, cmd *wkit.Command, name string, args []byte) error {
+	f := ctx.(*Faults)
+	n, err := strconv.Atoi(strings.TrimSpace(string(args)))
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err != nil || n < 1 || n > len(f.rules) {
+		return warp9.ErrorMsg(warp9.Einval, "usage: del n")
+	}
+	f.rules = append(f.rules[:n-1:n-1], f.rules[n:]...)
+	cmd.SetBuffer([]byte("ok\n"))
+	return nil
+}
+
+func faultsClear(ctx wkit.CmdCtx, cmd *wkit.Command, name string, args []byte) error {
+	ctx.(*Faults).Clear()
+	cmd.SetBuffer([]byte("ok\n"))
+	return nil
+}
+
+func faultsSeed(ctx wkit.CmdCtx, cmd *wkit.Command, name string, args []byte) error {
+	seed, err := strconv.ParseInt(strings.TrimSpace(string(args)), 10, 64)
+	if err != nil {
+		return warp9.ErrorMsg(warp9.Einval, "usage: seed n")
+	}
+	ctx.(*Faults).Seed(seed)
+	cmd.SetBuffer([]byte("ok\n"))
+	return nil
+}
+
+func faultsRules(ctx wkit.CmdCtx, cmd *wkit.Command, name string, args []byte) error {
+	var b bytes.Buffer
+	for i, r := range ctx.(*Faults).Rules() {
+		fmt.Fprintf(&b, "%d %s # %d hits\n", i+1, &r, r.Hits)
+	}
+	cmd.SetBuffer(b.Bytes())
+	return nil
+}
+
+// A FaultInjector is an interposer serving the tree of an upstream mount
+// with faults injected. The Faults' Ctl is in the tree attached with the
+// aname "faults".
+type FaultInjector struct {
+	*Proxy
+	*Faults
+}
+
+// NewFaultInjector returns an interposer serving the tree of up with the
+// faults of faults.
+func NewFaultInjector(id string, debuglevel int, up wkit.Directory, faults *Faults) *FaultInjector {
+	fi := &FaultInjector{Proxy: NewProxy(id, debuglevel, up), Faults: faults}
+	tree := wkit.NewDirItem("faults")
+	tree.AddItem(faults.Ctl)
+	if err := fi.AddTree("faults", tree, nil); err != nil {
+		warp9.Error("faults: ctl not served: %v", err)
+	}
+	return fi
+}
+
+// ConnClosed discards the connection's state in the server and in the
+// faults.
+func (fi *FaultInjector) ConnClosed(conn *warp9.Conn) {
+	fi.ServerController.ConnClosed(conn)
+	fi.Faults.ConnClosed(conn)
+}
+
+// Start starts the warp9 server of the fault injector.
+func (fi *FaultInjector) Start() bool {
+	return fi.ServerController.Start(fi)
+}
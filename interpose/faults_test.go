@@ -0,0 +1,243 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package interpose
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lavaorg/warp/warp9"
+	"github.com/lavaorg/warp/wkit"
+)
+
+func TestParseFaultRule(t *testing.T) {
+	for _, s := range []string{
+		"read,write /sensors 0.1 delay 2s",
+		"* * 0.01 reset",
+		"open /secret/* 1 error perm",
+		"walk / 0.5 error 7",
+		"read /big 1 truncate 3",
+		"clunk * 0 drop",
+	} {
+		r, err := ParseFaultRule(s)
+		if err != nil {
+			t.Errorf("%s: %v", s, err)
+			continue
+		}
+		if r.String() != s {
+			t.Errorf("%s: parsed as %s", s, r)
+		}
+	}
+	for _, s := range []string{
+		"read /x 1",
+		"version /x 1 drop",
+		"read x 1 drop",
+		"read /[ 1 drop",
+		"read /x 2 drop",
+		"read /x 1 delay",
+		"read /x 1 delay soon",
+		"read /x 1 error 0",
+		"read /x 1 error bogus",
+		"write /x 1 truncate 3",
+		"read /x 1 drop now",
+		"read /x 1 explode",
+	} {
+		if _, err := ParseFaultRule(s); err == nil {
+			t.Errorf("%s: no error", s)
+		}
+	}
+	rules, err := ParseFaultRules(strings.NewReader("# faults\n\nread /x 1 drop # lost\n* * 0.5 reset\n"))
+	if err != nil || len(rules) != 2 {
+		t.Errorf("rules: %v, %v", rules, err)
+	}
+	if _, err := ParseFaultRules(strings.NewReader("read /x 1 drop\nbad\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("bad rules: %v", err)
+	}
+}
+
+// the faults as the interceptor of a server
+type faulty struct {
+	*wkit.ServerController
+	*Faults
+}
+
+func (f faulty) Flush(req *warp9.SrvReq) { f.Faults.Flush(req) }
+
+func (f faulty) ConnClosed(conn *warp9.Conn) {
+	f.ServerController.ConnClosed(conn)
+	f.Faults.ConnClosed(conn)
+}
+
+func faultRule(t *testing.T, s string) *FaultRule {
+	t.Helper()
+	r, err := ParseFaultRule(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestFaults(t *testing.T) {
+	faults := NewFaults(7)
+	srv := wkit.NewServer("device", 0, tree("/", map[string]string{"temp": "21", "big": "abcdef"}))
+	if !srv.Start(faulty{srv, faults}) {
+		t.Fatal("unable to start server")
+	}
+	l := listen(t)
+	defer l.Close()
+	go srv.StartListener(l)
+	mount := func() *warp9.Clnt {
+		c9, err := warp9.Mount("tcp", l.Addr().String(), "", 8192, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c9
+	}
+	c9 := mount()
+	defer c9.Unmount()
+
+	faults.Add(faultRule(t, "read /temp 1 error perm"), faultRule(t, "read /big 1 truncate 3"))
+	if _, _, err := c9.Get("/temp", 0); !errors.Is(err, warp9.ErrorCode(warp9.Eperm)) {
+		t.Errorf("error: %v", err)
+	}
+	if s := get(c9, "/big"); s != "abc" {
+		t.Errorf("truncated: %q", s)
+	}
+	if r := faults.Rules(); r[0].Hits != 1 || r[1].Hits != 1 {
+		t.Errorf("hits: %d %d", r[0].Hits, r[1].Hits)
+	}
+
+	faults.Clear()
+	faults.Add(faultRule(t, "open /temp 1 delay 50ms"))
+	start := time.Now()
+	if s := get(c9, "/temp"); s != "21" || time.Since(start) < 50*time.Millisecond {
+		t.Errorf("delayed: %q after %v", s, time.Since(start))
+	}
+
+	// the same seed gives the same faults
+	faults.Clear()
+	faults.Add(faultRule(t, "read /temp 0.5 error io"))
+	run := func() string {
+		faults.Seed(42)
+		var b strings.Builder
+		for i := 0; i < 20; i++ {
+			if _, _, err := c9.Get("/temp", 0); err != nil {
+				b.WriteByte('x')
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		return b.String()
+	}
+	first := run()
+	if !strings.Contains(first, "x") || !strings.Contains(first, ".") {
+		t.Errorf("faults: %s", first)
+	}
+	if again := run(); again != first {
+		t.Errorf("reseeded: %s, was %s", again, first)
+	}
+
+	// a dropped request is not answered
+	faults.Clear()
+	faults.Add(faultRule(t, "stat /temp 1 drop"))
+	c := mount()
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.Stat("/temp")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Errorf("dropped stat answered: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	c.Unmount()
+
+	// and is forgotten once its connection is closed
+	pending := func() int {
+		faults.mu.Lock()
+		defer faults.mu.Unlock()
+		return len(faults.dropped)
+	}
+	for deadline := time.Now().Add(time.Second); pending() != 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := pending(); n != 0 {
+		t.Errorf("%d dropped requests kept after close", n)
+	}
+
+	// a reset closes the connection
+	faults.Clear()
+	faults.Add(faultRule(t, "open /temp 1 reset"))
+	c = mount()
+	if _, err := c.Open("/temp", warp9.OREAD); err == nil {
+		t.Error("opened through a reset")
+	}
+	select {
+	case <-c.Closed():
+	case <-time.After(time.Second):
+		t.Error("connection not closed")
+	}
+	c.Unmount()
+}
+
+func TestFaultInjector(t *testing.T) {
+	_, addr := serveUpstream(t, "device", tree("/", map[string]string{"temp": "21"}))
+	mt, err := wkit.MountPointDial("tcp", addr, "", 0, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mt.Unmount()
+
+	fi := NewFaultInjector("faults", 0, mt, NewFaults(1))
+	if !fi.Start() {
+		t.Fatal("unable to start fault injector")
+	}
+	l := listen(t)
+	defer l.Close()
+	go fi.StartListener(l)
+	cc9, err := warp9.Mount("tcp", l.Addr().String(), "faults", 8192, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc9.Unmount()
+	c9, err := warp9.Mount("tcp", l.Addr().String(), "", 8192, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c9.Unmount()
+
+	ctl, err := cc9.Open("/ctl", warp9.ORDWR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctl.Close()
+	command := func(s string) string {
+		t.Helper()
+		if _, err := ctl.WriteAt([]byte(s), 0); err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+		buf := make([]byte, 512)
+		n, _ := ctl.ReadAt(buf, 0)
+		return string(buf[:n])
+	}
+
+	// the rules apply to everything but the ctl
+	command("add * * 1 error perm")
+	if s := get(c9, "/temp"); !strings.Contains(s, "permission denied") {
+		t.Errorf("faulted: %q", s)
+	}
+	if s := command("rules"); s != "1 * * 1 error perm # 1 hits\n" {
+		t.Errorf("rules: %q", s)
+	}
+	if _, err := ctl.WriteAt([]byte("add read /temp"), 0); err == nil {
+		t.Error("added a bad rule")
+	}
+	command("del 1")
+	if s := get(c9, "/temp"); s != "21" {
+		t.Errorf("after del: %q", s)
+	}
+}
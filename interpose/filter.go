@@ -0,0 +1,241 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package interpose
+
+import (
+	"path"
+	"sync"
+
+	"github.com/lavaorg/warp/warp9"
+	"github.com/lavaorg/warp/wkit"
+)
+
+// A Filter is an interposer serving the tree of an upstream mount as a
+// Policy permits for the user of each fid, as given at attach.
+//
+// Hidden names do not exist for the user: walks to them fail with
+// Enotexist and they are left out of directory reads. Opening for
+// writing, creating, removing and wstat'ing paths that are not writable
+// fail with Eperm. Everything the policy permits goes to the upstream
+// server unchanged.
+type Filter struct {
+	*wkit.ServerController
+
+	up     *wkit.MountPoint
+	mu     sync.RWMutex
+	policy *Policy
+}
+
+// NewFilter returns a filtering interposer serving the tree of up under
+// policy.
+func NewFilter(id string, debuglevel int, up *wkit.MountPoint, policy *Policy) *Filter {
+	f := &Filter{up: up, policy: policy}
+	f.ServerController = wkit.NewServer(id, debuglevel, wkit.NewDirItem("/"))
+	return f
+}
+
+// Start starts the warp9 server of the filter.
+func (f *Filter) Start() bool {
+	return f.ServerController.Start(f)
+}
+
+// SetPolicy replaces the policy; it applies to the requests that follow,
+// on fids old and new.
+func (f *Filter) SetPolicy(policy *Policy) {
+	f.mu.Lock()
+	f.policy = policy
+	f.mu.Unlock()
+}
+
+// the access of user to p
+func (f *Filter) access(user warp9.User, p string) Access {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.policy.Access(user, p)
+}
+
+// Attach gives the session the upstream root, seen as its user.
+func (f *Filter) Attach(req *warp9.SrvReq) {
+	if aname := req.Tc.Aname; aname != "" && aname != "/" {
+		req.RespondError(warp9.ErrorMsg(warp9.Enotexist, aname))
+		return
+	}
+	if req.Afid != nil {
+		req.RespondError(warp9.ErrorCode(warp9.Enoauth))
+		return
+	}
+	if f.access(req.Fid.User, "/") == Hidden {
+		req.RespondError(warp9.ErrorCode(warp9.Eperm))
+		return
+	}
+	root, err := f.up.AttachRoot()
+	if err != nil {
+		req.RespondError(err)
+		return
+	}
+	req.Fid.Aux = &filterItem{proxyItem: proxyItem{up: root, path: "/"}, f: f, user: req.Fid.User}
+	qid := root.GetQid()
+	req.RespondRattach(&qid)
+}
+
+// A filterItem is a fid's view of an upstream object, as its user is
+// permitted to see it.
+type filterItem struct {
+	proxyItem
+	f      *Filter
+	user   warp9.User
+	iounit uint32
+	buf    []byte // the filtered listing of a directory, once read
+}
+
+// Eperm unless the fid's user may change p
+func (fi *filterItem) writable(p string) error {
+	if fi.f.access(fi.user, p) != Writable {
+		return warp9.ErrorCode(warp9.Eperm)
+	}
+	return nil
+}
+
+// the view of up at p for the same user
+func (fi *filterItem) wrap(up wkit.Item, p string) *filterItem {
+	return &filterItem{proxyItem: proxyItem{up: up, path: p}, f: fi.f, user: fi.user}
+}
+
+func (fi *filterItem) GetItem() wkit.Item {
+	return fi
+}
+
+func (fi *filterItem) IsDirectory() wkit.Directory {
+	if fi.up.IsDirectory() == nil {
+		return nil
+	}
+	return fi
+}
+
+// Walked returns a copy for the new fid.
+func (fi *filterItem) Walked() (wkit.Item, error) {
+	up, err := fi.up.Walked()
+	if err != nil {
+		return nil, err
+	}
+	return fi.wrap(up, fi.path), nil
+}
+
+// Open fails with Eperm if opening for writing a path that is not
+// writable.
+func (fi *filterItem) Open(mode byte) (uint32, error) {
+	if mode&3 != warp9.OREAD || mode&(warp9.OTRUNC|warp9.ORCLOSE) != 0 {
+		if err := fi.writable(fi.path); err != nil {
+			return 0, err
+		}
+	}
+	iounit, err := fi.up.Open(mode)
+	if err != nil {
+		return 0, err
+	}
+	fi.iounit, fi.buf = iounit, nil
+	return iounit, nil
+}
+
+// Read reads a directory without the names hidden from the user.
+func (fi *filterItem) Read(obuf []byte, off uint64, rcount uint32) (uint32, error) {
+	if fi.up.IsDirectory() == nil {
+		return fi.up.Read(obuf, off, rcount)
+	}
+	if fi.buf == nil || off == 0 {
+		list, err := fi.list()
+		if err != nil {
+			return 0, err
+		}
+		fi.buf = list
+	}
+
+	return wkit.ReadBuf(obuf, fi.buf, off, rcount), nil
+}
+
+// the packed entries of the directory the user may see
+func (fi *filterItem) list() ([]byte, error) {
+	all, err := readWhole(fi.up, fi.iounit, -1)
+	if err != nil {
+		return nil, err
+	}
+	list := []byte{}
+	for b := all; len(b) > 0; {
+		d, rest, _, err := warp9.UnpackDir(b)
+		if err != nil {
+			return nil, err
+		}
+		b = rest
+		if fi.f.access(fi.user, path.Join(fi.path, d.Name)) != Hidden {
+			list = append(list, warp9.PackDir(d)...)
+		}
+	}
+	return list, nil
+}
+
+func (fi *filterItem) Write(ibuf []byte, off uint64, count uint32) (uint32, error) {
+	if err := fi.writable(fi.path); err != nil {
+		return 0, err
+	}
+	return fi.up.Write(ibuf, off, count)
+}
+
+func (fi *filterItem) Remove() error {
+	if err := fi.writable(fi.path); err != nil {
+		return err
+	}
+	return fi.up.Remove()
+}
+
+// WStat needs the new name of a rename to be writable too.
+func (fi *filterItem) WStat(dir *warp9.Dir) error {
+	if err := fi.writable(fi.path); err != nil {
+		return err
+	}
+	if dir.Name != "" {
+		if err := fi.writable(path.Join(path.Dir(fi.path), dir.Name)); err != nil {
+			return err
+		}
+	}
+	return fi.up.WStat(dir)
+}
+
+//
+// Directory interface
+//
+
+// Walk fails with Enotexist if a name on the way is hidden from the user.
+func (fi *filterItem) Walk(names []string) (wkit.Item, error) {
+	p := fi.path
+	for _, n := range names {
+		p = path.Join(p, n)
+		if fi.f.access(fi.user, p) == Hidden {
+			return nil, warp9.ErrorMsg(warp9.Enotexist, n)
+		}
+	}
+	up, p, err := fi.walk(names)
+	if err != nil {
+		return nil, err
+	}
+	return fi.wrap(up, p), nil
+}
+
+func (fi *filterItem) RemoveItem(item wkit.Item) error {
+	if err := fi.writable(path.Join(fi.path, item.GetDir().Name)); err != nil {
+		return err
+	}
+	return fi.proxyItem.RemoveItem(item)
+}
+
+// Create fails with Eperm if the new path is not writable.
+func (fi *filterItem) Create(name string, perm uint32, mode uint8) (wkit.Item, error) {
+	if err := fi.writable(path.Join(fi.path, name)); err != nil {
+		return nil, err
+	}
+	item, p, err := fi.create(name, perm, mode)
+	if err != nil {
+		return nil, err
+	}
+	return fi.wrap(item, p), nil
+}
@@ -0,0 +1,195 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package interpose
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lavaorg/warp/warp9"
+	"github.com/lavaorg/warp/wkit"
+)
+
+const testPolicy = `
+# staff may change scratch, but not its keys
+default readonly
+hide   /secret
+hide   /*/keys  @20
+write  /scratch @20
+`
+
+func TestParsePolicy(t *testing.T) {
+	p, err := ParsePolicy(strings.NewReader(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Default != ReadOnly || len(p.Rules) != 3 || p.Rules[2].Line != 6 {
+		t.Fatalf("policy: %+v", p)
+	}
+	staff := warp9.Identity.User(501)
+	for _, c := range []struct {
+		user warp9.User
+		path string
+		want Access
+	}{
+		{user, "/", ReadOnly},
+		{user, "/secret/x", Hidden},
+		{user, "/scratch/f", ReadOnly},
+		{user, "/scratch/keys", ReadOnly},
+		{staff, "/scratch/f", Writable},
+		{staff, "/scratch/keys/k", Hidden},
+		{nil, "/scratch", ReadOnly},
+	} {
+		if a := p.Access(c.user, c.path); a != c.want {
+			t.Errorf("access to %s: %v, want %v", c.path, a, c.want)
+		}
+	}
+
+	for _, bad := range []string{
+		"hidden /x",
+		"hide x",
+		"hide /[",
+		"write /x @staff",
+		"default",
+		"write",
+	} {
+		if _, err := ParsePolicy(strings.NewReader(bad)); err == nil {
+			t.Errorf("%q: no error", bad)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"pub", "secret", "scratch/f", "scratch/keys/k"} {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exp, err := wkit.NewExportDir("/", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, addr := serveUpstream(t, "internal", exp)
+	mt, err := wkit.MountPointDial("tcp", addr, "", 0, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mt.Unmount()
+	policy, err := ParsePolicy(strings.NewReader(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := NewFilter("filter", 0, mt, policy)
+	if !f.Start() {
+		t.Fatal("unable to start filter")
+	}
+	c9 := mountInterposer(t, f.ServerController)
+	l := listen(t)
+	defer l.Close()
+	go f.StartListener(l)
+	staff, err := warp9.Mount("tcp", l.Addr().String(), "", 8192, warp9.Identity.User(501))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer staff.Unmount()
+
+	names := func(c9 *warp9.Clnt, p string) string {
+		dirs, err := c9.ReadDir(p)
+		if err != nil {
+			return err.Error()
+		}
+		var s []string
+		for _, d := range dirs {
+			s = append(s, d.Name)
+		}
+		return strings.Join(s, " ")
+	}
+	isPerm := func(err error) bool {
+		return err != nil && strings.Contains(err.Error(), warp9.ErrorCode(warp9.Eperm).Error())
+	}
+
+	// hidden names are not seen
+	if s := names(c9, "/"); strings.Contains(s, "secret") || !strings.Contains(s, "pub") {
+		t.Errorf("listing: %q", s)
+	}
+	if _, err := c9.Stat("/secret"); err == nil {
+		t.Error("walked to a hidden name")
+	}
+	if s := names(staff, "/scratch"); s != "f" {
+		t.Errorf("staff listing: %q", s)
+	}
+	if s := names(c9, "/scratch"); strings.Count(s, " ") != 1 {
+		t.Errorf("listing: %q", s)
+	}
+
+	// read only paths may be read but not changed
+	if s := get(c9, "/pub"); s != "pub" {
+		t.Errorf("pub: %q", s)
+	}
+	if _, err := c9.Open("/pub", warp9.OWRITE); !isPerm(err) {
+		t.Errorf("open for writing: %v", err)
+	}
+	if err := c9.Remove("/pub"); !isPerm(err) {
+		t.Errorf("remove: %v", err)
+	}
+	if _, err := c9.Create("/scratch/new", 0644, warp9.OWRITE); !isPerm(err) {
+		t.Errorf("create: %v", err)
+	}
+
+	// writable paths pass through
+	obj, err := staff.Create("/scratch/new", 0644, warp9.OWRITE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj.Write([]byte("new"))
+	obj.Close()
+	if data, err := os.ReadFile(filepath.Join(dir, "scratch", "new")); err != nil || string(data) != "new" {
+		t.Errorf("created: %q, %v", data, err)
+	}
+
+	// a rename needs the new name to be writable
+	renamed, err := ParsePolicy(strings.NewReader("default readonly\nreadonly /scratch/ro\nwrite /scratch @20\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.SetPolicy(renamed)
+	rename := func(from, to string) error {
+		fid, err := staff.Walk(from)
+		if err != nil {
+			return err
+		}
+		defer staff.Clunk(fid)
+		d := warp9.NullDir()
+		d.Name = to
+		return staff.FWstat(fid, d)
+	}
+	if err := rename("/scratch/new", "ro"); !isPerm(err) {
+		t.Errorf("rename to a read only name: %v", err)
+	}
+	if err := rename("/scratch/new", "new2"); err != nil {
+		t.Errorf("rename: %v", err)
+	}
+	f.SetPolicy(policy)
+	if err := staff.Remove("/scratch/new2"); err != nil {
+		t.Error(err)
+	}
+
+	// a new policy applies to open fids
+	obj, err = staff.Open("/scratch/f", warp9.OWRITE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer obj.Close()
+	f.SetPolicy(&Policy{Default: ReadOnly})
+	if _, err := obj.WriteAt([]byte("x"), 0); !isPerm(err) {
+		t.Errorf("write after the policy changed: %v", err)
+	}
+}
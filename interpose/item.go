@@ -0,0 +1,120 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package interpose
+
+import (
+	"path"
+
+	"github.com/lavaorg/warp/warp9"
+	"github.com/lavaorg/warp/wkit"
+)
+
+// A node is the place of a fid's view of an upstream object in the tree
+// of an interposer: its Dir, its upstream path and the directories above
+// it. Items that keep their own Dir embed it for the Item and Directory
+// methods needing nothing of the upstream object.
+type node struct {
+	warp9.Dir
+	path   string
+	parent wkit.Directory // the directory holding the object, if known
+	top    wkit.Directory // where ".." above the upstream root leads
+}
+
+func (n *node) GetDir() *warp9.Dir {
+	return &n.Dir
+}
+
+func (n *node) Parent() wkit.Directory {
+	return n.parent
+}
+
+func (n *node) SetParent(d wkit.Directory) error {
+	n.parent = d
+	if n.path == "/" {
+		n.top = d
+	}
+	return nil
+}
+
+func (n *node) GetQid() warp9.Qid {
+	return n.Qid
+}
+
+func (n *node) SetMode(mode uint32) {
+	n.Mode = mode
+}
+
+func (n *node) Name() string {
+	return n.Dir.Name
+}
+
+func (n *node) AddDirectory(d wkit.Directory) {}
+
+func (n *node) AddItem(item wkit.Item) {}
+
+// Children returns nil; the directory is listed by reading it.
+func (n *node) Children() map[string]wkit.Item {
+	return nil
+}
+
+// the upstream path names lead to from the node; above is set, with the
+// names left, if ".." leads above the upstream root into top
+func (n *node) follow(names []string) (p string, rest []string, above bool) {
+	p = n.path
+	for i, name := range names {
+		switch {
+		case name != "..":
+			p = path.Join(p, name)
+		case p != "/":
+			p = path.Dir(p)
+		case n.top != nil:
+			return p, names[i+1:], true
+		}
+	}
+	return p, nil, false
+}
+
+// walk the names left above the upstream root from top
+func (n *node) walkTop(rest []string) (wkit.Item, error) {
+	if len(rest) == 0 {
+		return n.top.Walked()
+	}
+	return n.top.Walk(rest)
+}
+
+// the directory holding p, where it is known; self is the item of n
+func (n *node) parentOf(self wkit.Directory, p string) wkit.Directory {
+	switch {
+	case p == n.path:
+		return n.parent
+	case p == "/":
+		return n.top
+	case path.Dir(p) == n.path:
+		return self
+	}
+	return nil
+}
+
+// read the whole of an open upstream object; with max >= 0 reading stops,
+// returning nil, once the object is longer than max
+func readWhole(item wkit.Item, iounit uint32, max int) ([]byte, error) {
+	if iounit == 0 {
+		iounit = warp9.MSIZE - warp9.IOHDRSZ
+	}
+	buf := make([]byte, iounit)
+	data := []byte{}
+	for {
+		n, err := item.Read(buf, uint64(len(data)), iounit)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return data, nil
+		}
+		data = append(data, buf[:n]...)
+		if max >= 0 && len(data) > max {
+			return nil, nil
+		}
+	}
+}
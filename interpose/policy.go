@@ -0,0 +1,212 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package interpose
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/lavaorg/warp/warp9"
+)
+
+// An Access is what a Policy permits on a path.
+type Access int
+
+const (
+	Writable Access = iota // read and change
+	ReadOnly               // read but not change
+	Hidden                 // not seen at all
+)
+
+func (a Access) String() string {
+	switch a {
+	case Writable:
+		return "write"
+	case ReadOnly:
+		return "readonly"
+	case Hidden:
+		return "hide"
+	}
+	return fmt.Sprintf("Access(%d)", int(a))
+}
+
+// A Rule gives Access to the paths matching Glob for the users it names.
+type Rule struct {
+	Access Access
+	Glob   string   // a path.Match pattern; it also covers the paths below a match
+	Users  []string // user ids or names, and @group ids; none is every user
+	Line   int      // line number in the policy description
+}
+
+// A Policy decides the access of a user to a path: the first rule
+// matching both gives it, and Default applies when none does.
+//
+// A policy is described by lines of the form
+//
+//	access glob [user ...]
+//	default access
+//
+// where access is write, readonly or hide and a user is a user id or
+// name, or @ and a group id. Blank lines and text after a '#' are
+// ignored. For example
+//
+//	default readonly
+//	hide      /secret
+//	hide      /*/keys
+//	write     /scratch  @20 501
+type Policy struct {
+	Default Access
+	Rules   []Rule
+}
+
+// ParsePolicy reads a policy description.
+func ParsePolicy(r io.Reader) (*Policy, error) {
+	p := &Policy{}
+	scan := bufio.NewScanner(r)
+	for line := 1; scan.Scan(); line++ {
+		text := scan.Text()
+		if n := strings.IndexByte(text, '#'); n >= 0 {
+			text = text[:n]
+		}
+		f := strings.Fields(text)
+		if len(f) == 0 {
+			continue
+		}
+		if err := p.parseLine(f, line); err != nil {
+			return nil, fmt.Errorf("policy line %d: %v", line, err)
+		}
+	}
+	if err := scan.Err(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// LoadPolicyFile reads the policy description in the named file.
+func LoadPolicyFile(name string) (*Policy, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParsePolicy(f)
+}
+
+func parseAccess(s string) (Access, error) {
+	for _, a := range []Access{Writable, ReadOnly, Hidden} {
+		if s == a.String() {
+			return a, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown access %q", s)
+}
+
+func (p *Policy) parseLine(f []string, line int) error {
+	if f[0] == "default" {
+		if len(f) != 2 {
+			return fmt.Errorf("usage: default access")
+		}
+		a, err := parseAccess(f[1])
+		if err != nil {
+			return err
+		}
+		p.Default = a
+		return nil
+	}
+	a, err := parseAccess(f[0])
+	if err != nil {
+		return err
+	}
+	if len(f) < 2 {
+		return fmt.Errorf("usage: %s glob [user ...]", f[0])
+	}
+	glob := f[1]
+	if !strings.HasPrefix(glob, "/") {
+		return fmt.Errorf("%q is not an absolute path", glob)
+	}
+	if _, err := path.Match(glob, ""); err != nil {
+		return fmt.Errorf("%q: %v", glob, err)
+	}
+	for _, u := range f[2:] {
+		if g := strings.TrimPrefix(u, "@"); g != u {
+			if _, err := strconv.ParseUint(g, 10, 32); err != nil {
+				return fmt.Errorf("bad group id %q", u)
+			}
+		}
+	}
+	p.Rules = append(p.Rules, Rule{Access: a, Glob: glob, Users: f[2:], Line: line})
+	return nil
+}
+
+// Access returns the access of user to the path name.
+func (p *Policy) Access(user warp9.User, name string) Access {
+	name = path.Clean("/" + name)
+	for _, r := range p.Rules {
+		if r.matches(name) && r.applies(user) {
+			return r.Access
+		}
+	}
+	return p.Default
+}
+
+// true if the glob matches name or a directory above it
+func (r *Rule) matches(name string) bool {
+	return globCovers(r.Glob, name)
+}
+
+// true if the path.Match pattern glob matches name or a directory above it
+func globCovers(glob, name string) bool {
+	for {
+		if ok, _ := path.Match(glob, name); ok {
+			return true
+		}
+		if name == "/" {
+			return false
+		}
+		name = path.Dir(name)
+	}
+}
+
+// true if the rule names user
+func (r *Rule) applies(user warp9.User) bool {
+	if len(r.Users) == 0 {
+		return true
+	}
+	if user == nil {
+		return false
+	}
+	for _, u := range r.Users {
+		if g := strings.TrimPrefix(u, "@"); g != u {
+			gid, _ := strconv.ParseUint(g, 10, 32)
+			if user.IsMember(group(gid)) {
+				return true
+			}
+			continue
+		}
+		if id, err := strconv.ParseUint(u, 10, 32); err == nil && uint32(id) == user.Id() || u == user.Name() {
+			return true
+		}
+	}
+	return false
+}
+
+// a group known only by its id, to ask about membership
+type group uint32
+
+func (g group) Name() string {
+	return strconv.FormatUint(uint64(g), 10)
+}
+
+func (g group) Id() uint32 {
+	return uint32(g)
+}
+
+func (g group) Members() []warp9.User {
+	return nil
+}
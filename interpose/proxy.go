@@ -0,0 +1,202 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package interpose
+
+import (
+	"path"
+
+	"github.com/lavaorg/warp/warp9"
+	"github.com/lavaorg/warp/wkit"
+)
+
+// A Proxy is an interposer serving the tree of an upstream directory,
+// typically a wkit.MountPoint, unchanged. It is the base of interposers
+// acting on the requests rather than on the tree.
+type Proxy struct {
+	*wkit.ServerController
+
+	up wkit.Directory
+}
+
+// NewProxy returns an interposer passing every request to the tree of up.
+func NewProxy(id string, debuglevel int, up wkit.Directory) *Proxy {
+	p := &Proxy{up: up}
+	p.ServerController = wkit.NewServer(id, debuglevel, wkit.NewDirItem("/"))
+	return p
+}
+
+// Start starts the warp9 server of the proxy.
+func (p *Proxy) Start() bool {
+	return p.ServerController.Start(p)
+}
+
+// Attach gives the session the upstream root; other anames attach the
+// trees added with AddTree.
+func (p *Proxy) Attach(req *warp9.SrvReq) {
+	if aname := req.Tc.Aname; aname != "" && aname != "/" {
+		p.ServerController.Attach(req)
+		return
+	}
+	if req.Afid != nil {
+		req.RespondError(warp9.ErrorCode(warp9.Enoauth))
+		return
+	}
+	root, err := p.up.Walked()
+	if err != nil {
+		req.RespondError(err)
+		return
+	}
+	req.Fid.Aux = &proxyItem{up: root, path: "/"}
+	qid := root.GetQid()
+	req.RespondRattach(&qid)
+}
+
+// A proxyItem is a fid's view of an upstream object passing every request
+// through. The items of interposers changing some requests embed it and
+// override those; they override GetItem, IsDirectory, Walked, Walk and
+// Create too, so that the items given out are their own.
+type proxyItem struct {
+	up   wkit.Item
+	path string
+}
+
+// the upstream object reached by walking names, and its path
+func (pi *proxyItem) walk(names []string) (wkit.Item, string, error) {
+	d := pi.up.IsDirectory()
+	if d == nil {
+		return nil, "", warp9.ErrorCode(warp9.Enotdir)
+	}
+	up, err := d.Walk(names)
+	if err != nil {
+		return nil, "", err
+	}
+	p := pi.path
+	for _, n := range names {
+		p = path.Join(p, n)
+	}
+	return up, p, nil
+}
+
+// the upstream object created as name, and its path
+func (pi *proxyItem) create(name string, perm uint32, mode uint8) (wkit.Item, string, error) {
+	cr, ok := pi.up.(wkit.Creator)
+	if !ok {
+		return nil, "", warp9.ErrorCode(warp9.Eperm)
+	}
+	item, err := cr.Create(name, perm, mode)
+	if err != nil {
+		return nil, "", err
+	}
+	return item, path.Join(pi.path, name), nil
+}
+
+func (pi *proxyItem) GetDir() *warp9.Dir {
+	return pi.up.GetDir()
+}
+
+func (pi *proxyItem) GetItem() wkit.Item {
+	return pi
+}
+
+func (pi *proxyItem) IsDirectory() wkit.Directory {
+	if pi.up.IsDirectory() == nil {
+		return nil
+	}
+	return pi
+}
+
+// Parent returns nil; ".." at the top of the tree stays there.
+func (pi *proxyItem) Parent() wkit.Directory {
+	return nil
+}
+
+func (pi *proxyItem) SetParent(d wkit.Directory) error {
+	return nil
+}
+
+func (pi *proxyItem) GetQid() warp9.Qid {
+	return pi.up.GetQid()
+}
+
+// Walked returns a copy for the new fid.
+func (pi *proxyItem) Walked() (wkit.Item, error) {
+	up, err := pi.up.Walked()
+	if err != nil {
+		return nil, err
+	}
+	return &proxyItem{up: up, path: pi.path}, nil
+}
+
+func (pi *proxyItem) SetMode(mode uint32) {
+	pi.up.SetMode(mode)
+}
+
+func (pi *proxyItem) Open(mode byte) (uint32, error) {
+	return pi.up.Open(mode)
+}
+
+func (pi *proxyItem) Read(obuf []byte, off uint64, rcount uint32) (uint32, error) {
+	return pi.up.Read(obuf, off, rcount)
+}
+
+func (pi *proxyItem) Write(ibuf []byte, off uint64, count uint32) (uint32, error) {
+	return pi.up.Write(ibuf, off, count)
+}
+
+func (pi *proxyItem) Clunk() error {
+	return pi.up.Clunk()
+}
+
+func (pi *proxyItem) Remove() error {
+	return pi.up.Remove()
+}
+
+func (pi *proxyItem) Stat() (*warp9.Dir, error) {
+	return pi.up.Stat()
+}
+
+func (pi *proxyItem) WStat(dir *warp9.Dir) error {
+	return pi.up.WStat(dir)
+}
+
+//
+// Directory interface
+//
+
+func (pi *proxyItem) Name() string {
+	return pi.up.GetDir().Name
+}
+
+func (pi *proxyItem) Walk(names []string) (wkit.Item, error) {
+	up, p, err := pi.walk(names)
+	if err != nil {
+		return nil, err
+	}
+	return &proxyItem{up: up, path: p}, nil
+}
+
+func (pi *proxyItem) AddDirectory(d wkit.Directory) {}
+
+func (pi *proxyItem) AddItem(item wkit.Item) {}
+
+// Children returns nil; the directory is listed by reading it.
+func (pi *proxyItem) Children() map[string]wkit.Item {
+	return nil
+}
+
+func (pi *proxyItem) RemoveItem(item wkit.Item) error {
+	d := pi.up.IsDirectory()
+	if d == nil {
+		return warp9.ErrorCode(warp9.Enotdir)
+	}
+	return d.RemoveItem(item)
+}
+
+func (pi *proxyItem) Create(name string, perm uint32, mode uint8) (wkit.Item, error) {
+	item, p, err := pi.create(name, perm, mode)
+	if err != nil {
+		return nil, err
+	}
+	return &proxyItem{up: item, path: p}, nil
+}
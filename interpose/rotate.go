@@ -0,0 +1,110 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package interpose
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// A RotatingFile is a log file that is rotated once it grows past
+// MaxSize: name is renamed name.1, name.1 name.2 and so on, keeping Keep
+// old files, and a new name is started.
+type RotatingFile struct {
+	Name    string
+	MaxSize int64
+	Keep    int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// OpenRotatingFile opens, or creates, the log file name for appending.
+func OpenRotatingFile(name string, maxsize int64, keep int) (*RotatingFile, error) {
+	rf := &RotatingFile{Name: name, MaxSize: maxsize, Keep: keep}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *RotatingFile) open() error {
+	f, err := os.OpenFile(rf.Name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	rf.f, rf.size = f, st.Size()
+	return nil
+}
+
+// Write appends p, rotating the file first if p would take it past
+// MaxSize. A write is never split between files. If the file cannot be
+// rotated p is appended to it still, and the error returned.
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.f == nil {
+		return 0, os.ErrClosed
+	}
+	var rerr error
+	if rf.MaxSize > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.MaxSize {
+		rerr = rf.rotate()
+	}
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+	if err == nil {
+		err = rerr
+	}
+	return n, err
+}
+
+// Rotate starts a new file now.
+func (rf *RotatingFile) Rotate() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.f == nil {
+		return os.ErrClosed
+	}
+	return rf.rotate()
+}
+
+// the old files are moved while the current one is open; if they cannot
+// be, or a new file started, the current one is kept for appending
+func (rf *RotatingFile) rotate() error {
+	if rf.Keep > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", rf.Name, rf.Keep))
+		for i := rf.Keep - 1; i > 0; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", rf.Name, i), fmt.Sprintf("%s.%d", rf.Name, i+1))
+		}
+		if err := os.Rename(rf.Name, rf.Name+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(rf.Name); err != nil {
+		return err
+	}
+	old := rf.f
+	if err := rf.open(); err != nil {
+		return err
+	}
+	return old.Close()
+}
+
+// Close closes the file.
+func (rf *RotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.f == nil {
+		return os.ErrClosed
+	}
+	err := rf.f.Close()
+	rf.f = nil
+	return err
+}
@@ -0,0 +1,373 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package interpose
+
+import (
+	"errors"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/lavaorg/warp/warp9"
+	"github.com/lavaorg/warp/wkit"
+)
+
+// A Transform is an interposer serving an upstream tree with the contents
+// of some objects converted by Transformers. The transformer of an object
+// is the first registered with Handle whose pattern matches its path, or
+// else the one registered with HandleType for its content type: its
+// ExtAttr up to any ';', as in "application/cbor; v=2". The warp9 stat
+// does not carry ExtAttr; content types are known only from upstream
+// trees in the same process and from their mounts' local Dirs.
+//
+// An object opened through the Transform is read and converted whole at
+// open, and reads of any size and offset are served from the result.
+// What a client writes is kept until the fid is clunked, then converted
+// and written in place of the upstream contents; until then, OTRUNC
+// included, the upstream object is left as it was. A write reaching past
+// MaxData bytes fails with Etoolarge. Stat gives such objects
+// a Length of 0, or that of the converted contents while open, as for
+// objects whose length is not known. Directories pass through.
+type Transform struct {
+	*wkit.ServerController
+	MaxData int // largest converted contents a client may write
+
+	up       wkit.Directory
+	mu       sync.RWMutex
+	patterns []pattern
+	types    map[string]Transformer
+}
+
+type pattern struct {
+	glob string
+	x    Transformer
+}
+
+// DefaultXformData is the MaxData of a new Transform.
+const DefaultXformData = 1024 * 1024
+
+// NewTransform returns a transforming interposer serving the tree of up,
+// typically a wkit.MountPoint.
+func NewTransform(id string, debuglevel int, up wkit.Directory) *Transform {
+	t := &Transform{
+		MaxData: DefaultXformData,
+		up:      up,
+		types:   make(map[string]Transformer),
+	}
+	t.ServerController = wkit.NewServer(id, debuglevel, wkit.NewDirItem("/"))
+	return t
+}
+
+// Start starts the warp9 server of the transform.
+func (t *Transform) Start() bool {
+	return t.ServerController.Start(t)
+}
+
+// Handle converts the objects whose paths match the path.Match pattern
+// glob with x.
+func (t *Transform) Handle(glob string, x Transformer) error {
+	if _, err := path.Match(glob, ""); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	t.patterns = append(t.patterns, pattern{glob, x})
+	t.mu.Unlock()
+	return nil
+}
+
+// HandleType converts the objects of content type ctype with x.
+func (t *Transform) HandleType(ctype string, x Transformer) {
+	t.mu.Lock()
+	t.types[ctype] = x
+	t.mu.Unlock()
+}
+
+// the transformer of the object at p described by d, nil for none
+func (t *Transform) lookup(p string, d *warp9.Dir) Transformer {
+	if d.Qid.Type&warp9.QTDIR != 0 {
+		return nil
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, pt := range t.patterns {
+		if ok, _ := path.Match(pt.glob, p); ok {
+			return pt.x
+		}
+	}
+	ctype := d.ExtAttr
+	if n := strings.IndexByte(ctype, ';'); n >= 0 {
+		ctype = ctype[:n]
+	}
+	return t.types[strings.TrimSpace(ctype)]
+}
+
+// Attach gives the session the upstream root; other anames attach the
+// trees added with AddTree.
+func (t *Transform) Attach(req *warp9.SrvReq) {
+	if aname := req.Tc.Aname; aname != "" && aname != "/" {
+		t.ServerController.Attach(req)
+		return
+	}
+	if req.Afid != nil {
+		req.RespondError(warp9.ErrorCode(warp9.Enoauth))
+		return
+	}
+	root, err := t.up.Walked()
+	if err != nil {
+		req.RespondError(err)
+		return
+	}
+	req.Fid.Aux = &xformItem{proxyItem: proxyItem{up: root, path: "/"}, t: t}
+	qid := root.GetQid()
+	req.RespondRattach(&qid)
+}
+
+// An xformItem is a fid's view of an upstream object.
+type xformItem struct {
+	proxyItem
+	t *Transform
+
+	x     Transformer // converts the object while open; nil if it passes through
+	buf   []byte      // the converted contents
+	dst   wkit.Item   // an unopened fid of the object, opened to rewrite it
+	write bool        // the upstream object is rewritten at clunk
+	trunc bool        // opened with OTRUNC; rewritten even if buf is not
+	dirty bool        // buf was written
+}
+
+func (xi *xformItem) wrap(up wkit.Item, p string) *xformItem {
+	return &xformItem{proxyItem: proxyItem{up: up, path: p}, t: xi.t}
+}
+
+func (xi *xformItem) GetItem() wkit.Item {
+	return xi
+}
+
+func (xi *xformItem) IsDirectory() wkit.Directory {
+	if xi.up.IsDirectory() == nil {
+		return nil
+	}
+	return xi
+}
+
+// Walked returns a copy for the new fid.
+func (xi *xformItem) Walked() (wkit.Item, error) {
+	up, err := xi.up.Walked()
+	if err != nil {
+		return nil, err
+	}
+	return xi.wrap(up, xi.path), nil
+}
+
+// write data as the whole of an open upstream object
+func writeItem(item wkit.Item, data []byte, iounit uint32) error {
+	if iounit == 0 {
+		iounit = warp9.MSIZE - warp9.IOHDRSZ
+	}
+	for off := 0; off < len(data); {
+		count := len(data) - off
+		if count > int(iounit) {
+			count = int(iounit)
+		}
+		n, err := item.Write(data[off:], uint64(off), uint32(count))
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return warp9.ErrorCode(warp9.Eio)
+		}
+		off += int(n)
+	}
+	return nil
+}
+
+// Open reads and converts a transformed object; empty contents are not
+// converted. Opening it for writing fails with Eperm if its transformer
+// cannot be reversed.
+func (xi *xformItem) Open(mode byte) (uint32, error) {
+	d, err := xi.up.Stat()
+	if err != nil {
+		return 0, err
+	}
+	x := xi.t.lookup(xi.path, d)
+	if x == nil {
+		return xi.up.Open(mode)
+	}
+	writing := mode&3 != warp9.OREAD || mode&warp9.OTRUNC != 0
+
+	// the upstream contents, read from a second fid if this one is
+	// not opened for reading
+	var orig []byte
+	switch {
+	case mode&warp9.OTRUNC != 0:
+	case writing:
+		c, err := xi.up.Walked()
+		if err != nil {
+			return 0, err
+		}
+		iounit, err := c.Open(warp9.OREAD)
+		if err == nil {
+			orig, err = readWhole(c, iounit, -1)
+		}
+		c.Clunk()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var buf []byte
+	if writing {
+		if len(orig) > 0 {
+			if buf, err = x.Decode(orig); err != nil {
+				return 0, err
+			}
+		}
+		if _, err := x.Encode(buf); errors.Is(err, warp9.ErrorCode(warp9.Eperm)) {
+			return 0, err
+		}
+	}
+	// the upstream object is only truncated when rewritten at clunk,
+	// through a fid walked now; an open fid cannot be walked from
+	var dst wkit.Item
+	if writing {
+		if dst, err = xi.up.Walked(); err != nil {
+			return 0, err
+		}
+	}
+	iounit, err := xi.up.Open(mode &^ warp9.OTRUNC)
+	if err != nil {
+		if dst != nil {
+			dst.Clunk()
+		}
+		return 0, err
+	}
+	if !writing {
+		if orig, err = readWhole(xi.up, iounit, -1); err == nil && len(orig) > 0 {
+			buf, err = x.Decode(orig)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	xi.x, xi.buf, xi.dst, xi.write, xi.dirty = x, buf, dst, writing, false
+	xi.trunc = mode&warp9.OTRUNC != 0
+	return iounit, nil
+}
+
+func (xi *xformItem) Read(obuf []byte, off uint64, rcount uint32) (uint32, error) {
+	if xi.x == nil {
+		return xi.up.Read(obuf, off, rcount)
+	}
+	return wkit.ReadBuf(obuf, xi.buf, off, rcount), nil
+}
+
+func (xi *xformItem) Write(ibuf []byte, off uint64, count uint32) (uint32, error) {
+	if xi.x == nil {
+		return xi.up.Write(ibuf, off, count)
+	}
+	if !xi.write {
+		return 0, warp9.ErrorCode(warp9.Ebaduse)
+	}
+	end := off + uint64(count)
+	if end < off || end > uint64(xi.t.MaxData) {
+		return 0, warp9.ErrorCode(warp9.Etoolarge)
+	}
+	if end > uint64(len(xi.buf)) {
+		xi.buf = append(xi.buf, make([]byte, end-uint64(len(xi.buf)))...)
+	}
+	copy(xi.buf[off:], ibuf[:count])
+	xi.dirty = true
+	return count, nil
+}
+
+// Clunk writes the converted contents of an object opened for writing
+// back upstream, truncating it then; if they cannot be converted the
+// upstream contents are left alone and the error returned.
+func (xi *xformItem) Clunk() error {
+	var err error
+	if xi.write && (xi.dirty || xi.trunc) {
+		var data []byte
+		if xi.dirty {
+			data, err = xi.x.Encode(xi.buf)
+		}
+		if err == nil {
+			err = xi.rewrite(data)
+		}
+	}
+	xi.dropDst()
+	xi.x, xi.buf, xi.write = nil, nil, false
+	if cerr := xi.up.Clunk(); cerr != nil && err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// replace the upstream contents with data
+func (xi *xformItem) rewrite(data []byte) error {
+	if xi.dst == nil {
+		// a created object is empty and open for writing already
+		return writeItem(xi.up, data, 0)
+	}
+	iounit, err := xi.dst.Open(warp9.OWRITE | warp9.OTRUNC)
+	if err != nil {
+		return err
+	}
+	return writeItem(xi.dst, data, iounit)
+}
+
+// drop the fid kept to rewrite the object
+func (xi *xformItem) dropDst() {
+	if xi.dst != nil {
+		xi.dst.Clunk()
+		xi.dst = nil
+	}
+}
+
+func (xi *xformItem) Remove() error {
+	xi.write = false
+	xi.dropDst()
+	return xi.up.Remove()
+}
+
+// Stat gives transformed objects the length of their converted contents
+// while open, and 0 otherwise.
+func (xi *xformItem) Stat() (*warp9.Dir, error) {
+	d, err := xi.up.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if xi.x != nil {
+		d.Length = uint64(len(xi.buf))
+	} else if xi.t.lookup(xi.path, d) != nil {
+		d.Length = 0
+	}
+	return d, nil
+}
+
+//
+// Directory interface
+//
+
+func (xi *xformItem) Walk(names []string) (wkit.Item, error) {
+	up, p, err := xi.walk(names)
+	if err != nil {
+		return nil, err
+	}
+	return xi.wrap(up, p), nil
+}
+
+// Create creates the object upstream; a transformed object is written
+// at clunk, as when opened for writing.
+func (xi *xformItem) Create(name string, perm uint32, mode uint8) (wkit.Item, error) {
+	item, p, err := xi.create(name, perm, mode)
+	if err != nil {
+		return nil, err
+	}
+	ni := xi.wrap(item, p)
+	if x := xi.t.lookup(ni.path, item.GetDir()); x != nil {
+		ni.x, ni.buf = x, []byte{}
+		ni.write = mode&3 != warp9.OREAD
+	}
+	return ni, nil
+}
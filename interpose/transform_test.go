@@ -0,0 +1,192 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package interpose
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lavaorg/warp/warp9"
+	"github.com/lavaorg/warp/wkit"
+)
+
+// the whole of the object p, read in pieces of the iounit
+func readAll(c9 *warp9.Clnt, p string) string {
+	obj, err := c9.Open(p, warp9.OREAD)
+	if err != nil {
+		return err.Error()
+	}
+	defer obj.Close()
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+func TestCBOR(t *testing.T) {
+	b, err := jsonToCBOR([]byte(`{"b":[2,3],"a":1}`))
+	if err != nil || hex.EncodeToString(b) != "a26161016162820203" {
+		t.Errorf("encoded: %x, %v", b, err)
+	}
+	for in, want := range map[string]string{
+		"f93c00":       `1`,
+		"f90001":       `5.960464477539063e-8`,
+		"3863":         `-100`,
+		"c11a514b67b0": `1363896240`,
+		"43010203":     `"AQID"`,
+		"a201020304":   `{"1":2,"3":4}`,
+	} {
+		b, _ := hex.DecodeString(in)
+		if out, err := cborToJSON(b); err != nil || string(out) != want {
+			t.Errorf("%s: %s, %v", in, out, err)
+		}
+	}
+
+	doc := `{"big":18446744073709551615,"f":-2.5,"list":[true,false,null,"x"],"n":-9000000000,"obj":{"k":"v"}}`
+	b, err = jsonToCBOR([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, err := cborToJSON(b); err != nil || string(out) != doc {
+		t.Errorf("round trip: %s, %v", out, err)
+	}
+	for _, bad := range []string{"", "1f", "5f", "62", "a1", "9a7fffffff"} {
+		b, _ := hex.DecodeString(bad)
+		if _, err := cborToJSON(b); err == nil {
+			t.Errorf("%s: no error", bad)
+		}
+	}
+}
+
+func TestTransform(t *testing.T) {
+	var fields []string
+	for i := 0; i < 40; i++ {
+		fields = append(fields, fmt.Sprintf(`"field%02d":%d`, i, i))
+	}
+	doc := "{" + strings.Join(fields, ",") + "}"
+	data, err := jsonToCBOR([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for name, s := range map[string]string{
+		"data.cbor": string(data),
+		"user.json": `{"name":"larry","password":"secret"}`,
+		"log.txt":   strings.Repeat("all is well\n", 50),
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exp, err := wkit.NewExportDir("/", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, addr := serveUpstream(t, "device", exp)
+	mt, err := wkit.MountPointDial("tcp", addr, "", 0, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mt.Unmount()
+
+	x := NewTransform("transform", 0, mt)
+	x.Handle("/*.cbor", CBORToJSON)
+	x.Handle("/user.json", Redact("password"))
+	x.Handle("/*.txt", Gzip)
+	if !x.Start() {
+		t.Fatal("unable to start transform")
+	}
+	// a small msize splits the reads and writes
+	l := listen(t)
+	defer l.Close()
+	go x.StartListener(l)
+	c9, err := warp9.Mount("tcp", l.Addr().String(), "", 256, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c9.Unmount()
+
+	if s := readAll(c9, "/data.cbor"); s != doc {
+		t.Errorf("converted: %s", s)
+	}
+	if s := get(c9, "/user.json"); s != `{"name":"larry"}` {
+		t.Errorf("redacted: %s", s)
+	}
+	if _, err := c9.Open("/user.json", warp9.OWRITE); err == nil {
+		t.Error("redacted object opened for writing")
+	}
+	if s, err := gunzipData([]byte(readAll(c9, "/log.txt"))); err != nil || !bytes.HasPrefix(s, []byte("all is well\n")) {
+		t.Errorf("compressed: %.20q, %v", s, err)
+	}
+
+	// writes are converted at clunk
+	put := func(obj *warp9.Object, s string) {
+		t.Helper()
+		if _, err := obj.Writen([]byte(s), 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := obj.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	obj, err := c9.Open("/data.cbor", warp9.OWRITE|warp9.OTRUNC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "data.cbor")); !bytes.Equal(b, data) {
+		t.Errorf("upstream truncated at open: %x", b)
+	}
+	if _, err := obj.Writen([]byte("x"), 1<<40); err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Errorf("write past MaxData: %v", err)
+	}
+	put(obj, doc[:len(doc)-1]+`,"added":true}`)
+	b, _ := os.ReadFile(filepath.Join(dir, "data.cbor"))
+	if s, err := cborToJSON(b); err != nil || !strings.HasPrefix(string(s), `{"added":true,`) {
+		t.Errorf("upstream after write: %.20s, %v", s, err)
+	}
+	if obj, err = c9.Create("/new.cbor", 0644, warp9.OWRITE); err != nil {
+		t.Fatal(err)
+	}
+	put(obj, `[1,2]`)
+	if b, _ := os.ReadFile(filepath.Join(dir, "new.cbor")); hex.EncodeToString(b) != "820102" {
+		t.Errorf("created: %x", b)
+	}
+
+	// what cannot be converted leaves the upstream object as it was
+	if obj, err = c9.Open("/new.cbor", warp9.OWRITE); err != nil {
+		t.Fatal(err)
+	}
+	obj.Writen([]byte("not json"), 0)
+	obj.Close()
+	if b, _ := os.ReadFile(filepath.Join(dir, "new.cbor")); hex.EncodeToString(b) != "820102" {
+		t.Errorf("after a bad write: %x", b)
+	}
+}
+
+func TestTransformType(t *testing.T) {
+	root := wkit.NewDirItem("/")
+	item := wkit.NewItem("reading")
+	item.SetBuffer([]byte{0xa1, 0x61, 0x74, 0x18, 0x15}) // {"t":21}
+	item.GetDir().ExtAttr = "application/cbor; v=1"
+	root.AddItem(item)
+	x := NewTransform("transform", 0, root)
+	x.HandleType("application/cbor", CBORToJSON)
+	if !x.Start() {
+		t.Fatal("unable to start transform")
+	}
+	c9 := mountInterposer(t, x.ServerController)
+	if s := get(c9, "/reading"); s != `{"t":21}` {
+		t.Errorf("converted: %q", s)
+	}
+	if d, err := c9.Stat("/reading"); err != nil || d.Length != 0 {
+		t.Errorf("stat: %v, %v", d, err)
+	}
+}
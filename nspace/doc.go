@@ -0,0 +1,33 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+/*
+Nspace is a gateway that mounts a set of remote Warp9 object servers into
+one namespace and serves that namespace to downstream clients.
+
+The mounts are described by a declarative mount table (see Table), for
+example:
+
+	{"mounts": [
+		{"path": "/svc/db",  "addr": "db.local:9090"},
+		{"path": "/svc/bin", "addr": "bin1.local:9090", "flag": "after"},
+		{"path": "/svc/bin", "addr": "bin2.local:9090", "flag": "after,create"}
+	]}
+
+The same layout can be written as a namespace description in the style of
+Plan 9 namespace files (see warp9.ParseNamespace and LoadNamespace), which
+also binds parts of the namespace to other paths:
+
+	mount tcp!db.local!9090 /svc/db
+	mount -a tcp!bin1.local!9090 /svc/bin
+	mount -ac tcp!bin2.local!9090 /svc/bin
+	bind /svc/db/info /info
+
+A client builds the same view without a gateway with warp9.Namespace.Load.
+
+Mounts sharing a path form a union directory. Each mount is supervised and
+remounted when its server comes back. The /ctl object of the served
+namespace changes the table at runtime and /status reports the state of
+every mount.
+*/
+package nspace
@@ -0,0 +1,534 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package nspace
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lavaorg/warp/warp9"
+	"github.com/lavaorg/warp/wkit"
+)
+
+// mount states reported by the status object
+const (
+	stateDown = "down"
+	stateUp   = "up"
+)
+
+// A Gateway mounts a table of remote object servers into a single
+// namespace and serves that namespace to downstream clients. Each mount
+// remounts itself with the Remount policy: a server that cannot be
+// reached, or whose connection drops, is retried with backoff until it
+// comes back. While a server is down its mount path remains in the
+// namespace as an empty directory.
+//
+// The namespace root holds two objects besides the mounts:
+//
+//	/ctl     a wkit.Command accepting "mount", "unmount" and "status"
+//	/status  the state of every mount, one per line
+type Gateway struct {
+	*wkit.ServerController
+	User    warp9.User         // user attaching to the remote servers
+	Remount wkit.RemountPolicy // how the mounts reconnect; binds back off alike
+
+	mu      sync.Mutex
+	root    wkit.Directory
+	mounts  map[string]*mount
+	binds   map[string]*bind
+	up      chan struct{} // closed, and replaced, when a mount comes up
+	started bool
+	nbind   int
+	bindMu  sync.Mutex // serializes rebuilding the unions of binds
+}
+
+// one supervised entry of the mount table
+type mount struct {
+	spec  MountSpec
+	flag  uint32
+	mt    *wkit.MountPoint // nil while down
+	err   error            // last mount error
+	since time.Time        // time of the last state change
+	stop  chan struct{}
+}
+
+// one supervised bind of a path of the namespace to another
+type bind struct {
+	name, old string
+	flag      uint32
+	seq       int       // order in which the binds were added
+	item      wkit.Item // the object to bind; nil while unreachable
+	bound     wkit.Item // the object bound at old; kept under bindMu
+	stop      chan struct{}
+}
+
+// NewGateway creates a gateway serving the mounts of table. Mounting starts
+// with Start.
+func NewGateway(id string, debuglevel int, table *Table, user warp9.User) (*Gateway, error) {
+	root := wkit.NewDirItem("/")
+	g := &Gateway{
+		ServerController: wkit.NewServer(id, debuglevel, root),
+		User:             user,
+		Remount:          wkit.DefaultRemount,
+		root:             root,
+		mounts:           make(map[string]*mount),
+		binds:            make(map[string]*bind),
+		up:               make(chan struct{}),
+	}
+	root.AddItem(g.newCtl())
+	root.AddItem(wkit.NewBytesItem("status", g))
+
+	if table != nil {
+		for _, spec := range table.Mounts {
+			if _, err := g.add(spec); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return g, nil
+}
+
+// Start starts the warp9 server and the supervision of every mount.
+func (g *Gateway) Start() bool {
+	if !g.ServerController.Start(g.ServerController) {
+		return false
+	}
+	g.mu.Lock()
+	g.started = true
+	for _, m := range g.mounts {
+		go g.supervise(m)
+	}
+	for _, b := range g.binds {
+		go g.superviseBind(b)
+	}
+	g.mu.Unlock()
+	return true
+}
+
+// Mount adds spec to the mount table and starts mounting it once the
+// gateway is started.
+func (g *Gateway) Mount(spec MountSpec) error {
+	m, err := g.add(spec)
+	if err != nil {
+		return err
+	}
+	g.mu.Lock()
+	if g.started {
+		go g.supervise(m)
+	}
+	g.mu.Unlock()
+	return nil
+}
+
+// BindPath makes the object at name in the namespace also appear at old.
+// The bind is supervised like a mount: while name cannot be reached, for
+// example because its server is down, the bind is retried.
+func (g *Gateway) BindPath(name, old string, flag uint32) error {
+	if !strings.HasPrefix(name, "/") || !strings.HasPrefix(old, "/") || path.Clean(old) == "/" {
+		return fmt.Errorf("bind %q %q: paths must be absolute and not the root", name, old)
+	}
+	b := &bind{name: path.Clean(name), old: path.Clean(old), flag: flag, stop: make(chan struct{})}
+	key := b.name + " " + b.old
+
+	g.mu.Lock()
+	if _, ok := g.binds[key]; ok {
+		g.mu.Unlock()
+		return warp9.ErrorMsg(warp9.Eexist, key)
+	}
+	if err := g.mkdirs(b.old); err != nil {
+		g.mu.Unlock()
+		return err
+	}
+	g.nbind++
+	b.seq = g.nbind
+	g.binds[key] = b
+	if g.started {
+		go g.superviseBind(b)
+	}
+	g.mu.Unlock()
+	return nil
+}
+
+// Unmount removes the mounts and binds at path from the table. If addr is
+// not empty only the mount of that address is removed.
+func (g *Gateway) Unmount(mpath, addr string) error {
+	mpath = path.Clean(mpath)
+	g.mu.Lock()
+	var found []chan struct{}
+	for key, m := range g.mounts {
+		if m.spec.Path == mpath && (addr == "" || m.spec.Addr == addr) {
+			found = append(found, m.stop)
+			delete(g.mounts, key)
+		}
+	}
+	for key, b := range g.binds {
+		if b.old == mpath && addr == "" {
+			found = append(found, b.stop)
+			delete(g.binds, key)
+		}
+	}
+	g.mu.Unlock()
+
+	if len(found) == 0 {
+		return warp9.ErrorCode(warp9.Enotexist)
+	}
+	for _, stop := range found {
+		close(stop)
+	}
+	return nil
+}
+
+// Stop unmounts every server and removes every bind.
+func (g *Gateway) Stop() {
+	g.mu.Lock()
+	mounts, binds := g.mounts, g.binds
+	g.mounts = make(map[string]*mount)
+	g.binds = make(map[string]*bind)
+	g.mu.Unlock()
+	for _, m := range mounts {
+		close(m.stop)
+	}
+	for _, b := range binds {
+		close(b.stop)
+	}
+}
+
+// validate spec, create its mount path and record it in the table
+func (g *Gateway) add(spec MountSpec) (*mount, error) {
+	if err := spec.check(); err != nil {
+		return nil, err
+	}
+	spec.Path = path.Clean(spec.Path)
+	flag, _ := ParseFlag(spec.Flag)
+	key := fmt.Sprintf("%s %s!%s %s", spec.Path, spec.Net, spec.Addr, spec.Aname)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.mounts[key]; ok {
+		return nil, warp9.ErrorMsg(warp9.Eexist, key)
+	}
+	if err := g.mkdirs(spec.Path); err != nil {
+		return nil, err
+	}
+	m := &mount{
+		spec:  spec,
+		flag:  flag,
+		since: time.Now(),
+		stop:  make(chan struct{}),
+	}
+	g.mounts[key] = m
+	return m, nil
+}
+
+// make the directories along mpath so it exists while its server is down
+func (g *Gateway) mkdirs(mpath string) error {
+	dir := g.root
+	for _, name := range strings.Split(mpath[1:], "/") {
+		item := dir.Children()[name]
+		if item == nil {
+			nd := wkit.NewDirItem(name)
+			dir.AddDirectory(nd)
+			item = nd
+		}
+		if dir = item.IsDirectory(); dir == nil {
+			return warp9.ErrorMsg(warp9.Enotdir, mpath)
+		}
+	}
+	return nil
+}
+
+// keep m bound into the namespace while its mount is up, until it is
+// removed from the table; the mount itself reconnects
+func (g *Gateway) supervise(m *mount) {
+	mt := wkit.MountPointRemount(m.spec.Net, m.spec.Addr, m.spec.Aname, m.spec.Msize, g.User, g.Remount)
+	mt.SetName(path.Base(m.spec.Path))
+	bound := false
+	for {
+		changed := mt.StateChanged()
+		state, _, err := mt.State()
+		switch {
+		case state == wkit.MountUp && !bound:
+			if err = g.Bind(mt, m.spec.Path, m.flag); err != nil {
+				warp9.Error("nspace: mount %v: %v", m.spec.String(), err)
+				g.setState(m, nil, err)
+				break
+			}
+			bound = true
+			g.setState(m, mt, nil)
+			warp9.Info("nspace: mounted %v", m.spec.String())
+		case state != wkit.MountUp && bound:
+			bound = false
+			g.Unbind(mt, m.spec.Path)
+			warp9.Error("nspace: lost %v: %v", m.spec.String(), err)
+			g.setState(m, nil, err)
+		case state != wkit.MountUp:
+			g.setState(m, nil, err)
+		}
+
+		select {
+		case <-changed:
+		case <-m.stop:
+			if bound {
+				g.Unbind(mt, m.spec.Path)
+			}
+			mt.Unmount()
+			return
+		}
+	}
+}
+
+// keep b bound until it is removed from the table. A bind that cannot be
+// made is retried when a mount comes up, or after a backoff as for the
+// mounts.
+func (g *Gateway) superviseBind(b *bind) {
+	backoff := g.backoff(0)
+	for {
+		g.mu.Lock()
+		up := g.up
+		g.mu.Unlock()
+
+		item, clnt, err := g.lookup(b.name)
+		if err != nil {
+			warp9.Debug("nspace: bind %s %s: %v", b.name, b.old, err)
+			select {
+			case <-b.stop:
+				return
+			case <-up:
+			case <-time.After(backoff):
+				backoff = g.backoff(backoff)
+			}
+			continue
+		}
+
+		backoff = g.backoff(0)
+		g.setBind(b, item)
+		warp9.Info("nspace: bound %s at %s", b.name, b.old)
+		var lost <-chan struct{}
+		if clnt != nil {
+			lost = clnt.Closed()
+		}
+		select {
+		case <-lost:
+			warp9.Error("nspace: lost bind %s %s", b.name, b.old)
+			g.setBind(b, nil)
+		case <-b.stop:
+			g.setBind(b, nil)
+			item.Clunk()
+			return
+		}
+	}
+}
+
+// the delay after d in the doubling backoff of the Remount policy; the
+// first delay for 0
+func (g *Gateway) backoff(d time.Duration) time.Duration {
+	min, max := g.Remount.MinBackoff, g.Remount.MaxBackoff
+	if min <= 0 {
+		min = wkit.DefaultRemount.MinBackoff
+	}
+	if max < min {
+		max = min
+	}
+	if d *= 2; d < min {
+		d = min
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+// record the object bound by b and rebuild the binds at b.old from the
+// reachable ones, in the order they were added, so the result does not
+// depend on which server came up first. Only the objects of the binds
+// are unbound; a mount at b.old stays in the union.
+func (g *Gateway) setBind(b *bind, item wkit.Item) {
+	g.bindMu.Lock()
+	defer g.bindMu.Unlock()
+
+	g.mu.Lock()
+	b.item = item
+	binds := []*bind{b} // b may be gone from the table already
+	for _, ob := range g.binds {
+		if ob.old == b.old && ob != b {
+			binds = append(binds, ob)
+		}
+	}
+	g.mu.Unlock()
+	sort.Slice(binds, func(i, j int) bool { return binds[i].seq < binds[j].seq })
+
+	for _, ob := range binds {
+		if ob.bound != nil {
+			g.Unbind(ob.bound, ob.old)
+			ob.bound = nil
+		}
+	}
+	for _, ob := range binds {
+		g.mu.Lock()
+		item := ob.item
+		g.mu.Unlock()
+		if item == nil {
+			continue
+		}
+		if err := g.Bind(item, ob.old, ob.flag); err != nil {
+			warp9.Error("nspace: bind %s %s: %v", ob.name, ob.old, err)
+			continue
+		}
+		ob.bound = item
+	}
+}
+
+// walk to the object at name. Below a mount point the object is walked
+// within the remote tree, and the client is that of the mount; other
+// names are walked in the gateway's own tree.
+func (g *Gateway) lookup(name string) (wkit.Item, *warp9.Clnt, error) {
+	best := ""
+	var up []*wkit.MountPoint
+	g.mu.Lock()
+	for _, m := range g.mounts {
+		p := m.spec.Path
+		if name != p && !strings.HasPrefix(name, p+"/") || len(p) < len(best) {
+			continue
+		}
+		if len(p) > len(best) {
+			best, up = p, nil
+		}
+		if m.mt != nil {
+			up = append(up, m.mt)
+		}
+	}
+	g.mu.Unlock()
+
+	names := strings.FieldsFunc(name[len(best):], func(r rune) bool { return r == '/' })
+	if best == "" {
+		if len(names) == 0 {
+			return g.GetRoot(), nil, nil
+		}
+		item, err := g.GetRoot().Walk(names)
+		return item, nil, err
+	}
+	var err error = warp9.ErrorMsg(warp9.Econn, best)
+	for _, mt := range up {
+		var item wkit.Item
+		if item, err = mt.Walk(names); err != nil {
+			continue
+		}
+		if clnt := mt.Clnt(); clnt != nil {
+			return item, clnt, nil
+		}
+		item.Clunk()
+		err = warp9.ErrorMsg(warp9.Econn, best)
+	}
+	return nil, nil, err
+}
+
+func (g *Gateway) setState(m *mount, mt *wkit.MountPoint, err error) {
+	g.mu.Lock()
+	m.mt = mt
+	m.err = err
+	m.since = time.Now()
+	if mt != nil {
+		close(g.up)
+		g.up = make(chan struct{})
+	}
+	g.mu.Unlock()
+}
+
+// Bytes reports the state of the mount table, one mount per line:
+//
+//	path net!addr aname flag state since [error]
+func (g *Gateway) Bytes() []byte {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	keys := make([]string, 0, len(g.mounts))
+	for k := range g.mounts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b bytes.Buffer
+	for _, k := range keys {
+		m := g.mounts[k]
+		state := stateDown
+		if m.mt != nil {
+			state = stateUp
+		}
+		flag := m.spec.Flag
+		if flag == "" {
+			flag = "repl"
+		}
+		fmt.Fprintf(&b, "%s %s!%s %s %s %s %s", m.spec.Path, m.spec.Net, m.spec.Addr,
+			quoteEmpty(m.spec.Aname), flag, state, m.since.UTC().Format(time.RFC3339))
+		if m.mt == nil && m.err != nil {
+			fmt.Fprintf(&b, " %q", m.err.Error())
+		}
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+//
+// ctl object
+//
+
+// the ctl object; commands:
+//
+//	mount path net addr [aname [flag]]
+//	unmount path [addr]
+//	status
+func (g *Gateway) newCtl() *wkit.Command {
+	return wkit.NewCommand("ctl", map[string]wkit.CommandFct{
+		"mount":   ctlMount,
+		"unmount": ctlUnmount,
+		"status":  ctlStatus,
+	}, g)
+}
+
+func ctlMount(ctx wkit.CmdCtx, cmd *wkit.Command, name string, args []byte) error {
+	g := ctx.(*Gateway)
+	f := strings.Fields(string(args))
+	if len(f) < 3 || len(f) > 5 {
+		return warp9.ErrorMsg(warp9.Einval, "usage: mount path net addr [aname [flag]]")
+	}
+	spec := MountSpec{Path: f[0], Net: f[1], Addr: f[2]}
+	if len(f) > 3 && f[3] != "''" {
+		spec.Aname = f[3]
+	}
+	if len(f) > 4 {
+		spec.Flag = f[4]
+	}
+	if err := g.Mount(spec); err != nil {
+		return warp9.ErrorMsg(warp9.Einval, err.Error())
+	}
+	cmd.SetBuffer([]byte("ok\n"))
+	return nil
+}
+
+func ctlUnmount(ctx wkit.CmdCtx, cmd *wkit.Command, name string, args []byte) error {
+	g := ctx.(*Gateway)
+	f := strings.Fields(string(args))
+	if len(f) < 1 || len(f) > 2 {
+		return warp9.ErrorMsg(warp9.Einval, "usage: unmount path [addr]")
+	}
+	addr := ""
+	if len(f) > 1 {
+		addr = f[1]
+	}
+	if err := g.Unmount(f[0], addr); err != nil {
+		return err
+	}
+	cmd.SetBuffer([]byte("ok\n"))
+	return nil
+}
+
+func ctlStatus(ctx wkit.CmdCtx, cmd *wkit.Command, name string, args []byte) error {
+	cmd.SetBuffer(ctx.(*Gateway).Bytes())
+	return nil
+}
@@ -0,0 +1,332 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package nspace
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lavaorg/warp/warp9"
+	"github.com/lavaorg/warp/wkit"
+)
+
+// a backend object server whose connections can be dropped
+type backend struct {
+	sync.Mutex
+	l     net.Listener
+	conns []net.Conn
+}
+
+func startBackend(t *testing.T, l net.Listener, file, data string) *backend {
+	root := wkit.NewDirItem("/")
+	item := wkit.NewItem(file)
+	item.SetBuffer([]byte(data))
+	root.AddItem(item)
+	srv := wkit.NewServer("backend", 0, root)
+	if !srv.Start(srv) {
+		t.Fatal("unable to start backend")
+	}
+	b := &backend{l: l}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			b.Lock()
+			b.conns = append(b.conns, c)
+			b.Unlock()
+			srv.NewConn(c)
+		}
+	}()
+	return b
+}
+
+// drop every connection, as if the backend restarted
+func (b *backend) drop() {
+	b.Lock()
+	for _, c := range b.conns {
+		c.Close()
+	}
+	b.conns = nil
+	b.Unlock()
+}
+
+func listen(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+// wait up to 5 seconds for cond, failing the test if it never holds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// wait until the status line of mpath reports state
+func waitState(t *testing.T, g *Gateway, mpath, state string) {
+	t.Helper()
+	waitFor(t, mpath+" to be "+state, func() bool {
+		for _, line := range strings.Split(string(g.Bytes()), "\n") {
+			f := strings.Fields(line)
+			if len(f) > 4 && f[0] == mpath && f[4] == state {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// wait until p holds want through c9
+func waitGet(t *testing.T, c9 *warp9.Clnt, p, want string) {
+	t.Helper()
+	waitFor(t, p+" to hold "+want, func() bool {
+		data, _, err := c9.Get(p, 0)
+		return err == nil && string(data) == want
+	})
+}
+
+// the client of the mount at mpath; nil while it is down
+func clntAt(g *Gateway, mpath string) *warp9.Clnt {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, m := range g.mounts {
+		if m.spec.Path == mpath && m.mt != nil {
+			return m.mt.Clnt()
+		}
+	}
+	return nil
+}
+
+func TestGateway(t *testing.T) {
+	la := listen(t)
+	a := startBackend(t, la, "hello", "from a")
+
+	// b's address is reserved but nothing serves it yet
+	lb := listen(t)
+	baddr := lb.Addr().String()
+
+	table, err := LoadTable(strings.NewReader(`{"mounts": [
+		{"path": "/svc/a", "addr": "` + la.Addr().String() + `"},
+		{"path": "/svc/b", "addr": "` + baddr + `", "flag": "after"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := warp9.Identity.User(1)
+	g, err := NewGateway("gateway", 0, table, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Remount = wkit.RemountPolicy{MinBackoff: 20 * time.Millisecond, MaxBackoff: 20 * time.Millisecond}
+	if !g.Start() {
+		t.Fatal("unable to start gateway")
+	}
+	defer g.Stop()
+	lg := listen(t)
+	go g.StartListener(lg)
+
+	c9, err := warp9.Mount("tcp", lg.Addr().String(), "/", 8192, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c9.Unmount()
+	get := func(p string) string {
+		data, _, err := c9.Get(p, 0)
+		if err != nil {
+			return err.Error()
+		}
+		return string(data)
+	}
+
+	waitState(t, g, "/svc/a", stateUp)
+	if got := get("/svc/a/hello"); got != "from a" {
+		t.Errorf("get through gateway: %q", got)
+	}
+	if _, err := c9.Stat("/svc/b"); err != nil {
+		t.Errorf("path of a down mount should exist: %v", err)
+	}
+
+	// b comes up later
+	startBackend(t, lb, "world", "from b")
+	waitState(t, g, "/svc/b", stateUp)
+	if got := get("/svc/b/world"); got != "from b" {
+		t.Errorf("late backend: %q", got)
+	}
+
+	// a drops its connections and is remounted
+	old := clntAt(g, "/svc/a")
+	a.drop()
+	waitFor(t, "/svc/a to be remounted", func() bool { c := clntAt(g, "/svc/a"); return c != nil && c != old })
+	if got := get("/svc/a/hello"); got != "from a" {
+		t.Errorf("after remount: %q", got)
+	}
+
+	// change the table through the ctl object
+	ctl, err := c9.Open("/ctl", warp9.ORDWR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctl.Close()
+	if _, err := ctl.Write([]byte("unmount /svc/a")); err != nil {
+		t.Fatalf("ctl unmount: %v", err)
+	}
+	if _, err := ctl.Write([]byte("mount /svc/c tcp " + baddr)); err != nil {
+		t.Fatalf("ctl mount: %v", err)
+	}
+	waitState(t, g, "/svc/c", stateUp)
+	if got := get("/svc/c/world"); got != "from b" {
+		t.Errorf("mounted through ctl: %q", got)
+	}
+	if _, err := c9.Stat("/svc/a/hello"); err == nil {
+		t.Error("unmounted server still visible")
+	}
+	status := get("/status")
+	if strings.Contains(status, "/svc/a ") || !strings.Contains(status, "/svc/c ") {
+		t.Errorf("status:\n%s", status)
+	}
+}
+
+func TestLoadNamespace(t *testing.T) {
+	la := listen(t)
+	a := startBackend(t, la, "hello", "from a")
+	lb := listen(t)
+	startBackend(t, lb, "world", "from b")
+	_, bport, _ := net.SplitHostPort(lb.Addr().String())
+
+	user := warp9.Identity.User(1)
+	g, err := NewGateway("gateway", 0, nil, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Remount = wkit.RemountPolicy{MinBackoff: 20 * time.Millisecond, MaxBackoff: 20 * time.Millisecond}
+	// loaded before Start; nothing is mounted until then
+	err = g.LoadNamespace(strings.NewReader(`
+		# two servers and a union of both
+		mount ` + la.Addr().String() + ` /svc/a
+		mount tcp!127.0.0.1!` + bport + ` /svc/b
+		bind /svc/a /all
+		bind -a /svc/b /all
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !g.Start() {
+		t.Fatal("unable to start gateway")
+	}
+	defer g.Stop()
+	lg := listen(t)
+	go g.StartListener(lg)
+
+	c9, err := warp9.Mount("tcp", lg.Addr().String(), "/", 8192, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c9.Unmount()
+	waitGet(t, c9, "/all/hello", "from a")
+	waitGet(t, c9, "/all/world", "from b")
+
+	// the bind follows its server through a remount
+	a.drop()
+	time.Sleep(50 * time.Millisecond)
+	waitGet(t, c9, "/all/hello", "from a")
+
+	if err := g.Unmount("/all", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c9.Stat("/all/world"); err == nil {
+		t.Error("unbound object still visible")
+	}
+
+	if err := g.LoadNamespace(strings.NewReader("bind /svc/a")); err == nil {
+		t.Error("bad description accepted")
+	}
+}
+
+// a bind onto a mounted path leaves the mount in the union as its server
+// comes and goes
+func TestMountAndBind(t *testing.T) {
+	// a's address is reserved but nothing serves it yet
+	la := listen(t)
+	aaddr := la.Addr().String()
+	lu := listen(t)
+	startBackend(t, lu, "world", "from u")
+
+	user := warp9.Identity.User(1)
+	g, err := NewGateway("gateway", 0, nil, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Remount = wkit.RemountPolicy{MinBackoff: 20 * time.Millisecond, MaxBackoff: 20 * time.Millisecond}
+	err = g.LoadNamespace(strings.NewReader(`
+		mount ` + aaddr + ` /svc/a
+		mount ` + lu.Addr().String() + ` /u
+		bind -a /svc/a /u
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !g.Start() {
+		t.Fatal("unable to start gateway")
+	}
+	defer g.Stop()
+	lg := listen(t)
+	go g.StartListener(lg)
+
+	c9, err := warp9.Mount("tcp", lg.Addr().String(), "/", 8192, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c9.Unmount()
+	waitGet(t, c9, "/u/world", "from u")
+
+	// the bind is made once a is up, and undone when it is lost
+	a := startBackend(t, la, "hello", "from a")
+	waitGet(t, c9, "/u/hello", "from a")
+	waitGet(t, c9, "/u/world", "from u")
+	a.drop()
+	waitGet(t, c9, "/u/hello", "from a")
+	waitGet(t, c9, "/u/world", "from u")
+}
+
+func TestFormatFlag(t *testing.T) {
+	for _, flag := range []uint32{warp9.MREPL, warp9.MBEFORE, warp9.MAFTER | warp9.MCREATE, warp9.MCREATE} {
+		if got, err := ParseFlag(FormatFlag(flag)); err != nil || got != flag {
+			t.Errorf("%#x: %q gives %#x, %v", flag, FormatFlag(flag), got, err)
+		}
+	}
+}
+
+func TestParseFlag(t *testing.T) {
+	tests := []struct {
+		in   string
+		want uint32
+		ok   bool
+	}{
+		{"", warp9.MREPL, true},
+		{"repl", warp9.MREPL, true},
+		{"before", warp9.MBEFORE, true},
+		{"after,create", warp9.MAFTER | warp9.MCREATE, true},
+		{"before,after", 0, false},
+		{"sideways", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseFlag(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseFlag(%q) = %v, %v", tt.in, got, err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package nspace
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/lavaorg/warp/warp9"
+)
+
+// LoadNamespace adds the mounts and binds of a namespace description (see
+// warp9.ParseNamespace) to the gateway, in order. Like every mount of the
+// gateway they are supervised, so the servers need not be reachable yet.
+func (g *Gateway) LoadNamespace(r io.Reader) error {
+	entries, err := warp9.ParseNamespace(r)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		switch e.Op {
+		case "mount":
+			err = g.Mount(MountSpec{
+				Path:  e.Old,
+				Net:   e.Net,
+				Addr:  e.Addr,
+				Aname: e.Aname,
+				Flag:  FormatFlag(e.Flag),
+			})
+		case "bind":
+			err = g.BindPath(e.Name, e.Old, e.Flag)
+		}
+		if err != nil {
+			return fmt.Errorf("namespace line %d: %w", e.Line, err)
+		}
+	}
+	return nil
+}
+
+// LoadNamespaceFile adds the namespace description in the named file.
+func (g *Gateway) LoadNamespaceFile(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return g.LoadNamespace(f)
+}
+
+// FormatFlag is the inverse of ParseFlag; MREPL gives "".
+func FormatFlag(flag uint32) string {
+	var f []string
+	switch {
+	case flag&warp9.MBEFORE != 0:
+		f = append(f, "before")
+	case flag&warp9.MAFTER != 0:
+		f = append(f, "after")
+	}
+	if flag&warp9.MCREATE != 0 {
+		if len(f) == 0 {
+			f = append(f, "repl")
+		}
+		f = append(f, "create")
+	}
+	return strings.Join(f, ",")
+}
@@ -0,0 +1,118 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package nspace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/lavaorg/warp/warp9"
+)
+
+// A Table is the declarative mount table of a Gateway.
+type Table struct {
+	Mounts []MountSpec `json:"mounts"`
+}
+
+// A MountSpec describes one remote object server and where it is placed
+// in the gateway's namespace.
+type MountSpec struct {
+	Path  string `json:"path"`            // where the server appears, e.g. "/svc/db"
+	Net   string `json:"net,omitempty"`   // network, per net.Dial; default "tcp"
+	Addr  string `json:"addr"`            // network address, per net.Dial
+	Aname string `json:"aname,omitempty"` // attach name on the remote server
+	Msize uint32 `json:"msize,omitempty"` // message size to negotiate; 0 for the default
+	Flag  string `json:"flag,omitempty"`  // repl (default), before or after; ",create" may be appended
+}
+
+// LoadTable reads a JSON mount table.
+func LoadTable(r io.Reader) (*Table, error) {
+	var t Table
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&t); err != nil {
+		return nil, err
+	}
+	for i := range t.Mounts {
+		if err := t.Mounts[i].check(); err != nil {
+			return nil, err
+		}
+	}
+	return &t, nil
+}
+
+// LoadTableFile reads a JSON mount table from the named file.
+func LoadTableFile(name string) (*Table, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadTable(f)
+}
+
+// validate a spec and fill in defaults
+func (ms *MountSpec) check() error {
+	if ms.Net == "" {
+		ms.Net = "tcp"
+	}
+	if !strings.HasPrefix(ms.Path, "/") || ms.Path == "/" {
+		return fmt.Errorf("mount %q: path must be absolute and not the root", ms.Path)
+	}
+	if ms.Addr == "" {
+		return fmt.Errorf("mount %q: no address", ms.Path)
+	}
+	if _, err := ParseFlag(ms.Flag); err != nil {
+		return fmt.Errorf("mount %q: %v", ms.Path, err)
+	}
+	return nil
+}
+
+// ParseFlag converts a textual bind flag ("repl", "before", "after",
+// optionally followed by ",create") to the warp9 M* flags.
+func ParseFlag(s string) (uint32, error) {
+	var flag uint32
+	for i, f := range strings.Split(s, ",") {
+		switch strings.TrimSpace(f) {
+		case "", "repl":
+			if i > 0 && f != "" {
+				return 0, fmt.Errorf("bad flag %q", s)
+			}
+		case "before":
+			flag |= warp9.MBEFORE
+		case "after":
+			flag |= warp9.MAFTER
+		case "create":
+			flag |= warp9.MCREATE
+		default:
+			return 0, fmt.Errorf("bad flag %q", s)
+		}
+	}
+	if flag&warp9.MORDER == warp9.MORDER {
+		return 0, fmt.Errorf("bad flag %q", s)
+	}
+	return flag, nil
+}
+
+// String formats the spec the way the ctl mount command accepts it.
+func (ms *MountSpec) String() string {
+	s := fmt.Sprintf("%s %s %s", ms.Path, ms.Net, ms.Addr)
+	if ms.Aname != "" || ms.Flag != "" {
+		s += " " + quoteEmpty(ms.Aname)
+	}
+	if ms.Flag != "" {
+		s += " " + ms.Flag
+	}
+	return s
+}
+
+func quoteEmpty(s string) string {
+	if s == "" {
+		return "''"
+	}
+	return s
+}
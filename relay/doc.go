@@ -0,0 +1,20 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+/*
+Relay lets clients reach Warp9 object servers that cannot accept
+connections, such as devices behind a NAT.
+
+A server dials out to the relay's registration address and serves its tree
+on that connection:
+
+	srv.InitiateConn("tcp", "relay.example:9091", false)
+
+The relay mounts it and registers it under the name of its root. A client
+then mounts the relay's client address with that name as the aname:
+
+	c9, err := warp9.Mount("tcp", "relay.example:9090", "edge17", 0, user)
+
+Mounting with an empty aname gives a directory of the registered servers.
+*/
+package relay
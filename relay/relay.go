@@ -0,0 +1,81 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package relay
+
+import (
+	"net"
+
+	"github.com/lavaorg/warp/warp9"
+	"github.com/lavaorg/warp/wkit"
+)
+
+// A Relay connects clients to object servers that cannot be dialed, for
+// example devices behind a NAT. The servers dial out to the relay (see
+// warp9.Srv.InitiateConn) and are registered under the name of the root
+// they serve. A client mounting the relay with that name as its aname gets
+// a session on the server; with an empty aname it gets a directory of the
+// registered servers.
+//
+// Each client session is forwarded through the relay's own connection to
+// the server, so clients never share fids or tags with each other.
+type Relay struct {
+	*wkit.ServerController
+	Registry *wkit.Registry // the servers that dialed in
+}
+
+// NewRelay returns a relay attaching to the servers as user.
+func NewRelay(id string, debuglevel int, user warp9.User) *Relay {
+	reg := wkit.NewRegistry("/", "", 0, user)
+	return &Relay{
+		ServerController: wkit.NewServer(id, debuglevel, reg.Dir()),
+		Registry:         reg,
+	}
+}
+
+// Start starts the warp9 server of the relay.
+func (r *Relay) Start() bool {
+	return r.ServerController.Start(r)
+}
+
+// ServeServers registers the servers dialing in on l until l is closed.
+func (r *Relay) ServeServers(l net.Listener) error {
+	return r.Registry.Serve(l)
+}
+
+// ServeClients serves the clients connecting on l until l is closed.
+func (r *Relay) ServeClients(l net.Listener) error {
+	return r.StartListener(l)
+}
+
+// Close disconnects every registered server.
+func (r *Relay) Close() {
+	r.Registry.Close()
+}
+
+// Attach gives the client the root of the server registered as aname. An
+// empty aname, or a tree added with AddTree, is served by the relay itself.
+func (r *Relay) Attach(req *warp9.SrvReq) {
+	aname := req.Tc.Aname
+	if aname == "" || aname == "/" || r.Tree(aname) != nil {
+		r.ServerController.Attach(req)
+		return
+	}
+	mt := r.Registry.Lookup(aname)
+	if mt == nil {
+		req.RespondError(warp9.ErrorMsg(warp9.Enotexist, aname))
+		return
+	}
+	if req.Afid != nil {
+		req.RespondError(warp9.ErrorCode(warp9.Enoauth))
+		return
+	}
+	root, err := mt.AttachRoot()
+	if err != nil {
+		req.RespondError(err)
+		return
+	}
+	req.Fid.Aux = root
+	qid := root.GetQid()
+	req.RespondRattach(&qid)
+}
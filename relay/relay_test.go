@@ -0,0 +1,145 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package relay
+
+import (
+	"errors"
+	"io/fs"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lavaorg/warp/warp9"
+	"github.com/lavaorg/warp/wkit"
+)
+
+func listen(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+// wait up to 5 seconds for cond, failing the test if it never holds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// start a server named name that dials out to the relay
+func dialIn(t *testing.T, addr, name string) {
+	root := wkit.NewDirItem(name)
+	id := wkit.NewItem("id")
+	id.SetBuffer([]byte(name))
+	root.AddItem(id)
+	srv := wkit.NewServer(name, 0, root)
+	if !srv.Start(srv) {
+		t.Fatal("unable to start server")
+	}
+	if err := srv.InitiateConn("tcp", addr, false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRelay(t *testing.T) {
+	user := warp9.Identity.User(1)
+	r := NewRelay("relay", 0, user)
+	if !r.Start() {
+		t.Fatal("unable to start relay")
+	}
+	defer r.Close()
+	lreg := listen(t)
+	defer lreg.Close()
+	go r.ServeServers(lreg)
+	lcl := listen(t)
+	defer lcl.Close()
+	go r.ServeClients(lcl)
+
+	dialIn(t, lreg.Addr().String(), "edge1")
+	dialIn(t, lreg.Addr().String(), "edge2")
+	waitFor(t, "both servers", func() bool { return strings.Join(r.Registry.Servers(), " ") == "edge1 edge2" })
+
+	mount := func(aname string) (*warp9.Clnt, error) {
+		return warp9.Mount("tcp", lcl.Addr().String(), aname, 8192, user)
+	}
+	for _, name := range []string{"edge1", "edge2"} {
+		c9, err := mount(name)
+		if err != nil {
+			t.Fatalf("mount %s: %v", name, err)
+		}
+		// two sessions on the same server are independent
+		c9b, err := mount(name)
+		if err != nil {
+			t.Fatalf("mount %s: %v", name, err)
+		}
+		for _, c := range []*warp9.Clnt{c9, c9b} {
+			if data, _, err := c.Get("/../id", 0); err != nil || string(data) != name {
+				t.Errorf("%s: %q, %v", name, data, err)
+			}
+		}
+		c9.Unmount()
+		if data, _, err := c9b.Get("/id", 0); err != nil || string(data) != name {
+			t.Errorf("%s after the other session ended: %q, %v", name, data, err)
+		}
+		c9b.Unmount()
+	}
+
+	c9, err := mount("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c9.Unmount()
+	if dirs, err := c9.ReadDir("/"); err != nil || len(dirs) != 2 {
+		t.Errorf("directory of servers: %v, %v", dirs, err)
+	}
+
+	if _, err := mount("edge3"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("unknown server: %v", err)
+	}
+
+	// a server dialing in under a name in use is refused
+	root := wkit.NewDirItem("edge1")
+	id := wkit.NewItem("id")
+	id.SetBuffer([]byte("impostor"))
+	root.AddItem(id)
+	imp := &closeWatch{ServerController: wkit.NewServer("impostor", 0, root), closed: make(chan struct{})}
+	if !imp.Start(imp) {
+		t.Fatal("unable to start server")
+	}
+	if err := imp.InitiateConn("tcp", lreg.Addr().String(), false); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-imp.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("duplicate server not refused")
+	}
+	c9, err = mount("edge1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c9.Unmount()
+	if data, _, err := c9.Get("/id", 0); err != nil || string(data) != "edge1" {
+		t.Errorf("edge1 after a duplicate: %q, %v", data, err)
+	}
+}
+
+// a server noting when its connection is closed
+type closeWatch struct {
+	*wkit.ServerController
+	closed chan struct{}
+}
+
+func (s *closeWatch) ConnClosed(conn *warp9.Conn) {
+	s.ServerController.ConnClosed(conn)
+	close(s.closed)
+}
@@ -0,0 +1,36 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+/*
+Tap records Warp9 sessions. A Proxy sits between clients and a server,
+passing the bytes through untouched, and logs every message it sees
+without taking part in the sessions: neither end needs to be changed or
+have its debug flags set.
+
+	w := tap.NewWriter(f)
+	p := tap.NewProxy("tcp", "device:9090", w)
+	p.ListenAndServe("tcp", ":9090")
+
+The log has a line per message, and one as each session opens and closes:
+
+	2026-10-19T09:12:01.759539013Z 1 + 127.0.0.1:60502
+	2026-10-19T09:12:01.759712563Z 1 > Tversion tag 65535 msize 8216 version 'Warp9.0'	1400000064ffff18...
+	2026-10-19T09:12:01.759821833Z 1 < Rversion tag 65535 msize 8192 version 'Warp9.0'	1400000065ffff00...
+	...
+	2026-10-19T09:12:01.760009657Z 1 > Tread tag 0 fid 1 offset 0 count 8168	1700000074000001...
+	2026-10-19T09:12:01.760039162Z 1 < Rread tag 0 count 2	0d00000075000002...
+	2026-10-19T09:12:01.760161598Z 1 - eof
+
+Each line holds the time, the session number, the direction (> to the
+server, < to the client) and the message as Fcall.String shows it,
+then, after a tab, the message itself in hex. The hex makes the log
+replayable: a Reader gives back each Record, and Record.Fcall decodes it.
+
+A Replayer sends the requests of a log to a server again and reports
+where the replies differ from those recorded, ignoring the fields that
+are expected to change, such as times:
+
+	rp := &tap.Replayer{Net: "tcp", Addr: "staging:9090", Ignore: []string{"*time"}}
+	divs, err := rp.Replay(tap.NewReader(f))
+*/
+package tap
@@ -0,0 +1,149 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package tap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/lavaorg/warp/warp9"
+)
+
+// MaxMessage is the largest message the proxy decodes. A size outside
+// what warp9 can send means the stream is not warp9, or is no longer in
+// step; the rest of it is passed through without being logged.
+const MaxMessage = 16 << 20
+
+// the size of the smallest message: size[4] type[1] tag[2]
+const minMessage = 7
+
+// A Proxy passes the sessions of clients through to a server, logging
+// the messages each way.
+type Proxy struct {
+	Net, Addr string // the server
+	Log       *Writer
+
+	mu    sync.Mutex
+	nsess int
+}
+
+// NewProxy returns a proxy to the server at the network address addr,
+// logging to log.
+func NewProxy(ntype, addr string, log *Writer) *Proxy {
+	return &Proxy{Net: ntype, Addr: addr, Log: log}
+}
+
+// ListenAndServe serves the clients connecting on the network address
+// addr.
+func (p *Proxy) ListenAndServe(ntype, addr string) error {
+	l, err := net.Listen(ntype, addr)
+	if err != nil {
+		return err
+	}
+	return p.Serve(l)
+}
+
+// Serve serves the clients connecting on l until l is closed.
+func (p *Proxy) Serve(l net.Listener) error {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go p.serveConn(c)
+	}
+}
+
+func (p *Proxy) record(sess int, ev Event, text string, pkt []byte) {
+	r := &Record{Time: time.Now(), Session: sess, Event: ev, Text: text, Pkt: pkt}
+	if err := p.Log.WriteRecord(r); err != nil {
+		warp9.Error("tap: %v", err)
+	}
+}
+
+// pass the session of the client c through to the server
+func (p *Proxy) serveConn(c net.Conn) {
+	p.mu.Lock()
+	p.nsess++
+	sess := p.nsess
+	p.mu.Unlock()
+
+	p.record(sess, Open, c.RemoteAddr().String(), nil)
+	up, err := net.Dial(p.Net, p.Addr)
+	if err != nil {
+		c.Close()
+		p.record(sess, Close, err.Error(), nil)
+		return
+	}
+	done := make(chan error, 2)
+	go func() { done <- p.pipe(sess, Tmsg, c, up) }()
+	go func() { done <- p.pipe(sess, Rmsg, up, c) }()
+	err = <-done
+	c.Close()
+	up.Close()
+	<-done
+
+	why := "eof"
+	if err != nil && !errors.Is(err, io.EOF) {
+		why = err.Error()
+	}
+	p.record(sess, Close, why, nil)
+}
+
+// copy the messages from one end to the other, logging each as ev
+// before passing it on; a reply is never logged before its request
+func (p *Proxy) pipe(sess int, ev Event, from, to net.Conn) error {
+	r := bufio.NewReader(from)
+	for {
+		pkt, err := readMessage(r)
+		if errors.Is(err, errNotWarp9) {
+			p.record(sess, Note, fmt.Sprintf("%c stream %v; passing it through", ev, err), nil)
+			if _, err := to.Write(pkt); err != nil {
+				return err
+			}
+			_, err := io.Copy(to, r)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		text := ""
+		if fc, err, _ := warp9.Unpack(pkt); err != nil {
+			text = fmt.Sprintf("undecoded: %v", err)
+		} else {
+			text = fc.String()
+		}
+		p.record(sess, ev, text, pkt)
+		if _, err := to.Write(pkt); err != nil {
+			return err
+		}
+	}
+}
+
+var errNotWarp9 = errors.New("is not warp9")
+
+// read the next message from r. If its size is not one warp9 can send,
+// the error wraps errNotWarp9 and the bytes read are returned.
+func readMessage(r io.Reader) ([]byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	size := binary.LittleEndian.Uint32(hdr[:])
+	if size < minMessage || size > MaxMessage {
+		return hdr[:], fmt.Errorf("%w (message size %d)", errNotWarp9, size)
+	}
+	pkt := make([]byte, size)
+	copy(pkt, hdr[:])
+	if _, err := io.ReadFull(r, pkt[len(hdr):]); err != nil {
+		return nil, err
+	}
+	return pkt, nil
+}
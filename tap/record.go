@@ -0,0 +1,147 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package tap
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lavaorg/warp/warp9"
+)
+
+// An Event is the kind of a Record.
+type Event byte
+
+const (
+	Tmsg  Event = '>' // a message to the server
+	Rmsg  Event = '<' // a message to the client
+	Open  Event = '+' // a session opened; the text is the client's address
+	Close Event = '-' // a session closed; the text says why
+	Note  Event = '!' // something else worth knowing about a session
+)
+
+// A Record is a line of a log.
+type Record struct {
+	Time    time.Time
+	Session int // numbered from 1 in the order the sessions opened
+	Event   Event
+	Text    string // what happened; for messages, Fcall.String
+	Pkt     []byte // the message as sent, for Tmsg and Rmsg
+}
+
+// Fcall decodes the message of the record.
+func (r *Record) Fcall() (*warp9.Fcall, error) {
+	if r.Event != Tmsg && r.Event != Rmsg {
+		return nil, fmt.Errorf("%c record holds no message", r.Event)
+	}
+	fc, err, _ := warp9.Unpack(r.Pkt)
+	return fc, err
+}
+
+// String returns the record as a line of the log, without the newline.
+func (r *Record) String() string {
+	// a record is a line: no newlines, and no tabs before the hex
+	text := strings.NewReplacer("\n", " ", "\r", " ", "\t", " ").Replace(r.Text)
+	s := fmt.Sprintf("%s %d %c %s", r.Time.UTC().Format(time.RFC3339Nano), r.Session, r.Event, text)
+	if r.Event == Tmsg || r.Event == Rmsg {
+		s += "\t" + hex.EncodeToString(r.Pkt)
+	}
+	return s
+}
+
+// ParseRecord parses a line of a log.
+func ParseRecord(line string) (*Record, error) {
+	f := strings.SplitN(line, " ", 4)
+	if len(f) < 3 {
+		return nil, fmt.Errorf("short record %q", line)
+	}
+	t, err := time.Parse(time.RFC3339Nano, f[0])
+	if err != nil {
+		return nil, err
+	}
+	sess, err := strconv.Atoi(f[1])
+	if err != nil {
+		return nil, fmt.Errorf("bad session %q", f[1])
+	}
+	if len(f[2]) != 1 || !strings.Contains("><+-!", f[2]) {
+		return nil, fmt.Errorf("bad event %q", f[2])
+	}
+	r := &Record{Time: t, Session: sess, Event: Event(f[2][0])}
+	if len(f) == 4 {
+		r.Text = f[3]
+	}
+	if r.Event == Tmsg || r.Event == Rmsg {
+		n := strings.LastIndexByte(r.Text, '\t')
+		if n < 0 {
+			return nil, fmt.Errorf("message record without its message")
+		}
+		if r.Pkt, err = hex.DecodeString(r.Text[n+1:]); err != nil {
+			return nil, err
+		}
+		r.Text = r.Text[:n]
+	}
+	return r, nil
+}
+
+// A Writer writes the records of a log; it may be shared by the
+// goroutines of many sessions.
+type Writer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriter returns a writer of the log w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// WriteRecord writes r as a line.
+func (w *Writer) WriteRecord(r *Record) error {
+	line := r.String() + "\n"
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := io.WriteString(w.w, line)
+	return err
+}
+
+// A Reader reads the records of a log.
+type Reader struct {
+	scan *bufio.Scanner
+	line int
+}
+
+// NewReader returns a reader of the log r.
+func NewReader(r io.Reader) *Reader {
+	scan := bufio.NewScanner(r)
+	// lines hold whole messages, in hex
+	scan.Buffer(make([]byte, 64*1024), 2*MaxMessage+4096)
+	return &Reader{scan: scan}
+}
+
+// ReadRecord returns the next record, or io.EOF at the end of the log.
+// Blank lines are skipped.
+func (rd *Reader) ReadRecord() (*Record, error) {
+	for rd.scan.Scan() {
+		rd.line++
+		line := rd.scan.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		r, err := ParseRecord(line)
+		if err != nil {
+			return nil, fmt.Errorf("tap line %d: %v", rd.line, err)
+		}
+		return r, nil
+	}
+	if err := rd.scan.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
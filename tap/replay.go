@@ -0,0 +1,419 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package tap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lavaorg/warp/warp9"
+)
+
+// DefaultTimeout is how long a Replayer waits for a reply.
+const DefaultTimeout = 5 * time.Second
+
+// A Replayer replays the sessions of a log against a server and compares
+// the replies with those recorded.
+//
+// Each session of the log gets a connection of its own, on which its
+// T-messages are sent as recorded, tags and fids included. By default
+// each message is sent once the reply to the one before it has come, so
+// the server sees the messages in the order of the log; a Paced replay
+// sends them with the gaps between them recorded instead, without
+// waiting.
+//
+// The replies are compared field by field. Fields are named as the
+// message shows them, such as count, iounit or qid.path; Rstat fields
+// are under stat, as in stat.mtime, and the entries of a directory read
+// under dir and their name, in any order, as in dir.temp.length. The
+// data of other reads is the field data.
+type Replayer struct {
+	Net, Addr string // the server
+	Paced     bool
+	Ignore    []string      // path.Match patterns of the fields not compared, such as *.mtime
+	Timeout   time.Duration // how long to wait for a reply; DefaultTimeout if 0
+}
+
+// A Divergence is a reply that was not as recorded.
+type Divergence struct {
+	Session int
+	Request *Record      // the T-message; nil for a reply to no request
+	Want    *Record      // the reply recorded; nil if there was none
+	Got     *warp9.Fcall // the reply; nil if none came
+	Diffs   []string     // for replies of both, the fields that differ
+}
+
+func (d *Divergence) String() string {
+	req := "unexpected reply"
+	if d.Request != nil {
+		req = d.Request.Text
+	}
+	s := fmt.Sprintf("session %d: %s: ", d.Session, req)
+	switch {
+	case d.Got == nil:
+		return s + "no reply"
+	case d.Want == nil:
+		return s + "got " + d.Got.String()
+	}
+	return s + strings.Join(d.Diffs, "; ")
+}
+
+// a request to replay, with the reply recorded for it
+type request struct {
+	rec  *Record
+	tag  uint16
+	want *Record       // nil if none was recorded
+	dir  bool          // a read of a directory
+	done chan struct{} // closed as the reply comes
+}
+
+// a session being replayed
+type replaySession struct {
+	id      int
+	conn    net.Conn
+	mu      sync.Mutex
+	waiting map[uint16][]*request // requests sent, by tag
+	closed  bool
+}
+
+// Replay replays the records of rd, returning the divergences found in
+// the order the requests were made.
+func (rp *Replayer) Replay(rd *Reader) ([]*Divergence, error) {
+	var recs []*Record
+	for {
+		r, err := rd.ReadRecord()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, r)
+	}
+	reqs, err := requests(recs)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := rp.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	var (
+		mu    sync.Mutex
+		divs  []*Divergence
+		order = make(map[*Record]int) // of the requests, to sort the divergences
+	)
+	diverged := func(d *Divergence) {
+		mu.Lock()
+		divs = append(divs, d)
+		mu.Unlock()
+	}
+	for i, r := range recs {
+		order[r] = i
+	}
+
+	sessions := make(map[int]*replaySession)
+	defer func() {
+		for _, s := range sessions {
+			s.conn.Close()
+		}
+	}()
+	// wait for the replies to the requests of s, then end it
+	finish := func(s *replaySession) {
+		s.mu.Lock()
+		var pending []*request
+		for _, q := range s.waiting {
+			pending = append(pending, q...)
+		}
+		s.mu.Unlock()
+		deadline := time.After(timeout)
+		for _, q := range pending {
+			select {
+			case <-q.done:
+			case <-deadline:
+			}
+		}
+		s.mu.Lock()
+		s.closed = true
+		for _, q := range s.waiting {
+			for _, r := range q {
+				if r.want != nil {
+					diverged(&Divergence{Session: s.id, Request: r.rec, Want: r.want})
+				}
+			}
+		}
+		s.waiting = nil
+		s.mu.Unlock()
+		s.conn.Close()
+		delete(sessions, s.id)
+	}
+
+	start := time.Now()
+	for _, r := range recs {
+		if rp.Paced {
+			time.Sleep(time.Until(start.Add(r.Time.Sub(recs[0].Time))))
+		}
+		switch r.Event {
+		case Close:
+			if s := sessions[r.Session]; s != nil {
+				finish(s)
+			}
+		case Tmsg:
+			s := sessions[r.Session]
+			if s == nil {
+				conn, err := net.Dial(rp.Net, rp.Addr)
+				if err != nil {
+					return nil, err
+				}
+				s = &replaySession{id: r.Session, conn: conn, waiting: make(map[uint16][]*request)}
+				sessions[r.Session] = s
+				go rp.receive(s, diverged)
+			}
+			q := reqs[r]
+			s.mu.Lock()
+			s.waiting[q.tag] = append(s.waiting[q.tag], q)
+			s.mu.Unlock()
+			if _, err := s.conn.Write(r.Pkt); err != nil {
+				return nil, fmt.Errorf("session %d: %v", s.id, err)
+			}
+			if !rp.Paced && q.want != nil {
+				select {
+				case <-q.done:
+				case <-time.After(timeout):
+				}
+			}
+		}
+	}
+	for _, s := range sessions {
+		finish(s)
+	}
+
+	sort.SliceStable(divs, func(i, j int) bool {
+		oi, oj := len(recs), len(recs)
+		if divs[i].Request != nil {
+			oi = order[divs[i].Request]
+		}
+		if divs[j].Request != nil {
+			oj = order[divs[j].Request]
+		}
+		return oi < oj
+	})
+	return divs, nil
+}
+
+// pair the T-messages of recs with their recorded replies, and find the
+// reads of directories
+func requests(recs []*Record) (map[*Record]*request, error) {
+	type fidKey struct {
+		session int
+		fid     uint32
+	}
+	reqs := make(map[*Record]*request)
+	dirs := make(map[fidKey]bool) // the fids of directories
+	for i, r := range recs {
+		if r.Event != Tmsg {
+			continue
+		}
+		tc, err := r.Fcall()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", r, err)
+		}
+		q := &request{rec: r, tag: tc.Tag, done: make(chan struct{})}
+		for _, w := range recs[i+1:] {
+			if w.Session == r.Session && w.Event == Rmsg && len(w.Pkt) >= minMessage &&
+				binary.LittleEndian.Uint16(w.Pkt[5:]) == tc.Tag {
+				q.want = w
+				break
+			}
+		}
+		reqs[r] = q
+
+		fid := fidKey{r.Session, tc.Fid}
+		q.dir = tc.Type == warp9.Tread && dirs[fid]
+		if q.want == nil {
+			continue
+		}
+		rc, err := q.want.Fcall()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", q.want, err)
+		}
+		switch {
+		case tc.Type == warp9.Tattach && rc.Type == warp9.Rattach,
+			tc.Type == warp9.Tcreate && rc.Type == warp9.Rcreate:
+			dirs[fid] = rc.Qid.Type&warp9.QTDIR != 0
+		case tc.Type == warp9.Twalk && rc.Type == warp9.Rwalk:
+			isdir := dirs[fid]
+			if len(tc.Wname) > 0 {
+				isdir = rc.Qid.Type&warp9.QTDIR != 0
+			}
+			dirs[fidKey{r.Session, tc.Newfid}] = isdir
+		case tc.Type == warp9.Tclunk, tc.Type == warp9.Tremove:
+			delete(dirs, fid)
+		}
+	}
+	return reqs, nil
+}
+
+// read the replies of the session, comparing each with the one recorded
+func (rp *Replayer) receive(s *replaySession, diverged func(*Divergence)) {
+	r := bufio.NewReader(s.conn)
+	for {
+		pkt, err := readMessage(r)
+		if err != nil {
+			return
+		}
+		rc, err, _ := warp9.Unpack(pkt)
+		if err != nil {
+			warp9.Error("replay: session %d: %v", s.id, err)
+			continue
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return
+		}
+		var q *request
+		if w := s.waiting[rc.Tag]; len(w) > 0 {
+			q = w[0]
+			if len(w) == 1 {
+				delete(s.waiting, rc.Tag)
+			} else {
+				s.waiting[rc.Tag] = w[1:]
+			}
+		}
+		s.mu.Unlock()
+
+		switch {
+		case q == nil:
+			diverged(&Divergence{Session: s.id, Got: rc})
+		case q.want == nil:
+			diverged(&Divergence{Session: s.id, Request: q.rec, Got: rc})
+		default:
+			want, err := q.want.Fcall()
+			if err != nil {
+				warp9.Error("replay: %s: %v", q.want, err)
+			} else if diffs := rp.compare(want, rc, q.dir); len(diffs) > 0 {
+				diverged(&Divergence{Session: s.id, Request: q.rec, Want: q.want, Got: rc, Diffs: diffs})
+			}
+		}
+		if q != nil {
+			close(q.done)
+		}
+	}
+}
+
+// the fields, not ignored, in which got differs from want, as
+// "name: want != got"
+func (rp *Replayer) compare(want, got *warp9.Fcall, dir bool) []string {
+	wf, gf := fields(want, dir), fields(got, dir)
+	var names []string
+	for n := range wf {
+		names = append(names, n)
+	}
+	for n := range gf {
+		if _, ok := wf[n]; !ok {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+
+	var diffs []string
+	for _, n := range names {
+		if wf[n] == gf[n] || rp.ignored(n) {
+			continue
+		}
+		w, g := wf[n], gf[n]
+		if w == "" {
+			w = "none"
+		}
+		if g == "" {
+			g = "none"
+		}
+		diffs = append(diffs, fmt.Sprintf("%s: %s != %s", n, w, g))
+	}
+	return diffs
+}
+
+func (rp *Replayer) ignored(name string) bool {
+	for _, glob := range rp.Ignore {
+		if ok, _ := path.Match(glob, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// the fields of a reply by name; the entries of a directory read are
+// given separately if dir is set
+func fields(fc *warp9.Fcall, dir bool) map[string]string {
+	f := map[string]string{"type": typeName(fc)}
+	qid := func(prefix string, q *warp9.Qid) {
+		f[prefix+"qid.type"] = fmt.Sprint(q.Type)
+		f[prefix+"qid.version"] = fmt.Sprint(q.Version)
+		f[prefix+"qid.path"] = fmt.Sprint(q.Path)
+	}
+	stat := func(prefix string, d *warp9.Dir) {
+		qid(prefix, &d.Qid)
+		f[prefix+"mode"] = fmt.Sprintf("%o", d.Mode)
+		f[prefix+"atime"] = fmt.Sprint(d.Atime)
+		f[prefix+"mtime"] = fmt.Sprint(d.Mtime)
+		f[prefix+"length"] = fmt.Sprint(d.Length)
+		f[prefix+"name"] = d.Name
+		f[prefix+"uid"] = fmt.Sprint(d.Uid)
+		f[prefix+"gid"] = fmt.Sprint(d.Gid)
+		f[prefix+"muid"] = fmt.Sprint(d.Muid)
+	}
+	switch fc.Type {
+	case warp9.Rversion:
+		f["msize"] = fmt.Sprint(fc.Msize)
+		f["version"] = fc.Version
+	case warp9.Rauth, warp9.Rattach, warp9.Rwalk:
+		qid("", &fc.Qid)
+	case warp9.Ropen, warp9.Rcreate:
+		qid("", &fc.Qid)
+		f["iounit"] = fmt.Sprint(fc.Iounit)
+	case warp9.Rread:
+		f["count"] = fmt.Sprint(fc.Count)
+		if !dir {
+			f["data"] = hex.EncodeToString(fc.Data)
+			break
+		}
+		buf := fc.Data
+		for len(buf) > 0 {
+			d, rest, _, err := warp9.UnpackDir(buf)
+			if err != nil {
+				f["data"] = hex.EncodeToString(buf)
+				break
+			}
+			stat("dir."+d.Name+".", d)
+			buf = rest
+		}
+	case warp9.Rwrite:
+		f["count"] = fmt.Sprint(fc.Count)
+	case warp9.Rstat:
+		stat("stat.", &fc.Dir)
+	case warp9.Rerror:
+		f["error"] = fmt.Sprint(fc.Error)
+	}
+	return f
+}
+
+// the name of the message type, as Fcall.String gives it
+func typeName(fc *warp9.Fcall) string {
+	if s := strings.Fields(fc.String()); len(s) > 0 && !strings.HasPrefix(s[0], "invalid") {
+		return s[0]
+	}
+	return fmt.Sprint(fc.Type)
+}
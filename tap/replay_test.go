@@ -0,0 +1,87 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package tap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lavaorg/warp/warp9"
+	"github.com/lavaorg/warp/wkit"
+)
+
+// serve a tree holding the named objects, returning its address
+func serveFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := wkit.NewDirItem("/")
+	for name, data := range files {
+		item := wkit.NewItem(name)
+		item.SetBuffer([]byte(data))
+		root.AddItem(item)
+	}
+	srv := wkit.NewServer("device", 0, root)
+	if !srv.Start(srv) {
+		t.Fatal("unable to start server")
+	}
+	l := listen(t)
+	go srv.StartListener(l)
+	return l.Addr().String()
+}
+
+func TestReplay(t *testing.T) {
+	addr := serveFiles(t, map[string]string{"temp": "21", "name": "dev1"})
+	var log syncBuffer
+	c9, err := warp9.Mount("tcp", startProxy(t, addr, &log), "", 8192, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c9.Get("/temp", 0)
+	c9.ReadDir("/")
+	c9.Stat("/name")
+	if _, err := c9.Stat("/missing"); err == nil {
+		t.Error("stat of a missing object")
+	}
+	c9.Unmount()
+	session := log.String()
+	closedLog(t, &log, 1)
+
+	replay := func(rp *Replayer) []string {
+		t.Helper()
+		divs, err := rp.Replay(NewReader(strings.NewReader(session)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var s []string
+		for _, d := range divs {
+			s = append(s, d.String())
+		}
+		return s
+	}
+
+	// the same server gives the same replies, in order or paced
+	for _, paced := range []bool{false, true} {
+		rp := &Replayer{Net: "tcp", Addr: addr, Paced: paced, Ignore: []string{"*time"}}
+		if divs := replay(rp); len(divs) != 0 {
+			t.Errorf("paced %v:\n%s", paced, strings.Join(divs, "\n"))
+		}
+	}
+
+	// another server differs in its contents, and in its qids
+	other := serveFiles(t, map[string]string{"temp": "22", "name": "dev1"})
+	divs := replay(&Replayer{Net: "tcp", Addr: other, Ignore: []string{"*time", "*qid.path"}})
+	if len(divs) != 1 || !strings.Contains(divs[0], "Tread") || !strings.HasSuffix(divs[0], "data: 3231 != 3232") {
+		t.Errorf("divergences:\n%s", strings.Join(divs, "\n"))
+	}
+	divs = replay(&Replayer{Net: "tcp", Addr: other, Ignore: []string{"*time", "data"}})
+	if len(divs) == 0 || !strings.Contains(strings.Join(divs, "\n"), "qid.path") {
+		t.Errorf("qid divergences:\n%s", strings.Join(divs, "\n"))
+	}
+
+	// and one without name differs in the directory and in its stat
+	divs = replay(&Replayer{Net: "tcp", Addr: serveFiles(t, map[string]string{"temp": "21"}), Ignore: []string{"*time", "*qid.path"}})
+	all := strings.Join(divs, "\n")
+	if !strings.Contains(all, "dir.name.length: 4 != none") || !strings.Contains(all, "type: Rwalk != Rerror") {
+		t.Errorf("divergences:\n%s", all)
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package tap
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lavaorg/warp/warp9"
+	"github.com/lavaorg/warp/wkit"
+)
+
+var user = warp9.Identity.User(1)
+
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+// a log written by the proxy's goroutines and read by the test
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.b.Write(p)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.b.String()
+}
+
+// the records of the log, once it holds n closed sessions
+func closedLog(t *testing.T, log *syncBuffer, n int) []*Record {
+	t.Helper()
+	for wait := 0; strings.Count(log.String(), " - ") < n; wait++ {
+		if wait == 100 {
+			t.Fatalf("sessions not closed:\n%s", log)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	var recs []*Record
+	rd := NewReader(strings.NewReader(log.String()))
+	for {
+		r, err := rd.ReadRecord()
+		if err == io.EOF {
+			return recs
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, r)
+	}
+}
+
+// start a proxy to addr, returning its address
+func startProxy(t *testing.T, addr string, log io.Writer) string {
+	l := listen(t)
+	go NewProxy("tcp", addr, NewWriter(log)).Serve(l)
+	return l.Addr().String()
+}
+
+func TestRecord(t *testing.T) {
+	r := &Record{
+		Time:    time.Date(2026, 10, 19, 9, 12, 1, 523114027, time.UTC),
+		Session: 3,
+		Event:   Tmsg,
+		Text:    "Twalk tag 1 fid 1 newfid 2 ['a\tb',]",
+		Pkt:     []byte{1, 2, 3},
+	}
+	line := r.String()
+	if line != "2026-10-19T09:12:01.523114027Z 3 > Twalk tag 1 fid 1 newfid 2 ['a b',]\t010203" {
+		t.Errorf("line: %q", line)
+	}
+	p, err := ParseRecord(line)
+	if err != nil || !p.Time.Equal(r.Time) || p.Session != 3 || p.Event != Tmsg ||
+		p.Text != "Twalk tag 1 fid 1 newfid 2 ['a b',]" || !bytes.Equal(p.Pkt, r.Pkt) {
+		t.Errorf("parsed: %+v, %v", p, err)
+	}
+	if p, err := ParseRecord("2026-10-19T09:12:01Z 3 -"); err != nil || p.Event != Close || p.Text != "" {
+		t.Errorf("close: %+v, %v", p, err)
+	}
+	for _, bad := range []string{
+		"",
+		"yesterday 1 + x",
+		"2026-10-19T09:12:01Z one + x",
+		"2026-10-19T09:12:01Z 1 ? x",
+		"2026-10-19T09:12:01Z 1 > Tclunk tag 1 fid 1",
+		"2026-10-19T09:12:01Z 1 > Tclunk tag 1 fid 1\tzz",
+	} {
+		if _, err := ParseRecord(bad); err == nil {
+			t.Errorf("%q: no error", bad)
+		}
+	}
+}
+
+func TestProxy(t *testing.T) {
+	root := wkit.NewDirItem("/")
+	item := wkit.NewItem("temp")
+	item.SetBuffer([]byte("21"))
+	root.AddItem(item)
+	srv := wkit.NewServer("device", 0, root)
+	if !srv.Start(srv) {
+		t.Fatal("unable to start server")
+	}
+	l := listen(t)
+	go srv.StartListener(l)
+
+	var log syncBuffer
+	addr := startProxy(t, l.Addr().String(), &log)
+	c9, err := warp9.Mount("tcp", addr, "", 8192, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _, err := c9.Get("/temp", 0)
+	if err != nil || string(data) != "21" {
+		t.Errorf("through the proxy: %q, %v", data, err)
+	}
+	c9.Unmount()
+
+	recs := closedLog(t, &log, 1)
+	if len(recs) < 4 || recs[0].Event != Open || recs[len(recs)-1].Event != Close {
+		t.Fatalf("log:\n%s", log.String())
+	}
+	var types []string
+	for _, r := range recs[1 : len(recs)-1] {
+		fc, err := r.Fcall()
+		if err != nil {
+			t.Fatalf("%s: %v", r, err)
+		}
+		if fc.String() != r.Text || (fc.Type%2 == 0) != (r.Event == Tmsg) {
+			t.Errorf("record %s of %s", r, fc)
+		}
+		types = append(types, strings.Fields(r.Text)[0])
+	}
+	want := "Tversion Rversion Tattach Rattach Twalk Rwalk Topen Ropen Tread Rread"
+	if s := strings.Join(types, " "); !strings.HasPrefix(s, want) {
+		t.Errorf("messages: %s", s)
+	}
+}
+
+func TestProxyOpaque(t *testing.T) {
+	// an echo server, speaking something other than warp9
+	l := listen(t)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		io.Copy(c, c)
+		c.Close()
+	}()
+	var log syncBuffer
+	c, err := net.Dial("tcp", startProxy(t, l.Addr().String(), &log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := "GET / HTTP/1.0\r\n\r\n"
+	if _, err := io.WriteString(c, msg); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, len(msg))
+	if _, err := io.ReadFull(c, buf); err != nil || string(buf) != msg {
+		t.Errorf("echoed: %q, %v", buf, err)
+	}
+	c.Close()
+
+	recs := closedLog(t, &log, 1)
+	if len(recs) != 4 || recs[1].Event != Note || recs[2].Event != Note || !strings.Contains(recs[1].Text, "not warp9") {
+		t.Errorf("log:\n%s", log.String())
+	}
+}
@@ -0,0 +1,291 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package warp9
+
+import (
+	"strings"
+	"sync"
+)
+
+// A Future is the pending result of a request issued through a Pipe.
+type Future struct {
+	done chan struct{}
+	rc   *Fcall
+	err  error
+}
+
+// Done returns a channel that is closed once the result is available.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until the response arrives and returns it, or the error
+// reported by the server or the connection.
+func (f *Future) Wait() (*Fcall, error) {
+	<-f.done
+	return f.rc, f.err
+}
+
+// Err blocks until the response arrives and returns only its error.
+func (f *Future) Err() error {
+	<-f.done
+	return f.err
+}
+
+func (f *Future) complete(rc *Fcall, err error) {
+	f.rc = rc
+	f.err = err
+	close(f.done)
+}
+
+// A Pipe is an asynchronous front end to a Tag. Every request sent on a
+// Pipe shares the Pipe's tag; the server processes requests sharing a tag
+// in the order they were sent, so dependent operations (walk, open, read,
+// clunk) can be sent back to back without waiting for each reply. Each
+// operation returns a Future. Use several Pipes to keep many tags in flight.
+//
+// Close must be called once the Pipe is no longer needed; the tag is
+// released after every outstanding request has completed.
+type Pipe struct {
+	sync.Mutex
+	clnt    *Clnt
+	tag     *Tag
+	reqchan chan *Req
+	pending []*Future // responses on one tag arrive in the order sent
+	closed  bool
+	freed   bool
+}
+
+// NewPipe allocates a tag and returns a Pipe issuing requests on it.
+func (clnt *Clnt) NewPipe() *Pipe {
+	p := &Pipe{
+		clnt:    clnt,
+		reqchan: make(chan *Req, 16),
+	}
+	p.tag = clnt.TagAlloc(p.reqchan)
+	go p.dispatch()
+	return p
+}
+
+// Close releases the Pipe. Outstanding requests still complete.
+func (p *Pipe) Close() {
+	p.Lock()
+	p.closed = true
+	free := len(p.pending) == 0
+	p.Unlock()
+	if free {
+		p.free()
+	}
+}
+
+func (p *Pipe) free() {
+	p.Lock()
+	if p.freed {
+		p.Unlock()
+		return
+	}
+	p.freed = true
+	p.Unlock()
+	p.clnt.TagFree(p.tag)
+	close(p.reqchan)
+}
+
+// match completed requests to their futures
+func (p *Pipe) dispatch() {
+	for r := range p.reqchan {
+		var f *Future
+		p.Lock()
+		if len(p.pending) > 0 {
+			f = p.pending[0]
+			p.pending = p.pending[1:]
+		}
+		last := p.closed && len(p.pending) == 0
+		p.Unlock()
+
+		if f != nil {
+			f.complete(reqResult(r))
+		}
+		p.tag.ReqFree(r)
+		if last {
+			go p.free()
+		}
+	}
+}
+
+// reqResult converts a completed Req into a response and an error.
+func reqResult(r *Req) (*Fcall, error) {
+	rc := r.Rc
+	switch {
+	case rc != nil && rc.Type == Rerror:
+		if rc.Error != nil {
+			return rc, rc.Error
+		}
+		return rc, &WarpError{Einval, ""}
+	case r.Err != nil:
+		return rc, r.Err
+	case rc == nil:
+		return nil, &WarpError{Econn, ""}
+	}
+	return rc, nil
+}
+
+// issue a request on the pipe's tag; send performs the Tag operation.
+// The lock is held while sending so the pending queue matches the order
+// the requests were posted.
+func (p *Pipe) issue(send func() error) *Future {
+	f := &Future{done: make(chan struct{})}
+
+	p.Lock()
+	if p.closed {
+		p.Unlock()
+		f.complete(nil, &WarpError{Ebaduse, "pipe closed"})
+		return f
+	}
+	p.pending = append(p.pending, f)
+	err := send()
+	if err != nil {
+		p.pending = p.pending[:len(p.pending)-1]
+	}
+	p.Unlock()
+
+	if err != nil {
+		f.complete(nil, err)
+	}
+	return f
+}
+
+// Walk walks newfid from fid through wnames.
+func (p *Pipe) Walk(fid, newfid *Fid, wnames []string) *Future {
+	return p.issue(func() error { return p.tag.Walk(fid, newfid, wnames) })
+}
+
+// Open opens the object associated with fid.
+func (p *Pipe) Open(fid *Fid, mode uint8) *Future {
+	return p.issue(func() error { return p.tag.Open(fid, mode) })
+}
+
+// Create creates name in the directory associated with fid.
+func (p *Pipe) Create(fid *Fid, name string, perm uint32, mode uint8, extattr string) *Future {
+	return p.issue(func() error { return p.tag.Create(fid, name, perm, mode, extattr) })
+}
+
+// Read reads count bytes from offset; the data is in the response's Data.
+func (p *Pipe) Read(fid *Fid, offset uint64, count uint32) *Future {
+	return p.issue(func() error { return p.tag.Read(fid, offset, count) })
+}
+
+// Write writes data at offset; the count written is in the response's Count.
+func (p *Pipe) Write(fid *Fid, data []byte, offset uint64) *Future {
+	return p.issue(func() error { return p.tag.Write(fid, data, offset) })
+}
+
+// Clunk releases fid.
+func (p *Pipe) Clunk(fid *Fid) *Future {
+	return p.issue(func() error { return p.tag.Clunk(fid) })
+}
+
+// Remove removes the object associated with fid and releases the fid.
+func (p *Pipe) Remove(fid *Fid) *Future {
+	return p.issue(func() error { return p.tag.Remove(fid) })
+}
+
+// Stat returns the object's Dir in the response's Dir.
+func (p *Pipe) Stat(fid *Fid) *Future {
+	return p.issue(func() error { return p.tag.Stat(fid) })
+}
+
+// Wstat changes the metadata of the object associated with fid.
+func (p *Pipe) Wstat(fid *Fid, dir *Dir) *Future {
+	return p.issue(func() error { return p.tag.Wstat(fid, dir) })
+}
+
+//
+// batch helpers
+//
+
+// A GetResult is the outcome of fetching one path in a GetBatch.
+type GetResult struct {
+	Path string
+	Qid  Qid
+	Data []byte
+	Err  error
+}
+
+// the futures for one path of a batch
+type batchGet struct {
+	path                    string
+	pipe                    *Pipe
+	walk, open, read, clunk *Future
+}
+
+// GetBatch fetches each of paths the way Get(path, 0) does (walk, open,
+// one read and clunk) but pipelines the requests: each path uses its own
+// Pipe so its four requests are sent back to back, and up to inflight
+// paths are outstanding at once. The results are delivered on the
+// returned channel in the order of paths; the channel is closed after
+// the last result. It holds the whole batch, so a caller may stop reading
+// early without leaving the batch blocked.
+func (clnt *Clnt) GetBatch(paths []string, inflight int) <-chan *GetResult {
+	if inflight < 1 {
+		inflight = 1
+	}
+	out := make(chan *GetResult, len(paths))
+	started := make(chan *batchGet, inflight)
+
+	go func() {
+		for _, path := range paths {
+			started <- clnt.startGet(path)
+		}
+		close(started)
+	}()
+
+	go func() {
+		for b := range started {
+			out <- b.result()
+		}
+		close(out)
+	}()
+
+	return out
+}
+
+func (clnt *Clnt) startGet(path string) *batchGet {
+	b := &batchGet{path: path, pipe: clnt.NewPipe()}
+	fid := clnt.FidAlloc()
+	b.walk = b.pipe.Walk(clnt.Root, fid, splitPath(path))
+	b.open = b.pipe.Open(fid, OREAD)
+	b.read = b.pipe.Read(fid, 0, clnt.Msize-IOHDRSZ)
+	b.clunk = b.pipe.Clunk(fid)
+	return b
+}
+
+func (b *batchGet) result() *GetResult {
+	defer b.pipe.Close()
+	res := &GetResult{Path: b.path}
+
+	rc, err := b.walk.Wait()
+	if err == nil {
+		res.Qid = rc.Qid
+		_, err = b.open.Wait()
+	}
+	if err == nil {
+		rc, err = b.read.Wait()
+		if err == nil {
+			res.Data = append([]byte(nil), rc.Data...)
+		}
+	}
+	// always wait for the clunk so the fid is released
+	b.clunk.Wait()
+	res.Err = err
+	return res
+}
+
+// split a path into the names to walk
+func splitPath(path string) []string {
+	path = cleanPath(path)
+	if path == "/" {
+		return nil
+	}
+	return strings.Split(path[1:], "/")
+}
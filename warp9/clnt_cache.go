@@ -0,0 +1,382 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package warp9
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Cache is an optional client side cache of walk results, Dir entries,
+// directory listings and small object contents. A Cache is attached to a
+// Clnt with SetCache; once attached the Stat, ReadDir and Get methods consult
+// it before going to the server.
+//
+// Entries are keyed by the cleaned object path. An entry is trusted without
+// contacting the server for TTL after it was last validated. Once expired the
+// entry is revalidated by walking to the object: if the Qid returned by the
+// walk has the same Path and Version as the cached Qid the entry is reused,
+// otherwise it is invalidated and refetched. Objects with a Qid.Version of 0
+// are treated as unversioned and are always refetched once expired.
+//
+// Mutations performed through the same Clnt (Write, FCreate, FRemove, FWstat)
+// invalidate the affected entries.
+type Cache struct {
+	sync.Mutex
+	TTL     time.Duration // how long an entry is trusted without asking the server
+	MaxData int           // largest object contents (in bytes) kept by Get
+
+	entries map[string]*cacheEntry
+	stats   CacheStats
+}
+
+// CacheStats reports the activity of a Cache.
+type CacheStats struct {
+	Hits          uint64 // lookups answered from the cache
+	Misses        uint64 // lookups that required fetching from the server
+	Invalidations uint64 // entries dropped because the object changed
+	Entries       int    // number of paths currently cached
+}
+
+// the cached state of one path
+type cacheEntry struct {
+	qid     Qid
+	checked time.Time // last time the entry was known to be valid
+	dir     *Dir      // stat result
+	dirents []*Dir    // directory listing
+	data    []byte    // object contents (offset 0, single read)
+	hasData bool
+}
+
+// NewCache creates a Cache whose entries are trusted for ttl and which
+// keeps object contents up to maxdata bytes.
+func NewCache(ttl time.Duration, maxdata int) *Cache {
+	return &Cache{
+		TTL:     ttl,
+		MaxData: maxdata,
+		entries: make(map[string]*cacheEntry),
+	}
+}
+
+// SetCache attaches (or with nil, detaches) a cache to the client.
+func (clnt *Clnt) SetCache(c *Cache) {
+	clnt.Lock()
+	clnt.cache = c
+	clnt.Unlock()
+}
+
+// Cache returns the cache attached to the client, or nil.
+func (clnt *Clnt) Cache() *Cache {
+	clnt.Lock()
+	defer clnt.Unlock()
+	return clnt.cache
+}
+
+// Stats returns a snapshot of the cache statistics.
+func (c *Cache) Stats() CacheStats {
+	c.Lock()
+	defer c.Unlock()
+	s := c.stats
+	s.Entries = len(c.entries)
+	return s
+}
+
+// Purge drops every entry from the cache. Statistics are retained.
+func (c *Cache) Purge() {
+	c.Lock()
+	c.entries = make(map[string]*cacheEntry)
+	c.Unlock()
+}
+
+// Invalidate drops the entry for path.
+func (c *Cache) Invalidate(path string) {
+	c.Lock()
+	c.invalidate(cleanPath(path))
+	c.Unlock()
+}
+
+//
+// private cache helpers -- all expect the caller to hold the lock
+// unless noted otherwise
+//
+
+// return the entry for path if it is still within the TTL
+func (c *Cache) fresh(path string) *cacheEntry {
+	e := c.entries[path]
+	if e == nil || time.Since(e.checked) > c.TTL {
+		return nil
+	}
+	return e
+}
+
+// get or create the entry for path
+func (c *Cache) entry(path string, qid Qid) *cacheEntry {
+	e := c.entries[path]
+	if e == nil {
+		e = &cacheEntry{qid: qid}
+		c.entries[path] = e
+	}
+	e.qid = qid
+	e.checked = time.Now()
+	return e
+}
+
+// drop the entry for path, counting it if it held anything
+func (c *Cache) invalidate(path string) {
+	e := c.entries[path]
+	if e == nil {
+		return
+	}
+	if e.dir != nil || e.dirents != nil || e.hasData {
+		c.stats.Invalidations++
+	}
+	delete(c.entries, path)
+}
+
+// drop the entry for path and the listing of its parent directory
+func (c *Cache) invalidateWithParent(path string) {
+	c.invalidate(path)
+	c.invalidate(parentPath(path))
+}
+
+// walked records the Qid the server returned when walking to path.
+// A Qid that differs from the cached one invalidates the entry; a matching,
+// versioned Qid revalidates it.
+func (c *Cache) walked(path string, qid Qid) {
+	c.Lock()
+	defer c.Unlock()
+	e := c.entries[path]
+	switch {
+	case e == nil:
+		c.entry(path, qid)
+	case e.qid != qid:
+		c.invalidate(path)
+		c.entry(path, qid)
+	case qid.Version != 0:
+		e.checked = time.Now()
+	case time.Since(e.checked) > c.TTL:
+		// unversioned and expired; nothing to validate against
+		delete(c.entries, path)
+		c.entry(path, qid)
+	}
+}
+
+// mutated is called after the client changes the object at path.
+func (c *Cache) mutated(path string, withParent bool) {
+	if path == "" {
+		return
+	}
+	c.Lock()
+	if withParent {
+		c.invalidateWithParent(path)
+	} else {
+		c.invalidate(path)
+	}
+	c.Unlock()
+}
+
+func (c *Cache) getDir(path string) *Dir {
+	c.Lock()
+	defer c.Unlock()
+	if e := c.fresh(path); e != nil && e.dir != nil {
+		c.stats.Hits++
+		d := *e.dir
+		return &d
+	}
+	return nil
+}
+
+func (c *Cache) putDir(path string, d *Dir) {
+	c.Lock()
+	defer c.Unlock()
+	c.stats.Misses++
+	nd := *d
+	c.entry(path, d.Qid).dir = &nd
+}
+
+func (c *Cache) getDirents(path string) []*Dir {
+	c.Lock()
+	defer c.Unlock()
+	if e := c.fresh(path); e != nil && e.dirents != nil {
+		c.stats.Hits++
+		return copyDirs(e.dirents)
+	}
+	return nil
+}
+
+// putDirents stores a listing and primes the Dir entries of the children.
+func (c *Cache) putDirents(path string, qid Qid, dirs []*Dir) {
+	c.Lock()
+	defer c.Unlock()
+	c.stats.Misses++
+	c.entry(path, qid).dirents = copyDirs(dirs)
+	for _, d := range dirs {
+		child := joinPath(path, d.Name)
+		if old := c.entries[child]; old != nil && old.qid != d.Qid {
+			c.invalidate(child)
+		}
+		nd := *d
+		c.entry(child, d.Qid).dir = &nd
+	}
+}
+
+func (c *Cache) getData(path string) ([]byte, *Qid) {
+	c.Lock()
+	defer c.Unlock()
+	if e := c.fresh(path); e != nil && e.hasData {
+		c.stats.Hits++
+		qid := e.qid
+		return append([]byte(nil), e.data...), &qid
+	}
+	return nil, nil
+}
+
+func (c *Cache) putData(path string, qid Qid, data []byte) {
+	c.Lock()
+	defer c.Unlock()
+	c.stats.Misses++
+	if len(data) > c.MaxData {
+		return
+	}
+	e := c.entry(path, qid)
+	e.data = append([]byte(nil), data...)
+	e.hasData = true
+}
+
+//
+// cached client operations
+//
+
+// stat through the cache
+func (clnt *Clnt) cachedStat(c *Cache, path string) (*Dir, error) {
+	path = cleanPath(path)
+	if d := c.getDir(path); d != nil {
+		return d, nil
+	}
+
+	// the walk revalidates a versioned entry that has expired
+	fid, err := clnt.Walk(path)
+	if err != nil {
+		return nil, err
+	}
+	defer clnt.Clunk(fid)
+
+	if d := c.getDir(path); d != nil {
+		return d, nil
+	}
+
+	d, err := clnt.FStat(fid)
+	if err != nil {
+		return nil, err
+	}
+	c.putDir(path, d)
+	return d, nil
+}
+
+// ReadDir returns all the entries of the named directory. If a Cache is
+// attached the listing is served from, and stored in, the cache.
+func (clnt *Clnt) ReadDir(path string) ([]*Dir, error) {
+	c := clnt.Cache()
+	path = cleanPath(path)
+	if c != nil {
+		if dirs := c.getDirents(path); dirs != nil {
+			return dirs, nil
+		}
+	}
+
+	fid, err := clnt.Walk(path)
+	if err != nil {
+		return nil, err
+	}
+	if c != nil {
+		if dirs := c.getDirents(path); dirs != nil {
+			clnt.Clunk(fid)
+			return dirs, nil
+		}
+	}
+
+	obj, err := clnt.FOpenObject(fid, OREAD)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+
+	dirs, err := obj.Readdir(0)
+	if err != nil {
+		return nil, err
+	}
+	if c != nil {
+		c.putDirents(path, fid.Qid, dirs)
+	}
+	return dirs, nil
+}
+
+// get through the cache; only reads from offset 0 are cached
+func (clnt *Clnt) cachedGet(c *Cache, path string) ([]byte, *Qid, error) {
+	path = cleanPath(path)
+	if data, qid := c.getData(path); qid != nil {
+		return data, qid, nil
+	}
+
+	// the walk revalidates a versioned entry that has expired
+	fid, err := clnt.Walk(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if data, qid := c.getData(path); qid != nil {
+		clnt.Clunk(fid)
+		return data, qid, nil
+	}
+
+	obj, err := clnt.FOpenObject(fid, OREAD)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer obj.Close()
+
+	qid := fid.Qid
+	data, err := clnt.Read(fid, 0, fid.Iounit)
+	if err != nil {
+		return nil, &qid, err
+	}
+	c.putData(path, qid, data)
+	return data, &qid, nil
+}
+
+//
+// path helpers
+//
+
+// cleanPath returns the canonical form of an object path used as cache key.
+func cleanPath(path string) string {
+	names := make([]string, 0, 8)
+	for _, n := range strings.Split(path, "/") {
+		if n != "" && n != "." {
+			names = append(names, n)
+		}
+	}
+	return "/" + strings.Join(names, "/")
+}
+
+func joinPath(dir string, names ...string) string {
+	return cleanPath(dir + "/" + strings.Join(names, "/"))
+}
+
+func parentPath(path string) string {
+	n := strings.LastIndex(path, "/")
+	if n <= 0 {
+		return "/"
+	}
+	return path[:n]
+}
+
+func copyDirs(dirs []*Dir) []*Dir {
+	cp := make([]*Dir, len(dirs))
+	for i, d := range dirs {
+		nd := *d
+		cp[i] = &nd
+	}
+	return cp
+}
@@ -0,0 +1,113 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package warp9
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCachePaths(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"", "/"},
+		{"/", "/"},
+		{"a/b", "/a/b"},
+		{"//a/./b/", "/a/b"},
+	}
+	for _, tt := range tests {
+		if got := cleanPath(tt.in); got != tt.want {
+			t.Errorf("cleanPath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	if got := parentPath("/a/b"); got != "/a" {
+		t.Errorf("parentPath(/a/b) = %q", got)
+	}
+	if got := parentPath("/a"); got != "/" {
+		t.Errorf("parentPath(/a) = %q", got)
+	}
+}
+
+func TestCacheVersionValidation(t *testing.T) {
+	c := NewCache(time.Hour, 1024)
+	qid := Qid{QTOBJ, 1, 7}
+
+	c.walked("/a", qid)
+	c.putDir("/a", &Dir{Qid: qid, Name: "a"})
+	if d := c.getDir("/a"); d == nil || d.Name != "a" {
+		t.Fatalf("expected hit, got %v", d)
+	}
+
+	// same version revalidates
+	c.walked("/a", qid)
+	if c.getDir("/a") == nil {
+		t.Fatal("entry dropped on matching version")
+	}
+
+	// new version invalidates
+	c.walked("/a", Qid{QTOBJ, 2, 7})
+	if c.getDir("/a") != nil {
+		t.Fatal("entry kept after version change")
+	}
+
+	s := c.Stats()
+	if s.Hits != 2 || s.Misses != 1 || s.Invalidations != 1 {
+		t.Errorf("bad stats: %+v", s)
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	c := NewCache(time.Millisecond, 1024)
+	versioned := Qid{QTOBJ, 3, 1}
+	unversioned := Qid{QTOBJ, 0, 2}
+
+	c.putData("/v", versioned, []byte("v"))
+	c.putData("/u", unversioned, []byte("u"))
+	time.Sleep(5 * time.Millisecond)
+
+	if _, qid := c.getData("/v"); qid != nil {
+		t.Fatal("expired entry returned")
+	}
+
+	// a walk with the same version brings a versioned entry back
+	c.walked("/v", versioned)
+	if data, _ := c.getData("/v"); string(data) != "v" {
+		t.Fatalf("versioned entry not revalidated: %q", data)
+	}
+
+	// an unversioned entry must be refetched
+	c.walked("/u", unversioned)
+	if _, qid := c.getData("/u"); qid != nil {
+		t.Fatal("unversioned entry revalidated")
+	}
+}
+
+func TestCacheListingAndMutation(t *testing.T) {
+	c := NewCache(time.Hour, 4)
+	dirs := []*Dir{
+		{Qid: Qid{QTOBJ, 1, 10}, Name: "x"},
+		{Qid: Qid{QTOBJ, 1, 11}, Name: "y"},
+	}
+	c.putDirents("/d", Qid{QTDIR, 1, 9}, dirs)
+
+	if got := c.getDirents("/d"); len(got) != 2 {
+		t.Fatalf("listing: got %d entries", len(got))
+	}
+	if d := c.getDir("/d/y"); d == nil || d.Qid.Path != 11 {
+		t.Fatalf("child not primed: %v", d)
+	}
+
+	c.mutated("/d/x", true)
+	if c.getDirents("/d") != nil {
+		t.Error("parent listing survived mutation of child")
+	}
+	if c.getDir("/d/x") != nil {
+		t.Error("child survived mutation")
+	}
+
+	// contents larger than MaxData are not kept
+	c.putData("/big", Qid{QTOBJ, 1, 12}, []byte("too big"))
+	if _, qid := c.getData("/big"); qid != nil {
+		t.Error("oversized contents cached")
+	}
+}
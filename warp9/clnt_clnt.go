@@ -27,9 +27,11 @@ type Clnt struct {
 	fidpool  *pool
 	reqout   chan *Req
 	done     chan bool
+	closed   chan struct{} // closed once the connection is gone
 	reqfirst *Req
 	reqlast  *Req
 	err      error
+	cache    *Cache // optional client side cache (see SetCache)
 
 	reqchan chan *Req   //pool of avail req structs
 	tchan   chan *Fcall //pool of avail fcall structs
@@ -48,6 +50,7 @@ type Fid struct {
 	Fid    uint32 // Fid number
 	User          // The user the fid belongs to
 	walked bool   // true if the fid points to a walked object on the server
+	path   string // cleaned path of the object, if known (used by the cache)
 }
 
 // The object is similar to the Fid, but is used in the high-level client
@@ -224,6 +227,8 @@ func (clnt *Clnt) recv() {
 					//log.Println(fmt.Sprintf("TTT %v", r.Tc))
 					//log.Println(fmt.Sprintf("RRR %v", r.Rc))
 				} else {
+					// pass the server's error through to the caller
+					r.Err = r.Rc.Error
 					if r.Err == nil {
 						r.Err = &WarpError{Einval, ""}
 					}
@@ -279,6 +284,7 @@ closed:
 	if sop, ok := (interface{}(clnt)).(StatsOps); ok {
 		sop.statsUnregister()
 	}
+	close(clnt.closed)
 }
 
 func (clnt *Clnt) send() {
@@ -325,6 +331,7 @@ func NewClnt(c net.Conn, msize uint32) *Clnt {
 	clnt.fidpool = newPool(NOFID)
 	clnt.reqout = make(chan *Req)
 	clnt.done = make(chan bool)
+	clnt.closed = make(chan struct{})
 	clnt.reqchan = make(chan *Req, 16)
 	clnt.tchan = make(chan *Fcall, 16)
 	go clnt.recv()
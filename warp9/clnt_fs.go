@@ -0,0 +1,280 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package warp9
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"sort"
+	"time"
+)
+
+// FS presents the object tree of a mounted Clnt as an io/fs file system.
+// It implements fs.FS, fs.ReadDirFS, fs.StatFS and fs.ReadFileFS so the
+// standard library helpers (fs.WalkDir, fs.Glob, template.ParseFS, http.FS)
+// can read warp trees directly. Names are the slash separated, unrooted
+// names required by io/fs; "." is the root of the mount.
+//
+// Errors are returned as *fs.PathError wrapping the WarpError; the
+// WarpError codes map onto fs.ErrNotExist, fs.ErrPermission and fs.ErrExist
+// (see WarpError.Is).
+type FS struct {
+	clnt *Clnt
+}
+
+// NewFS returns an FS reading the tree mounted by clnt.
+func NewFS(clnt *Clnt) *FS {
+	return &FS{clnt}
+}
+
+// convert an io/fs name to a warp path
+func (fsys *FS) path(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return "/", nil
+	}
+	return "/" + name, nil
+}
+
+// Open opens the named object for reading.
+func (fsys *FS) Open(name string) (fs.File, error) {
+	path, err := fsys.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	fid, err := fsys.clnt.Walk(path)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	d, err := fsys.clnt.FStat(fid)
+	if err != nil {
+		fsys.clnt.Clunk(fid)
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	obj, err := fsys.clnt.FOpenObject(fid, OREAD)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	info := &dirInfo{*d}
+	if name == "." {
+		info.Dir.Name = "."
+	}
+	return &fsFile{name: name, obj: obj, info: info}, nil
+}
+
+// Stat returns the metadata of the named object.
+func (fsys *FS) Stat(name string) (fs.FileInfo, error) {
+	path, err := fsys.path("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	d, err := fsys.clnt.Stat(path)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	info := &dirInfo{*d}
+	if name == "." {
+		info.Dir.Name = "."
+	}
+	return info, nil
+}
+
+// ReadDir returns the entries of the named directory sorted by name.
+func (fsys *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	path, err := fsys.path("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	dirs, err := fsys.clnt.ReadDir(path)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	return dirEntries(dirs), nil
+}
+
+// ReadFile returns the full contents of the named object.
+func (fsys *FS) ReadFile(name string) ([]byte, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	return data, nil
+}
+
+func dirEntries(dirs []*Dir) []fs.DirEntry {
+	ents := make([]fs.DirEntry, len(dirs))
+	for i, d := range dirs {
+		ents[i] = &dirInfo{*d}
+	}
+	sort.Slice(ents, func(i, j int) bool { return ents[i].Name() < ents[j].Name() })
+	return ents
+}
+
+// fsFile is an open object returned by FS.Open. It implements fs.File,
+// fs.ReadDirFile, io.Seeker and io.ReaderAt.
+type fsFile struct {
+	name    string
+	obj     *Object
+	info    *dirInfo
+	offset  int64
+	dirents []fs.DirEntry // remaining entries of a directory, once read
+	dirread bool
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *fsFile) Read(b []byte) (int, error) {
+	if f.info.IsDir() {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: errors.New("is a directory")}
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	n, err := f.obj.ReadAt(b, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *fsFile) ReadAt(b []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, &fs.PathError{Op: "readat", Path: f.name, Err: fs.ErrInvalid}
+	}
+	total := 0
+	for total < len(b) {
+		n, err := f.obj.ReadAt(b[total:], off+int64(total))
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func (f *fsFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.Size()
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *fsFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !f.info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: errors.New("not a directory")}
+	}
+	if !f.dirread {
+		dirs, err := f.obj.Readdir(0)
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: err}
+		}
+		f.dirents = dirEntries(dirs)
+		f.dirread = true
+	}
+
+	if n <= 0 {
+		ents := f.dirents
+		f.dirents = nil
+		return ents, nil
+	}
+	if len(f.dirents) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(f.dirents) {
+		n = len(f.dirents)
+	}
+	ents := f.dirents[:n:n]
+	f.dirents = f.dirents[n:]
+	return ents, nil
+}
+
+func (f *fsFile) Close() error {
+	if f.obj == nil {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	err := f.obj.Close()
+	f.obj = nil
+	return err
+}
+
+// dirInfo presents a Dir as both an fs.FileInfo and an fs.DirEntry.
+type dirInfo struct {
+	Dir
+}
+
+// DirFileInfo returns an fs.FileInfo describing d. Sys returns the *Dir.
+func DirFileInfo(d *Dir) fs.FileInfo {
+	return &dirInfo{*d}
+}
+
+func (di *dirInfo) Name() string               { return di.Dir.Name }
+func (di *dirInfo) Size() int64                { return int64(di.Length) }
+func (di *dirInfo) ModTime() time.Time         { return time.Unix(int64(di.Mtime), 0) }
+func (di *dirInfo) IsDir() bool                { return di.Mode().IsDir() }
+func (di *dirInfo) Sys() interface{}           { return &di.Dir }
+func (di *dirInfo) Type() fs.FileMode          { return di.Mode().Type() }
+func (di *dirInfo) Info() (fs.FileInfo, error) { return di, nil }
+
+// some servers only flag directories in the Qid type
+func (di *dirInfo) Mode() fs.FileMode {
+	m := PermToFileMode(di.Dir.Mode)
+	if di.Qid.Type&QTDIR != 0 {
+		m |= fs.ModeDir
+	}
+	return m
+}
+
+// PermToFileMode converts a Warp9 mode word to an fs.FileMode.
+// The low nine permission bits share the Unix layout.
+func PermToFileMode(perm uint32) fs.FileMode {
+	m := fs.FileMode(perm & 0777)
+	if perm&DMDIR != 0 {
+		m |= fs.ModeDir
+	}
+	if perm&DMAPPEND != 0 {
+		m |= fs.ModeAppend
+	}
+	if perm&DMEXCL != 0 {
+		m |= fs.ModeExclusive
+	}
+	if perm&DMTMP != 0 {
+		m |= fs.ModeTemporary
+	}
+	return m
+}
+
+// FileModeToPerm converts an fs.FileMode to a Warp9 mode word.
+func FileModeToPerm(m fs.FileMode) uint32 {
+	perm := uint32(m.Perm())
+	if m&fs.ModeDir != 0 {
+		perm |= DMDIR
+	}
+	if m&fs.ModeAppend != 0 {
+		perm |= DMAPPEND
+	}
+	if m&fs.ModeExclusive != 0 {
+		perm |= DMEXCL
+	}
+	if m&fs.ModeTemporary != 0 {
+		perm |= DMTMP
+	}
+	return perm
+}
@@ -9,8 +9,15 @@ package warp9
 // (this is the size of the iounit as would be returned by Open)
 // The associated Qid is also returned.
 // A suitable error code is returned if any error.
+// If a Cache is attached, reads from offset 0 may be served from the cache.
 func (clnt *Clnt) Get(path string, offset uint64) ([]byte, *Qid, error) {
+	if c := clnt.Cache(); c != nil && offset == 0 {
+		return clnt.cachedGet(c, path)
+	}
+	return clnt.get(path, offset)
+}
 
+func (clnt *Clnt) get(path string, offset uint64) ([]byte, *Qid, error) {
 	obj, err := clnt.Open(path, OREAD)
 	if err != nil {
 		return nil, nil, err
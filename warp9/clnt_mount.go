@@ -55,6 +55,7 @@ func (clnt *Clnt) Attach(afid *Fid, user User, aname string) (*Fid, error) {
 	fid.Qid = rc.Qid
 	fid.User = user
 	fid.walked = true
+	fid.path = "/"
 	return fid, nil
 }
 
@@ -91,3 +92,17 @@ func (clnt *Clnt) Unmount() {
 	clnt.conn.Close()
 	clnt.Unlock()
 }
+
+// Closed returns a channel that is closed once the connection to the
+// server is gone, whether through Unmount or a network failure.
+func (clnt *Clnt) Closed() <-chan struct{} {
+	return clnt.closed
+}
+
+// Err returns the error that closed the connection, or nil while the
+// connection is up.
+func (clnt *Clnt) Err() error {
+	clnt.Lock()
+	defer clnt.Unlock()
+	return clnt.err
+}
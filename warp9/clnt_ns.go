@@ -0,0 +1,385 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package warp9
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A Namespace composes the trees of several mounted Clnts into one logical
+// tree, in the manner of a Plan 9 process namespace. Trees are attached at
+// paths with Mount, parts of the namespace are made to appear elsewhere with
+// Bind, and a path may hold a union of several trees (MBEFORE, MAFTER).
+// The application uses the namespace's paths; which servers provide them is
+// decided when the namespace is built.
+//
+// A path is resolved through the longest mounted or bound prefix. In a
+// union each member is tried in order and the first one holding the name
+// is used. Directories above mount points that no mounted tree provides
+// are presented as empty, read only directories so the mount points can be
+// reached by reading the tree from the root.
+type Namespace struct {
+	User  User   // user attaching the trees mounted by address
+	Msize uint32 // message size of connections made by MountAddr; 0 for the default
+
+	mu     sync.Mutex
+	mounts map[string][]nsMember // mount table by clean path
+	owned  []*Clnt               // connections made by MountAddr
+}
+
+// one member of a mount point: the path within the tree of clnt
+type nsMember struct {
+	clnt *Clnt
+	path string
+	flag uint32
+}
+
+// NewNamespace returns an empty namespace. The user and msize are used by
+// MountAddr.
+func NewNamespace(user User, msize uint32) *Namespace {
+	return &Namespace{User: user, Msize: msize, mounts: make(map[string][]nsMember)}
+}
+
+// Mount attaches the tree of clnt at old. The flag is MREPL, MBEFORE or
+// MAFTER, optionally or'ed with MCREATE. Mounting a tree already in a
+// union at old moves it to its new place.
+func (ns *Namespace) Mount(clnt *Clnt, old string, flag uint32) error {
+	if clnt == nil {
+		return &WarpError{Ebaduse, ""}
+	}
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.add(cleanPath(old), nsMember{clnt, "/", flag})
+	return nil
+}
+
+// MountAddr mounts the server at the network address with aname and
+// attaches its tree at old (see Mount). The connection is closed when it
+// is no longer in the namespace.
+func (ns *Namespace) MountAddr(ntype, addr, aname, old string, flag uint32) error {
+	clnt, err := Mount(ntype, addr, aname, ns.mountSize(), ns.User)
+	if err != nil {
+		return err
+	}
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.owned = append(ns.owned, clnt)
+	ns.add(cleanPath(old), nsMember{clnt, "/", flag})
+	return nil
+}
+
+// the message size MountAddr asks for
+func (ns *Namespace) mountSize() uint32 {
+	switch {
+	case ns.Msize == 0:
+		return MSIZE
+	case ns.Msize < IOHDRSZ:
+		return IOHDRSZ
+	}
+	return ns.Msize
+}
+
+// Bind makes the object named name in the namespace also appear at old.
+// The flag is as for Mount.
+func (ns *Namespace) Bind(name, old string, flag uint32) error {
+	ns.mu.Lock()
+	members := ns.resolve(cleanPath(name))
+	ns.mu.Unlock()
+
+	var err error = ErrorMsg(Enotexist, name)
+	for _, m := range members {
+		if _, err = m.clnt.Stat(m.path); err == nil {
+			m.flag = flag
+			ns.mu.Lock()
+			ns.add(cleanPath(old), m)
+			ns.mu.Unlock()
+			return nil
+		}
+	}
+	return err
+}
+
+// Unmount removes everything mounted or bound at old.
+func (ns *Namespace) Unmount(old string) error {
+	ns.mu.Lock()
+	old = cleanPath(old)
+	if _, ok := ns.mounts[old]; !ok {
+		ns.mu.Unlock()
+		return ErrorMsg(Enotexist, old)
+	}
+	delete(ns.mounts, old)
+	unused := ns.release()
+	ns.mu.Unlock()
+
+	for _, clnt := range unused {
+		clnt.Unmount()
+	}
+	return nil
+}
+
+// Close empties the namespace and closes the connections made by MountAddr.
+func (ns *Namespace) Close() {
+	ns.mu.Lock()
+	ns.mounts = make(map[string][]nsMember)
+	owned := ns.owned
+	ns.owned = nil
+	ns.mu.Unlock()
+
+	for _, clnt := range owned {
+		clnt.Unmount()
+	}
+}
+
+// Mounts returns the mount points of the namespace in sorted order.
+func (ns *Namespace) Mounts() []string {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	mp := make([]string, 0, len(ns.mounts))
+	for p := range ns.mounts {
+		mp = append(mp, p)
+	}
+	sort.Strings(mp)
+	return mp
+}
+
+// Walk walks to the named object. The returned Fid belongs to the Clnt of
+// the tree holding the object (see Fid.Clnt).
+func (ns *Namespace) Walk(name string) (*Fid, error) {
+	var fid *Fid
+	err := ns.each(name, func(m nsMember) (err error) {
+		fid, err = m.clnt.Walk(m.path)
+		return err
+	})
+	return fid, err
+}
+
+// Open opens the named object.
+func (ns *Namespace) Open(name string, mode uint8) (*Object, error) {
+	var obj *Object
+	err := ns.each(name, func(m nsMember) (err error) {
+		obj, err = m.clnt.Open(m.path, mode)
+		return err
+	})
+	return obj, err
+}
+
+// Create creates and opens the named object. In a union directory it is
+// created in the first member mounted with MCREATE.
+func (ns *Namespace) Create(name string, perm uint32, mode uint8) (*Object, error) {
+	name = cleanPath(name)
+	if name == "/" {
+		return nil, ErrorMsg(Ename, name)
+	}
+	ns.mu.Lock()
+	members := ns.resolve(parentPath(name))
+	ns.mu.Unlock()
+
+	var dir *nsMember
+	for i := range members {
+		if len(members) == 1 || members[i].flag&MCREATE != 0 {
+			dir = &members[i]
+			break
+		}
+	}
+	if dir == nil {
+		return nil, ErrorMsg(Eperm, name)
+	}
+	return dir.clnt.Create(joinPath(dir.path, path.Base(name)), perm, mode)
+}
+
+// Remove removes the named object.
+func (ns *Namespace) Remove(name string) error {
+	return ns.each(name, func(m nsMember) error {
+		return m.clnt.Remove(m.path)
+	})
+}
+
+// Stat returns the metadata of the named object. A mount point is given
+// the name it has in the namespace.
+func (ns *Namespace) Stat(name string) (*Dir, error) {
+	name = cleanPath(name)
+	var d *Dir
+	err := ns.each(name, func(m nsMember) (err error) {
+		d, err = m.clnt.Stat(m.path)
+		return err
+	})
+	if err != nil {
+		if ns.above(name) {
+			return ns.dir(name), nil
+		}
+		return nil, err
+	}
+	ns.mu.Lock()
+	_, mp := ns.mounts[name]
+	ns.mu.Unlock()
+	if mp && name != "/" {
+		nd := *d
+		nd.Name = path.Base(name)
+		d = &nd
+	}
+	return d, nil
+}
+
+// ReadDir returns the entries of the named directory. The entries of a
+// union are merged; where names repeat, the first member's entry is used.
+// Mount points below the directory are listed as well.
+func (ns *Namespace) ReadDir(name string) ([]*Dir, error) {
+	name = cleanPath(name)
+	ns.mu.Lock()
+	members := ns.resolve(name)
+	ns.mu.Unlock()
+
+	var dirs []*Dir
+	seen := make(map[string]bool)
+	var err error = ErrorMsg(Enotexist, name)
+	found := false
+	for _, m := range members {
+		var ents []*Dir
+		if ents, err = m.clnt.ReadDir(m.path); err != nil {
+			continue
+		}
+		found = true
+		for _, d := range ents {
+			if !seen[d.Name] {
+				seen[d.Name] = true
+				dirs = append(dirs, d)
+			}
+		}
+	}
+	if !found && !ns.above(name) {
+		return nil, err
+	}
+	for _, sub := range ns.below(name) {
+		if !seen[sub] {
+			seen[sub] = true
+			dirs = append(dirs, ns.dir(joinPath(name, sub)))
+		}
+	}
+	return dirs, nil
+}
+
+// call fn for each member holding name until one succeeds
+func (ns *Namespace) each(name string, fn func(m nsMember) error) error {
+	ns.mu.Lock()
+	members := ns.resolve(cleanPath(name))
+	ns.mu.Unlock()
+
+	var err error = ErrorMsg(Enotexist, name)
+	for i, m := range members {
+		e := fn(m)
+		if e == nil {
+			return nil
+		}
+		if i == 0 {
+			err = e
+		}
+	}
+	return err
+}
+
+// the members providing the clean path p; called with ns.mu held
+func (ns *Namespace) resolve(p string) []nsMember {
+	for mp := p; ; mp = parentPath(mp) {
+		if members, ok := ns.mounts[mp]; ok {
+			rest := strings.TrimPrefix(p, mp)
+			res := make([]nsMember, len(members))
+			for i, m := range members {
+				res[i] = nsMember{m.clnt, joinPath(m.path, rest), m.flag}
+			}
+			return res
+		}
+		if mp == "/" {
+			return nil
+		}
+	}
+}
+
+// add m to the mount point p, moving it if it is there already; called
+// with ns.mu held
+func (ns *Namespace) add(p string, m nsMember) {
+	members, ok := ns.mounts[p]
+	if !ok && m.flag&(MBEFORE|MAFTER) != 0 {
+		// a new union includes what was already at p
+		members = ns.resolve(p)
+	}
+	kept := make([]nsMember, 0, len(members))
+	for _, om := range members {
+		if om.clnt != m.clnt || om.path != m.path {
+			kept = append(kept, om)
+		}
+	}
+	members = kept
+	switch {
+	case m.flag&MBEFORE != 0:
+		members = append([]nsMember{m}, members...)
+	case m.flag&MAFTER != 0:
+		members = append(members, m)
+	default:
+		members = []nsMember{m}
+	}
+	ns.mounts[p] = members
+}
+
+// drop the owned connections no longer in the mount table; called with
+// ns.mu held
+func (ns *Namespace) release() []*Clnt {
+	used := make(map[*Clnt]bool)
+	for _, members := range ns.mounts {
+		for _, m := range members {
+			used[m.clnt] = true
+		}
+	}
+	var unused []*Clnt
+	owned := ns.owned[:0]
+	for _, clnt := range ns.owned {
+		if used[clnt] {
+			owned = append(owned, clnt)
+		} else {
+			unused = append(unused, clnt)
+		}
+	}
+	ns.owned = owned
+	return unused
+}
+
+// the names of the next elements of mount points below p
+func (ns *Namespace) below(p string) []string {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	prefix := strings.TrimSuffix(p, "/") + "/"
+	var names []string
+	seen := make(map[string]bool)
+	for mp := range ns.mounts {
+		if mp == p || !strings.HasPrefix(mp, prefix) {
+			continue
+		}
+		sub := strings.SplitN(mp[len(prefix):], "/", 2)[0]
+		if !seen[sub] {
+			seen[sub] = true
+			names = append(names, sub)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// true if p is a directory above some mount point
+func (ns *Namespace) above(p string) bool {
+	return p == "/" || len(ns.below(p)) > 0
+}
+
+// the metadata of a directory provided by the namespace itself
+func (ns *Namespace) dir(p string) *Dir {
+	return &Dir{
+		Qid:  Qid{Type: QTDIR},
+		Mode: DMDIR | 0555,
+		Name: path.Base(p),
+		Uid:  NOUID,
+		Gid:  NOUID,
+		Muid: NOUID,
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package warp9
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestNamespaceMsize(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// the server reports the msize of each Tversion and hangs up
+	msizes := make(chan uint32, 1)
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			var hdr [4]byte
+			if _, err := io.ReadFull(c, hdr[:]); err == nil {
+				pkt := make([]byte, binary.LittleEndian.Uint32(hdr[:]))
+				copy(pkt, hdr[:])
+				if _, err := io.ReadFull(c, pkt[len(hdr):]); err == nil {
+					if fc, err, _ := Unpack(pkt); err == nil {
+						msizes <- fc.Msize
+					}
+				}
+			}
+			c.Close()
+		}
+	}()
+
+	for _, tt := range []struct{ msize, want uint32 }{
+		{0, MSIZE + IOHDRSZ},
+		{1, 2 * IOHDRSZ},
+		{4096, 4096 + IOHDRSZ},
+	} {
+		ns := NewNamespace(Identity.User(1), tt.msize)
+		if err := ns.MountAddr("tcp", l.Addr().String(), "", "/x", MREPL); err == nil {
+			t.Errorf("msize %d: mounted a server that hung up", tt.msize)
+		}
+		if got := <-msizes; got != tt.want {
+			t.Errorf("msize %d: Tversion msize %d, want %d", tt.msize, got, tt.want)
+		}
+	}
+}
+
+func TestNamespaceResolve(t *testing.T) {
+	a, b, c := &Clnt{}, &Clnt{}, &Clnt{}
+	ns := NewNamespace(nil, 0)
+	ns.Mount(a, "/", MREPL)
+	ns.Mount(b, "/svc/b", MREPL)
+	ns.Mount(c, "/svc/b", MBEFORE)
+	ns.owned = []*Clnt{a, b, c}
+
+	tests := []struct {
+		name string
+		want []nsMember
+	}{
+		{"/etc/conf", []nsMember{{a, "/etc/conf", MREPL}}},
+		{"/svc", []nsMember{{a, "/svc", MREPL}}},
+		{"/svc/b/x", []nsMember{{c, "/x", MBEFORE}, {b, "/x", MREPL}}},
+	}
+	for _, tt := range tests {
+		got := ns.resolve(cleanPath(tt.name))
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: member %d is %v, want %v", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+	// binding a member again moves it
+	ns.Mount(b, "/svc/b", MBEFORE)
+	if got := ns.resolve("/svc/b"); len(got) != 2 || got[0].clnt != b || got[1].clnt != c {
+		t.Errorf("after a rebind: %v", got)
+	}
+	if got := ns.below("/"); len(got) != 1 || got[0] != "svc" {
+		t.Errorf("below /: %v", got)
+	}
+
+	// the connections of an unmounted union are released
+	delete(ns.mounts, "/svc/b")
+	if unused := ns.release(); len(unused) != 2 || len(ns.owned) != 1 || ns.owned[0] != a {
+		t.Errorf("released %d, kept %d", len(unused), len(ns.owned))
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package warp9
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// An NsEntry is one line of a namespace description:
+//
+//	mount [-abc] dialstring old [aname]
+//	bind [-abc] name old
+//
+// The options are those of Plan 9: -b adds to the front of a union (MBEFORE),
+// -a to the end (MAFTER) and -c permits creation (MCREATE). A dial string
+// is net!host!port, net!addr or a plain host:port using tcp. Blank lines and
+// text after a '#' are ignored.
+type NsEntry struct {
+	Op    string // "mount" or "bind"
+	Net   string // mount: network, per net.Dial
+	Addr  string // mount: network address, per net.Dial
+	Aname string // mount: attach name on the server
+	Name  string // bind: the path bound
+	Old   string // where the tree or object appears
+	Flag  uint32 // MREPL, MBEFORE or MAFTER, optionally or'ed with MCREATE
+	Line  int    // line number in the description
+}
+
+// ParseNamespace reads a namespace description.
+func ParseNamespace(r io.Reader) ([]NsEntry, error) {
+	var entries []NsEntry
+	scan := bufio.NewScanner(r)
+	for line := 1; scan.Scan(); line++ {
+		text := scan.Text()
+		if n := strings.IndexByte(text, '#'); n >= 0 {
+			text = text[:n]
+		}
+		f := strings.Fields(text)
+		if len(f) == 0 {
+			continue
+		}
+		e, err := parseNsEntry(f)
+		if err != nil {
+			return nil, fmt.Errorf("namespace line %d: %v", line, err)
+		}
+		e.Line = line
+		entries = append(entries, e)
+	}
+	if err := scan.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func parseNsEntry(f []string) (NsEntry, error) {
+	e := NsEntry{Op: f[0]}
+	args := f[1:]
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		for _, c := range args[0][1:] {
+			switch c {
+			case 'a':
+				e.Flag |= MAFTER
+			case 'b':
+				e.Flag |= MBEFORE
+			case 'c':
+				e.Flag |= MCREATE
+			default:
+				return e, fmt.Errorf("%s: unknown option -%c", e.Op, c)
+			}
+		}
+		args = args[1:]
+	}
+	if e.Flag&MORDER == MORDER {
+		return e, fmt.Errorf("%s: -a and -b together", e.Op)
+	}
+
+	switch e.Op {
+	case "mount":
+		if len(args) < 2 || len(args) > 3 {
+			return e, fmt.Errorf("usage: mount [-abc] dialstring old [aname]")
+		}
+		var err error
+		if e.Net, e.Addr, err = ParseDialString(args[0]); err != nil {
+			return e, err
+		}
+		e.Old = args[1]
+		if len(args) > 2 {
+			e.Aname = args[2]
+		}
+	case "bind":
+		if len(args) != 2 {
+			return e, fmt.Errorf("usage: bind [-abc] name old")
+		}
+		e.Name, e.Old = args[0], args[1]
+		if !strings.HasPrefix(e.Name, "/") {
+			return e, fmt.Errorf("bind: %q is not an absolute path", e.Name)
+		}
+	default:
+		return e, fmt.Errorf("unknown command %q", e.Op)
+	}
+	if !strings.HasPrefix(e.Old, "/") {
+		return e, fmt.Errorf("%s: %q is not an absolute path", e.Op, e.Old)
+	}
+	return e, nil
+}
+
+// ParseDialString splits a Plan 9 style dial string, net!host!port or
+// net!addr, into the network and address used by net.Dial. A string
+// without '!' is a tcp address.
+func ParseDialString(s string) (ntype, addr string, err error) {
+	f := strings.Split(s, "!")
+	switch {
+	case len(f) == 1 && s != "":
+		return "tcp", s, nil
+	case len(f) == 2 && f[0] != "" && f[1] != "":
+		return f[0], f[1], nil
+	case len(f) == 3 && f[0] != "" && f[2] != "":
+		return f[0], net.JoinHostPort(f[1], f[2]), nil
+	}
+	return "", "", fmt.Errorf("bad dial string %q", s)
+}
+
+// Load applies the namespace description read from r, in order. It stops
+// at the first entry that fails; the entries before it stay applied.
+func (ns *Namespace) Load(r io.Reader) error {
+	entries, err := ParseNamespace(r)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		switch e.Op {
+		case "mount":
+			err = ns.MountAddr(e.Net, e.Addr, e.Aname, e.Old, e.Flag)
+		case "bind":
+			err = ns.Bind(e.Name, e.Old, e.Flag)
+		}
+		if err != nil {
+			return fmt.Errorf("namespace line %d: %s %s: %w", e.Line, e.Op, e.Old, err)
+		}
+	}
+	return nil
+}
+
+// LoadFile applies the namespace description in the named file.
+func (ns *Namespace) LoadFile(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return ns.Load(f)
+}
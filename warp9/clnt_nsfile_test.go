@@ -0,0 +1,50 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package warp9
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseNamespace(t *testing.T) {
+	entries, err := ParseNamespace(strings.NewReader(`
+# a comment
+mount tcp!db.local!9090 /svc/db   # trailing comment
+mount -bc unix!/tmp/bin.sock /bin bin
+mount host:9090 /svc/x
+bind -a /svc/db/info /info
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []NsEntry{
+		{Op: "mount", Net: "tcp", Addr: "db.local:9090", Old: "/svc/db", Line: 3},
+		{Op: "mount", Net: "unix", Addr: "/tmp/bin.sock", Aname: "bin", Old: "/bin", Flag: MBEFORE | MCREATE, Line: 4},
+		{Op: "mount", Net: "tcp", Addr: "host:9090", Old: "/svc/x", Line: 5},
+		{Op: "bind", Name: "/svc/db/info", Old: "/info", Flag: MAFTER, Line: 6},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("entries: %+v", entries)
+	}
+	for i := range want {
+		if entries[i] != want[i] {
+			t.Errorf("entry %d: %+v, want %+v", i, entries[i], want[i])
+		}
+	}
+
+	for _, bad := range []string{
+		"mount tcp!host!9090",
+		"mount -ab tcp!host!9090 /x",
+		"mount !host!9090 /x",
+		"bind -z /a /b",
+		"bind a /b",
+		"bind /a b",
+		"unmount /a",
+	} {
+		if _, err := ParseNamespace(strings.NewReader("\n" + bad)); err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("%q: %v", bad, err)
+		}
+	}
+}
@@ -112,6 +112,12 @@ func (clnt *Clnt) FCreate(fid *Fid, name string, perm uint32, mode uint8, extatt
 		return clnt.Perr(err)
 	}
 
+	if fid.path != "" {
+		fid.path = joinPath(fid.path, name)
+	}
+	if c := clnt.Cache(); c != nil {
+		c.mutated(fid.path, true)
+	}
 	fid.Qid = rc.Qid
 	fid.Iounit = rc.Iounit
 	if fid.Iounit == 0 || fid.Iounit > clnt.Msize-IOHDRSZ {
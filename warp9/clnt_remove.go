@@ -14,6 +14,9 @@ func (clnt *Clnt) FRemove(fid *Fid) error {
 	}
 
 	_, err = clnt.Rpc(tc)
+	if c := clnt.Cache(); c != nil {
+		c.mutated(fid.path, true)
+	}
 	clnt.fidpool.putId(fid.Fid)
 	fid.Fid = NOFID
 
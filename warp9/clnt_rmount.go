@@ -51,16 +51,7 @@ func ReverseMountListener(ntype, addr string, aname string, msize uint32, user U
 	
 // perform Accept on listener in a loop; until closed or error.
 func handleListen(l net.Listener, aname string, msize uint32, user User, mounted RMountConn, rmounterr RMountError) {
-
-	for {
-		c, err := l.Accept()
-		if err != nil {
-			rmounterr(0,err)
-			return //exit thread
-		} else {
-			go handleConnection(c, aname, msize, user, mounted, rmounterr)
-		}
-	}
+	rmounterr(0, ReverseMountServe(l, aname, msize, user, mounted, rmounterr))
 }
 
 // mount the server that just called on the Conn
@@ -76,3 +67,15 @@ func handleConnection(c net.Conn, aname string, msize uint32, user User, mounted
 	//exit thread
 }
 
+// ReverseMountServe is ReverseMountListener on an existing listener. It
+// accepts connections until l is closed and returns the error that ended
+// the loop; rmounterr is called for mounts that fail.
+func ReverseMountServe(l net.Listener, aname string, msize uint32, user User, mounted RMountConn, rmounterr RMountError) error {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go handleConnection(c, aname, msize, user, mounted, rmounterr)
+	}
+}
@@ -5,7 +5,12 @@
 package warp9
 
 // Returns the metadata for a named object, or an Error.
+// If a Cache is attached the result may come from the cache.
 func (clnt *Clnt) Stat(path string) (*Dir, error) {
+	if c := clnt.Cache(); c != nil {
+		return clnt.cachedStat(c, path)
+	}
+
 	fid, err := clnt.Walk(path)
 	if err != nil {
 		return nil, err
@@ -41,5 +46,24 @@ func (clnt *Clnt) FWstat(fid *Fid, dir *Dir) error {
 	}
 
 	_, err = clnt.Rpc(tc)
+	if c := clnt.Cache(); c != nil {
+		c.mutated(fid.path, true)
+	}
 	return err
 }
+
+// NullDir returns a Dir whose fields all hold the "don't change" values
+// understood by Twstat: ~0 for numeric fields and "" for strings. Set only
+// the fields to be modified before passing it to FWstat.
+func NullDir() *Dir {
+	return &Dir{
+		Qid:    Qid{Type: 0xFF, Version: 0xFFFFFFFF, Path: 0xFFFFFFFFFFFFFFFF},
+		Mode:   0xFFFFFFFF,
+		Atime:  0xFFFFFFFF,
+		Mtime:  0xFFFFFFFF,
+		Length: 0xFFFFFFFFFFFFFFFF,
+		Uid:    NOUID,
+		Gid:    NOUID,
+		Muid:   NOUID,
+	}
+}
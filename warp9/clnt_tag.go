@@ -52,7 +52,7 @@ func (tag *Tag) reqproc() {
 		case r := <-tag.respchan:
 			rc := r.Rc
 			fid := r.fid
-			err := r.Rc.Type == Rerror
+			err := rc == nil || rc.Type == Rerror
 
 			switch r.Tc.Type {
 			case Tauth:
@@ -63,6 +63,7 @@ func (tag *Tag) reqproc() {
 			case Tattach:
 				if !err {
 					fid.Qid = rc.Qid
+					fid.walked = true
 				} else {
 					fid.User = nil
 				}
@@ -75,18 +76,21 @@ func (tag *Tag) reqproc() {
 					fid.User = nil
 				}
 
-			case Topen:
-			case Tcreate:
+			case Topen, Tcreate:
 				if !err {
 					fid.Iounit = rc.Iounit
+					if fid.Iounit == 0 || fid.Iounit > tag.clnt.Msize-IOHDRSZ {
+						fid.Iounit = tag.clnt.Msize - IOHDRSZ
+					}
 					fid.Qid = rc.Qid
 				} else {
 					fid.Mode = 0
 				}
 
-			case Tclunk:
-			case Tremove:
+			case Tclunk, Tremove:
 				tag.clnt.fidpool.putId(fid.Fid)
+				fid.walked = false
+				fid.Fid = NOFID
 			}
 
 			tag.reqchan <- r
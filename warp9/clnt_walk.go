@@ -25,6 +25,9 @@ func (clnt *Clnt) FWalk(fid *Fid, newfid *Fid, wnames []string) (*Qid, error) {
 	}
 
 	newfid.walked = true
+	if fid.path != "" {
+		newfid.path = joinPath(fid.path, wnames...)
+	}
 	return &rc.Qid, nil
 }
 
@@ -44,6 +47,7 @@ func (clnt *Clnt) Walk(path string) (*Fid, error) {
 		path = path[i:]
 	}
 
+	wpath := path
 	wnames := strings.Split(path, "/")
 	newfid := clnt.FidAlloc()
 	fid := clnt.Root
@@ -88,6 +92,10 @@ func (clnt *Clnt) Walk(path string) (*Fid, error) {
 		}
 	}
 
+	newfid.path = cleanPath(wpath)
+	if c := clnt.Cache(); c != nil {
+		c.walked(newfid.path, newfid.Qid)
+	}
 	return newfid, nil
 
 error:
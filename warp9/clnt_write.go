@@ -18,6 +18,9 @@ func (clnt *Clnt) Write(fid *Fid, data []byte, offset uint64) (int, error) {
 	}
 
 	rc, err := clnt.Rpc(tc)
+	if c := clnt.Cache(); c != nil {
+		c.mutated(fid.path, true)
+	}
 	if err != nil {
 		return 0, clnt.Perr(err)
 	}
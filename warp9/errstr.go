@@ -5,6 +5,8 @@ package warp9
 
 import (
 	"fmt"
+	"io"
+	"io/fs"
 )
 
 // WarpError The Warp9 protocol expresses an error as a int16 value with negative values representing framework
@@ -58,6 +60,32 @@ func (e *WarpError) IsFWError() bool {
 	return e.errcode <= Egood
 }
 
+// Code returns the protocol error code.
+func (e *WarpError) Code() int16 {
+	return e.errcode
+}
+
+// Is reports whether the error matches target. Another WarpError matches
+// when the codes are equal. The framework codes are also mapped onto the
+// standard sentinel errors so errors.Is(err, fs.ErrNotExist),
+// fs.ErrPermission, fs.ErrExist and io.EOF work as expected.
+func (e *WarpError) Is(target error) bool {
+	switch target {
+	case fs.ErrNotExist:
+		return e.errcode == Enotexist || e.errcode == Enoent
+	case fs.ErrPermission:
+		return e.errcode == Eperm || e.errcode == Enotowner
+	case fs.ErrExist:
+		return e.errcode == Eexist
+	case io.EOF:
+		return e.errcode == Eeof
+	}
+	if t, ok := target.(*WarpError); ok {
+		return e.errcode == t.errcode
+	}
+	return false
+}
+
 // WarpErrorEOF end of object Data. alias of io.EOF
 var WarpErrorEOF = &WarpError{Eeof, ""}
 
@@ -239,7 +239,10 @@ func Unpack(buf []byte) (fc *Fcall, err error, fcsz int) {
 	case Twstat:
 		fc.Fid, p = gint32(p)
 		m, p = gint16(p)
-		p, _ = gstat(p, &fc.Dir)
+		p, err = gstat(p, &fc.Dir)
+		if err != nil {
+			return nil, err, 0
+		}
 
 	case Rflush, Rclunk, Rremove, Rwstat:
 	}
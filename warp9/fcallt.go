@@ -217,7 +217,7 @@ func (fc *Fcall) packTstat(fid uint32) error {
 
 // Create a Twstat message in the specified Fcall.
 func (fc *Fcall) packTwstat(fid uint32, d *Dir) error {
-	stsz := 0            // statsz(d, dotu)
+	stsz := statsz(d)
 	size := 4 + 2 + stsz /* fid[4] stat[n] */
 	p, err := fc.packCommon(size, Twstat)
 	if err != nil {
@@ -89,6 +89,7 @@ func (srv *Srv) NewConnWait(c net.Conn) {
 // net,addr are same as used in net.Dial()
 // wait==true block and serve (see NewConnWait()), else return see NewConn())
 // return nil on success; else err is a Dial() error
+// See Connector for a connection that is dialed again when it fails.
 //
 func (srv *Srv) InitiateConn(nettyp, addr string, wait bool) error {
 	c, e := net.Dial(nettyp, addr)
@@ -277,6 +278,12 @@ func (conn *Conn) RemoteAddr() net.Addr {
 	return conn.conn.RemoteAddr()
 }
 
+// Close closes the network connection; the connection ends as if the
+// client had gone away.
+func (conn *Conn) Close() error {
+	return conn.conn.Close()
+}
+
 // Return the local address of the connection.
 func (conn *Conn) LocalAddr() net.Addr {
 	return conn.conn.LocalAddr()
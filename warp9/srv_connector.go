@@ -0,0 +1,198 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package warp9
+
+import (
+	"crypto/tls"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// Default retry intervals of a Connector.
+const (
+	DefaultMinBackoff = time.Second
+	DefaultMaxBackoff = time.Minute
+	DefaultJitter     = 0.2
+)
+
+// ConnectorState is the state of a Connector.
+type ConnectorState int
+
+const (
+	ConnectorStopped   ConnectorState = iota // not started, or stopped
+	ConnectorDialing                         // dialing the remote address
+	ConnectorConnected                       // serving a connection
+	ConnectorWaiting                         // waiting to dial again
+)
+
+func (s ConnectorState) String() string {
+	switch s {
+	case ConnectorStopped:
+		return "stopped"
+	case ConnectorDialing:
+		return "dialing"
+	case ConnectorConnected:
+		return "connected"
+	case ConnectorWaiting:
+		return "waiting"
+	}
+	return "unknown"
+}
+
+// ConnectorOps is implemented by servers that want to hear about the
+// failed dials of their Connectors. Established connections are reported
+// through ConnOps like any other.
+type ConnectorOps interface {
+	// ConnectFailed is called after a dial failed; the next attempt is
+	// made after wait.
+	ConnectFailed(c *Connector, err error, wait time.Duration)
+}
+
+// A Connector keeps an outbound connection from the server to a remote
+// address, such as a relay or reverse mount listener, and serves it. When
+// the connection fails or cannot be made, it is dialed again after a delay
+// that doubles from MinBackoff up to MaxBackoff and is spread by Jitter.
+// The fields may be changed before Start.
+type Connector struct {
+	Net, Addr  string
+	TLS        *tls.Config   // if set, the connection is made with TLS
+	Dialer     net.Dialer    // dial options; Dialer.Timeout bounds each attempt
+	MinBackoff time.Duration // first delay after a failure
+	MaxBackoff time.Duration // limit of the delay between attempts
+	Jitter     float64       // fraction by which each delay is randomly varied, 0..1
+
+	srv   *Srv
+	mu    sync.Mutex
+	state ConnectorState
+	err   error    // why the last attempt failed or the connection ended
+	nc    net.Conn // the connection being served
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// NewConnector returns a stopped Connector for the server to the network
+// address, with the default retry intervals.
+func (srv *Srv) NewConnector(ntype, addr string) *Connector {
+	return &Connector{
+		Net:        ntype,
+		Addr:       addr,
+		Dialer:     net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+		MinBackoff: DefaultMinBackoff,
+		MaxBackoff: DefaultMaxBackoff,
+		Jitter:     DefaultJitter,
+		srv:        srv,
+	}
+}
+
+// Start starts connecting in the background. It does nothing if the
+// Connector is running.
+func (c *Connector) Start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stop != nil {
+		return
+	}
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+	go c.run(c.stop, c.done)
+}
+
+// Stop closes the connection and stops reconnecting. It returns once the
+// connection has been closed.
+func (c *Connector) Stop() {
+	c.mu.Lock()
+	stop, done, nc := c.stop, c.done, c.nc
+	c.stop = nil
+	c.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	if nc != nil {
+		nc.Close()
+	}
+	<-done
+}
+
+// State reports the state of the Connector and the error of the last
+// failed attempt or ended connection.
+func (c *Connector) State() (ConnectorState, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state, c.err
+}
+
+func (c *Connector) setState(state ConnectorState, nc net.Conn, err error) {
+	c.mu.Lock()
+	c.state = state
+	c.nc = nc
+	if err != nil {
+		c.err = err
+	}
+	c.mu.Unlock()
+}
+
+func (c *Connector) run(stop, done chan struct{}) {
+	defer close(done)
+	defer c.setState(ConnectorStopped, nil, nil)
+
+	backoff := c.MinBackoff
+	for {
+		c.setState(ConnectorDialing, nil, nil)
+		nc, err := c.dial()
+		wait := c.delay(backoff)
+		if err != nil {
+			c.setState(ConnectorWaiting, nil, err)
+			if op, ok := (c.srv.ops).(ConnectorOps); ok {
+				op.ConnectFailed(c, err, wait)
+			}
+			if backoff *= 2; backoff > c.MaxBackoff {
+				backoff = c.MaxBackoff
+			}
+		} else {
+			c.setState(ConnectorConnected, nc, nil)
+			select {
+			case <-stop:
+				// stopped while dialing
+				nc.Close()
+				return
+			default:
+			}
+			c.srv.NewConnWait(nc)
+			nc.Close()
+			backoff = c.MinBackoff
+			wait = c.delay(backoff)
+			c.setState(ConnectorWaiting, nil, &WarpError{Econn, ""})
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (c *Connector) dial() (net.Conn, error) {
+	if c.TLS != nil {
+		return tls.DialWithDialer(&c.Dialer, c.Net, c.Addr, c.TLS)
+	}
+	return c.Dialer.Dial(c.Net, c.Addr)
+}
+
+// d varied by up to Jitter either way, and at most MaxBackoff
+func (c *Connector) delay(d time.Duration) time.Duration {
+	if c.Jitter > 0 {
+		d += time.Duration(float64(d) * c.Jitter * (2*rand.Float64() - 1))
+	}
+	if c.MaxBackoff > 0 && d > c.MaxBackoff {
+		d = c.MaxBackoff
+	}
+	if d < 0 {
+		d = 0
+	}
+	return d
+}
@@ -0,0 +1,119 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package warp9
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// wait up to 5 seconds for cond, failing the test if it never holds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// a server counting its connections and failed dials; it is sent no
+// requests, so it serves none
+type connectorOps struct {
+	SrvReqOps
+	sync.Mutex
+	opened, closed, failed int
+}
+
+func (s *connectorOps) ConnOpened(conn *Conn) {
+	s.Lock()
+	s.opened++
+	s.Unlock()
+}
+
+func (s *connectorOps) ConnClosed(conn *Conn) {
+	s.Lock()
+	s.closed++
+	s.Unlock()
+}
+
+func (s *connectorOps) ConnectFailed(c *Connector, err error, wait time.Duration) {
+	s.Lock()
+	s.failed++
+	s.Unlock()
+}
+
+func (s *connectorOps) counts() (opened, closed, failed int) {
+	s.Lock()
+	defer s.Unlock()
+	return s.opened, s.closed, s.failed
+}
+
+func TestConnector(t *testing.T) {
+	// an address with nothing listening yet
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	ops := &connectorOps{}
+	srv := &Srv{Id: "edge"}
+	if !srv.Start(ops) {
+		t.Fatal("unable to start server")
+	}
+	c := srv.NewConnector("tcp", addr)
+	c.MinBackoff = 10 * time.Millisecond
+	c.MaxBackoff = 20 * time.Millisecond
+	c.Start()
+	defer c.Stop()
+
+	waitFor(t, "failed dials", func() bool { _, _, failed := ops.counts(); return failed >= 2 })
+	if state, err := c.State(); state == ConnectorConnected || err == nil {
+		t.Errorf("state with no listener: %v, %v", state, err)
+	}
+
+	if l, err = net.Listen("tcp", addr); err != nil {
+		t.Skip(err)
+	}
+	defer l.Close()
+	conns := make(chan net.Conn, 4)
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conns <- nc
+		}
+	}()
+	accept := func() net.Conn {
+		select {
+		case nc := <-conns:
+			return nc
+		case <-time.After(5 * time.Second):
+			t.Fatal("no connection")
+		}
+		return nil
+	}
+	nc := accept()
+	waitFor(t, "the connection", func() bool { state, _ := c.State(); return state == ConnectorConnected })
+
+	// the connector dials again when the connection is lost
+	nc.Close()
+	nc = accept()
+	defer nc.Close()
+	waitFor(t, "the second connection", func() bool { opened, _, _ := ops.counts(); return opened == 2 })
+
+	c.Stop()
+	if state, _ := c.State(); state != ConnectorStopped {
+		t.Errorf("state after Stop: %v", state)
+	}
+	waitFor(t, "the connections to close", func() bool { opened, closed, _ := ops.counts(); return closed == opened })
+}
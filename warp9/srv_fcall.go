@@ -20,6 +20,8 @@ package warp9
 // this allows common book-keeping to be done on behalf of the user's handlers
 //
 
+import "path"
+
 func (srv *Srv) version(req *SrvReq) {
 	tc := req.Tc
 	conn := req.Conn
@@ -84,7 +86,10 @@ func (srv *Srv) auth(req *SrvReq) {
 	req.Afid.Type = QTAUTH
 	if aop, ok := (srv.ops).(AuthOps); ok {
 		aqid, err := aop.AuthInit(req.Afid, tc.Aname)
-		if err != nil {
+		if werr, ok := err.(*WarpError); ok && werr.Equals(Enoauth) {
+			// nothing to authenticate for aname
+			req.RespondError(werr)
+		} else if err != nil {
 			req.RespondError(&WarpError{Eauthinit, ""})
 		} else {
 			aqid.Type |= QTAUTH // just in case
@@ -148,6 +153,7 @@ func (srv *Srv) attach(req *SrvReq) {
 func (srv *Srv) attachPost(req *SrvReq) {
 	if req.Rc != nil && req.Rc.Type == Rattach {
 		req.Fid.Type = req.Rc.Qid.Type
+		req.Fid.Path = "/"
 		req.Fid.IncRef()
 	}
 }
@@ -245,6 +251,7 @@ func (srv *Srv) walkPost(req *SrvReq) {
 	}
 
 	req.Newfid.Type = rc.Qid.Type
+	req.Newfid.Path = path.Join(append([]string{req.Fid.Path}, req.Tc.Wname...)...)
 
 	if req.Newfid.fid != req.Fid.fid {
 		req.Newfid.IncRef()
@@ -311,6 +318,7 @@ func (srv *Srv) create(req *SrvReq) {
 func (srv *Srv) createPost(req *SrvReq) {
 	if req.Rc != nil && req.Rc.Type == Rcreate && req.Fid != nil {
 		req.Fid.Type = req.Rc.Qid.Type
+		req.Fid.Path = path.Join(req.Fid.Path, req.Tc.Name)
 		req.Fid.opened = true
 	}
 }
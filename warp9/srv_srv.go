@@ -29,7 +29,8 @@ type AuthOps interface {
 	// process on SrvFid afid. The user that is being authenticated
 	// is referred by afid.User. The function should return the Qid
 	// for the authentication object, or an Error if the user can't be
-	// authenticated
+	// authenticated. An Enoauth error reaches the client as is, telling
+	// it that aname needs no authentication.
 	AuthInit(afid *SrvFid, aname string) (*Qid, error)
 
 	// AuthDestroy is called when an authentication fid is destroyed.
@@ -130,6 +131,7 @@ type SrvFid struct {
 	Diroffset uint64      // If directory, the next valid read position
 	Dirents   []byte      // If directory, the serialized dirents
 	User      User        // The SrvFid's user
+	Path      string      // Path of the object from the root attached to, as walked
 	Aux       interface{} // Can be used by the object server implementation for per-SrvFid data
 }
 
@@ -77,6 +77,15 @@ const (
 	ORCLOSE = 64 // or'ed in, remove on close
 )
 
+// Flags for binding an object into a namespace
+const (
+	MREPL   = 0x0000 // replace the old object
+	MBEFORE = 0x0001 // add to the front of a union directory
+	MAFTER  = 0x0002 // add to the end of a union directory
+	MCREATE = 0x0004 // objects created in the union are created in this member
+	MORDER  = 0x0003 // mask for the ordering bits
+)
+
 // Object modes
 const (
 	// object types -- high order 8bits
@@ -4,42 +4,523 @@
 package wkit
 
 import (
+	"strings"
 	"sync"
 
 	"github.com/lavaorg/warp/warp9"
 )
 
+// A Binder is a Directory whose contents are the union of a list of bound
+// Items.
 type Binder interface {
-	Lookup(item string) Item
-	IsDirectory() bool
+	Directory
+	Bind(item Item, flag uint32) error
+	Unbind(item Item) error
+	Members() []Item
 }
 
-// a BindPoint is both a binder and a DirItem.  As a DirItem it will have a directory
-// entry of its contents Item.  When its data is read the data is the concatenation
-// of all of its bind-points chain with early entries obscuring the later entries (e.g.
-// a uniion directory)
+// a BindPoint is both a binder and a Directory. Items are bound onto it with
+// Plan 9 bind semantics: MREPL replaces the current members, MBEFORE and
+// MAFTER add a directory to the front or back of the union, and MCREATE marks
+// the member that receives Create requests.
+//
+// Walks resolve a name through the members in order and the first member
+// holding the name wins. When its data is read the data is the concatenation
+// of all of its members' entries with early entries obscuring the later
+// entries (e.g. a union directory).
 type BindPoint struct {
 	sync.Mutex
-	DirItem
-	dir    bool
-	parent *BindPoint
-	next   *BindPoint //reference to union-peers (we only ever look back)
+	*BaseItem
+	orig    Item         // the item the bind point was created over, if any
+	members []bindMember // union members, searched in order
+	buffer  []byte       // merged directory entries while open
 }
 
+// one element of a union
+type bindMember struct {
+	item   Item
+	create bool
+}
+
+// NewBindPoint returns a BindPoint over i. The bind point takes i's name and
+// i is its only member until other items are bound to it.
 func NewBindPoint(i Item) *BindPoint {
-	var b BindPoint
-	b.dir = i.IsDirectory() != nil
-	return &b
+	bp := &BindPoint{
+		BaseItem: NewBaseItem(i.GetDir().Name, i.IsDirectory() != nil),
+		orig:     i,
+		members:  []bindMember{{i, false}},
+	}
+	bp.update()
+	return bp
 }
 
-func (bp *BindPoint) GetDir() *warp9.Dir {
-	return bp.GetDir()
+// the bind point takes the Qid and mode of its first member
+func (bp *BindPoint) update() {
+	if len(bp.members) == 0 {
+		return
+	}
+	first := bp.members[0].item
+	d := first.GetDir()
+	bp.Qid = first.GetQid()
+	bp.Mode = d.Mode
+	bp.Mtime = d.Mtime
+	bp.Atime = d.Atime
 }
 
-func (d *BindPoint) Lookup(n string) Item {
+// Bind adds item to the union according to flag (MREPL, MBEFORE or MAFTER,
+// optionally or'ed with MCREATE). Only directories can form a union; a
+// non-directory can only replace the current members.
+func (bp *BindPoint) Bind(item Item, flag uint32) error {
+	if item == nil {
+		return warp9.ErrorCode(warp9.Einval)
+	}
+	bp.Lock()
+	defer bp.Unlock()
+
+	m := bindMember{item, flag&warp9.MCREATE != 0}
+	order := flag & warp9.MORDER
+	if order != warp9.MREPL {
+		if item.IsDirectory() == nil || (len(bp.members) > 0 && bp.members[0].item.IsDirectory() == nil) {
+			return warp9.ErrorCode(warp9.Enotdir)
+		}
+	}
+	switch order {
+	case warp9.MREPL:
+		bp.members = []bindMember{m}
+	case warp9.MBEFORE:
+		bp.members = append([]bindMember{m}, bp.members...)
+	case warp9.MAFTER:
+		bp.members = append(bp.members, m)
+	default:
+		return warp9.ErrorCode(warp9.Einval)
+	}
+	bp.update()
+	bp.buffer = nil
 	return nil
 }
 
-func (bp *BindPoint) IsDirectory() bool {
-	return bp.dir
+// Unbind removes item from the union. A nil item removes every member
+// except the item the bind point was created over; removing the last member
+// also restores that item.
+func (bp *BindPoint) Unbind(item Item) error {
+	bp.Lock()
+	defer bp.Unlock()
+
+	if item == nil {
+		bp.members = nil
+		if bp.orig != nil {
+			bp.members = []bindMember{{bp.orig, false}}
+		}
+		bp.update()
+		bp.buffer = nil
+		return nil
+	}
+	for i, m := range bp.members {
+		if m.item == item {
+			bp.members = append(bp.members[:i:i], bp.members[i+1:]...)
+			if len(bp.members) == 0 && bp.orig != nil {
+				bp.members = []bindMember{{bp.orig, false}}
+			}
+			bp.update()
+			bp.buffer = nil
+			return nil
+		}
+	}
+	return warp9.ErrorCode(warp9.Enotexist)
+}
+
+// Members returns the items of the union in search order.
+func (bp *BindPoint) Members() []Item {
+	bp.Lock()
+	defer bp.Unlock()
+	items := make([]Item, len(bp.members))
+	for i, m := range bp.members {
+		items[i] = m.item
+	}
+	return items
+}
+
+// the members that are directories, in search order
+func (bp *BindPoint) dirs() []Directory {
+	bp.Lock()
+	defer bp.Unlock()
+	dirs := make([]Directory, 0, len(bp.members))
+	for _, m := range bp.members {
+		if d := m.item.IsDirectory(); d != nil {
+			dirs = append(dirs, d)
+		}
+	}
+	return dirs
+}
+
+//
+// Directory Interface
+//
+
+func (bp *BindPoint) Name() string {
+	return bp.Dir.Name
+}
+
+// Walk resolves the first name through the members in order; the remaining
+// names are walked within the member directory that held the first one.
+func (bp *BindPoint) Walk(path []string) (Item, error) {
+	if len(path) < 1 {
+		return bp.Walked()
+	}
+	if path[0] == ".." {
+		parent := bp.Parent()
+		if parent == nil {
+			return nil, warp9.ErrorCode(warp9.Enotexist)
+		}
+		if len(path) == 1 {
+			return parent.Walked()
+		}
+		return parent.Walk(path[1:])
+	}
+
+	dirs := bp.dirs()
+	if len(dirs) == 0 {
+		return nil, warp9.ErrorCode(warp9.Enotdir)
+	}
+	var err error = warp9.ErrorCode(warp9.Enotexist)
+	for _, d := range dirs {
+		if len(path) == 1 {
+			var item Item
+			if item, err = d.Walk(path); err == nil {
+				return item, nil
+			}
+			continue
+		}
+		if !hasChild(d, path[0]) {
+			continue
+		}
+		return d.Walk(path)
+	}
+	return nil, err
+}
+
+// report whether d holds name. Directories that do not keep their children
+// in memory (e.g. a MountPoint) are probed with a walk.
+func hasChild(d Directory, name string) bool {
+	if children := d.Children(); children != nil {
+		_, ok := children[name]
+		return ok
+	}
+	item, err := d.Walk([]string{name})
+	if err != nil {
+		return false
+	}
+	item.Clunk()
+	return true
+}
+
+// Create creates name in the member bound with MCREATE. The create fails if
+// no member was bound with MCREATE or that member cannot create objects.
+func (bp *BindPoint) Create(name string, perm uint32, mode uint8) (Item, error) {
+	var target Item
+	bp.Lock()
+	for _, m := range bp.members {
+		if m.create {
+			target = m.item
+			break
+		}
+	}
+	bp.buffer = nil
+	bp.Unlock()
+
+	if target == nil {
+		return nil, warp9.ErrorCode(warp9.Eperm)
+	}
+	clone, err := target.Walked()
+	if err != nil {
+		return nil, err
+	}
+	c, ok := clone.(Creator)
+	if !ok {
+		return nil, warp9.ErrorCode(warp9.Eperm)
+	}
+	return c.Create(name, perm, mode)
+}
+
+// AddDirectory adds newDir to the first member directory.
+func (bp *BindPoint) AddDirectory(newDir Directory) {
+	if dirs := bp.dirs(); len(dirs) > 0 {
+		dirs[0].AddDirectory(newDir)
+	}
+}
+
+// AddItem adds item to the first member directory.
+func (bp *BindPoint) AddItem(item Item) {
+	if dirs := bp.dirs(); len(dirs) > 0 {
+		dirs[0].AddItem(item)
+	}
+}
+
+// Children returns the merged contents of the member directories; earlier
+// members hide entries of the same name in later members.
+func (bp *BindPoint) Children() map[string]Item {
+	content := make(map[string]Item)
+	dirs := bp.dirs()
+	for i := len(dirs) - 1; i >= 0; i-- {
+		for name, item := range dirs[i].Children() {
+			content[name] = item
+		}
+	}
+	return content
+}
+
+// RemoveItem removes item from the first member directory holding it.
+func (bp *BindPoint) RemoveItem(item Item) error {
+	name := item.GetDir().Name
+	for _, d := range bp.dirs() {
+		if _, ok := d.Children()[name]; ok {
+			return d.RemoveItem(item)
+		}
+	}
+	return warp9.ErrorCode(warp9.Enotexist)
+}
+
+//
+// Item Interface
+//
+
+// GetDir returns the bind point's Dir.
+func (bp *BindPoint) GetDir() *warp9.Dir {
+	return &bp.Dir
+}
+
+// Return the object as the interface type Item.
+func (bp *BindPoint) GetItem() Item {
+	return bp
+}
+
+// IsDirectory returns the bind point if its first member is a directory.
+func (bp *BindPoint) IsDirectory() Directory {
+	bp.Lock()
+	defer bp.Unlock()
+	if len(bp.members) > 0 && bp.members[0].item.IsDirectory() == nil {
+		return nil
+	}
+	return bp
+}
+
+// Walked returns a copy of the bind point so each fid has its own buffer.
+// A bind point holding a single non-directory yields that item.
+func (bp *BindPoint) Walked() (Item, error) {
+	bp.Lock()
+	if len(bp.members) > 0 && bp.members[0].item.IsDirectory() == nil {
+		item := bp.members[0].item
+		bp.Unlock()
+		return item.Walked()
+	}
+	nbp := &BindPoint{
+		orig:    bp.orig,
+		members: append([]bindMember(nil), bp.members...),
+	}
+	base := *bp.BaseItem
+	nbp.BaseItem = &base
+	bp.Unlock()
+	return nbp, nil
+}
+
+// Open resets the merged directory buffer.
+func (bp *BindPoint) Open(mode byte) (uint32, error) {
+	bp.buffer = nil
+	return bp.BaseItem.Open(mode)
+}
+
+// Clunk releases the merged directory buffer.
+func (bp *BindPoint) Clunk() error {
+	bp.buffer = nil
+	return bp.BaseItem.Clunk()
+}
+
+// Read returns the packed Dir entries of the union. Entries of earlier
+// members hide entries with the same name in later members.
+func (bp *BindPoint) Read(obuf []byte, off uint64, rcount uint32) (uint32, error) {
+	if bp.buffer == nil {
+		seen := make(map[string]bool)
+		bp.buffer = make([]byte, 0, 300)
+		for _, d := range bp.dirs() {
+			dirs, err := readDirectory(d)
+			if err != nil {
+				warp9.Debug("bp.Read: skipping member %v: %v", d.Name(), err)
+				continue
+			}
+			for _, dir := range dirs {
+				if seen[dir.Name] {
+					continue
+				}
+				seen[dir.Name] = true
+				bp.buffer = append(bp.buffer, warp9.PackDir(dir)...)
+			}
+		}
+	}
+
+	return ReadBuf(obuf, bp.buffer, off, rcount), nil
+}
+
+// Write is not supported on a union directory.
+func (bp *BindPoint) Write(ibuf []byte, off uint64, count uint32) (uint32, error) {
+	return 0, warp9.ErrorCode(warp9.Eperm)
+}
+
+// Remove is not supported; use Unbind.
+func (bp *BindPoint) Remove() error {
+	return warp9.ErrorCode(warp9.Eperm)
+}
+
+// Stat returns the first member's Dir under the bind point's name.
+func (bp *BindPoint) Stat() (*warp9.Dir, error) {
+	bp.Lock()
+	if len(bp.members) == 0 {
+		bp.Unlock()
+		return &bp.Dir, nil
+	}
+	first := bp.members[0].item
+	bp.Unlock()
+
+	d, err := first.Stat()
+	if err != nil {
+		return nil, err
+	}
+	nd := *d
+	nd.Name = bp.Dir.Name
+	return &nd, nil
+}
+
+// WStat is not supported on a bind point.
+func (bp *BindPoint) WStat(dir *warp9.Dir) error {
+	return warp9.ErrorCode(warp9.Eperm)
+}
+
+// read all the entries of a directory item. Directories that keep their
+// children in memory are listed directly, others are opened and read.
+func readDirectory(d Directory) ([]*warp9.Dir, error) {
+	if children := d.Children(); children != nil {
+		dirs := make([]*warp9.Dir, 0, len(children))
+		for _, item := range children {
+			dirs = append(dirs, item.GetDir())
+		}
+		return dirs, nil
+	}
+
+	item, err := d.Walked()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := item.Open(warp9.OREAD); err != nil {
+		return nil, err
+	}
+	defer item.Clunk()
+
+	var data []byte
+	buf := make([]byte, 8192)
+	for {
+		n, err := item.Read(buf, uint64(len(data)), uint32(len(buf)))
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			break
+		}
+		data = append(data, buf[:n]...)
+	}
+
+	var dirs []*warp9.Dir
+	for len(data) > 0 {
+		d, rest, _, err := warp9.UnpackDir(data)
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, d)
+		data = rest
+	}
+	return dirs, nil
+}
+
+//
+// binding into a server's tree
+//
+
+// Bind binds item onto path in the server's tree with Plan 9 semantics.
+// flag is MREPL, MBEFORE or MAFTER, optionally or'ed with MCREATE. The
+// object at path must exist; the first bind replaces it with a BindPoint
+// holding the original object. The parent of path must accept AddItem
+// (e.g. a DirItem).
+func (srv *ServerController) Bind(item Item, path string, flag uint32) error {
+	bp, err := srv.bindPoint(path, true)
+	if err != nil {
+		return err
+	}
+	return bp.Bind(item, flag)
+}
+
+// Unbind removes item from the union at path. A nil item undoes every bind
+// at path, restoring the original object.
+func (srv *ServerController) Unbind(item Item, path string) error {
+	bp, err := srv.bindPoint(path, false)
+	if err != nil {
+		return err
+	}
+	return bp.Unbind(item)
+}
+
+// find the BindPoint at path; with mk a BindPoint is created over the
+// object currently at path.
+func (srv *ServerController) bindPoint(path string, mk bool) (Binder, error) {
+	names := splitNames(path)
+	if len(names) == 0 {
+		srv.treeMu.Lock()
+		defer srv.treeMu.Unlock()
+		if b, ok := srv.root.(Binder); ok {
+			return b, nil
+		}
+		if !mk {
+			return nil, warp9.ErrorCode(warp9.Einval)
+		}
+		bp := NewBindPoint(srv.root)
+		srv.root = bp
+		return bp, nil
+	}
+
+	parent := srv.GetRoot()
+	if len(names) > 1 {
+		item, err := parent.Walk(names[:len(names)-1])
+		if err != nil {
+			return nil, err
+		}
+		if parent = item.IsDirectory(); parent == nil {
+			return nil, warp9.ErrorCode(warp9.Enotdir)
+		}
+	}
+
+	name := names[len(names)-1]
+	old := parent.Children()[name]
+	if old == nil {
+		return nil, warp9.ErrorCode(warp9.Enotexist)
+	}
+	if b, ok := old.(Binder); ok {
+		return b, nil
+	}
+	if !mk {
+		return nil, warp9.ErrorCode(warp9.Einval)
+	}
+	bp := NewBindPoint(old)
+	parent.AddItem(bp)
+	if parent.Children()[name] != Item(bp) {
+		return nil, warp9.ErrorCode(warp9.Eperm)
+	}
+	return bp, nil
+}
+
+// split a slash separated path into its names
+func splitNames(path string) []string {
+	var names []string
+	for _, n := range strings.Split(path, "/") {
+		if n != "" && n != "." {
+			names = append(names, n)
+		}
+	}
+	return names
 }
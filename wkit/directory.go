@@ -11,4 +11,10 @@ type (
 		Children() map[string]Item
 		RemoveItem(Item) error
 	}
+
+	// A Directory that can create new objects in itself. The returned Item
+	// is the new object, opened according to mode.
+	Creator interface {
+		Create(name string, perm uint32, mode uint8) (Item, error)
+	}
 )
@@ -255,17 +255,7 @@ func (d *DirItem) Read(obuf []byte, off uint64, rcount uint32) (uint32, error) {
 		}
 	}
 
-	// determine which and how many bytes to return
-	var count uint32
-	switch {
-	case off > uint64(len(d.buffer)):
-		count = 0
-	case uint32(len(d.buffer[off:])) > rcount:
-		count = rcount
-	default:
-		count = uint32(len(d.buffer[off:]))
-	}
-	copy(obuf, d.buffer[off:uint32(off)+count])
+	count := ReadBuf(obuf, d.buffer, off, rcount)
 	warp9.Debug("d.Read:buffer:%v, obuf: %v, off:%v, rcount:%v\n", len(d.buffer), len(obuf), off, count)
 
 	return count, nil
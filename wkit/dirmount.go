@@ -5,6 +5,7 @@ package wkit
 
 import (
 	"net"
+	"sync"
 
 	"github.com/lavaorg/warp/warp9"
 )
@@ -23,6 +24,8 @@ type MountPoint struct {
 	parent Directory
 	mi     *MountInfo
 	fid    *warp9.Fid
+	depth  int  // levels below the remote root
+	root   bool // the mount itself; it follows remounts
 }
 
 // MountInfo holder for the remote mount information
@@ -34,129 +37,260 @@ type MountInfo struct {
 	user   warp9.User  //warp9 user id
 	dialer *net.Dialer //dial options
 	conn   net.Conn    //connection, only non-nil if pre-exsited before mount
-	clnt   *warp9.Clnt //warp9 remote mount
 
+	mu   sync.Mutex
+	clnt *warp9.Clnt //warp9 remote mount
+	mon  *monitor    //health monitor, if remounting
 }
 
 // MountPointDial Attempt to establish a mount of a remote object server,
 // upon success return a valid local MountPoint to be placed in the local
 // namespace
 func MountPointDial(ntype, addr, aname string, msize uint32, user warp9.User) (*MountPoint, error) {
-	mi := &MountInfo{aname, ntype, addr, msize, user, &net.Dialer{}, nil, nil}
+	mi := &MountInfo{Aname: aname, ntype: ntype, addr: addr, msize: msize, user: user, dialer: &net.Dialer{}}
 	var err error
-	if msize == 0 {
-		msize = warp9.MSIZE
-	} else if msize < warp9.IOHDRSZ {
-		msize = warp9.IOHDRSZ
-	}
 
-	mi.clnt, err = warp9.Mount(ntype, addr, aname, msize, user)
+	mi.clnt, err = warp9.Mount(ntype, addr, aname, mi.mountSize(), user)
 	if err != nil {
 		return nil, err
 	}
-	mi.msize = mi.clnt.Msize
-	mt := &MountPoint{warp9.Dir{}, nil, mi, mi.clnt.Root}
-	return mt, nil
+	return newMountPoint(mi)
 }
 
 // MountPointDialer Attempt to establish a mount of a remote object serer, using the
 // Dialer attributes passed, upon success return a valid local MountPoint
 // to be placed in the local namespace.
 func MountPointDialer(dialer net.Dialer, ntype, addr, aname string, msize uint32, user warp9.User) (*MountPoint, error) {
-	mi := &MountInfo{aname, ntype, addr, msize, user, &dialer, nil, nil}
+	mi := &MountInfo{Aname: aname, ntype: ntype, addr: addr, msize: msize, user: user, dialer: &dialer}
 	var err error
 
-	c, e := mi.dialer.Dial(ntype, addr)
-	if e != nil {
-		return nil, e
-	}
-
-	if msize == 0 {
-		msize = warp9.MSIZE
-	} else if msize < warp9.IOHDRSZ {
-		msize = warp9.IOHDRSZ
-	}
-
-	mi.clnt, err = warp9.MountConn(c, aname, msize, user)
+	mi.clnt, err = mi.dial()
 	if err != nil {
 		return nil, err
 	}
-	mi.msize = mi.clnt.Msize
-	mt := &MountPoint{warp9.Dir{}, nil, mi, mi.clnt.Root}
-
-	return mt, nil
+	return newMountPoint(mi)
 }
 
 // MountPointConn Use the provided established net.Conn to mount a remote object server,
 // upon success return a valid local MountPoint to be placed in the local
 // namespace
 func MountPointConn(conn net.Conn, aname string, msize uint32, user warp9.User) (*MountPoint, error) {
-	mi := &MountInfo{aname, "", "", msize, user, &net.Dialer{}, conn, nil}
+	mi := &MountInfo{Aname: aname, msize: msize, user: user, dialer: &net.Dialer{}, conn: conn}
 	var err error
 
-	if msize == 0 {
-		msize = warp9.MSIZE
-	} else if msize < warp9.IOHDRSZ {
-		msize = warp9.IOHDRSZ
+	mi.clnt, err = warp9.MountConn(mi.conn, aname, mi.mountSize(), user)
+	if err != nil {
+		return nil, err
 	}
+	return newMountPoint(mi)
+}
 
-	mi.clnt, err = warp9.MountConn(mi.conn, aname, msize, user)
+// the MountPoint for the root of a new mount; its Dir is the remote root's
+func newMountPoint(mi *MountInfo) (*MountPoint, error) {
+	mt := &MountPoint{fid: mi.clnt.Root, mi: mi, root: true}
+	d, err := mi.clnt.FStat(mt.fid)
 	if err != nil {
+		mi.clnt.Unmount()
 		return nil, err
 	}
-	mi.msize = mi.clnt.Msize
-	mt := &MountPoint{warp9.Dir{}, nil, mi, mi.clnt.Root}
-
+	mt.Dir = *d
 	return mt, nil
 }
 
+// the message size to ask for
+func (mi *MountInfo) mountSize() uint32 {
+	switch {
+	case mi.msize == 0:
+		return warp9.MSIZE
+	case mi.msize < warp9.IOHDRSZ:
+		return warp9.IOHDRSZ
+	}
+	return mi.msize
+}
+
+// connect and attach to the remote server with the mount's parameters
+func (mi *MountInfo) dial() (*warp9.Clnt, error) {
+	if mi.addr == "" {
+		return nil, warp9.ErrorMsg(warp9.Ebaduse, "mount has no address to dial")
+	}
+	c, err := mi.dialer.Dial(mi.ntype, mi.addr)
+	if err != nil {
+		return nil, err
+	}
+	return warp9.MountConn(c, mi.Aname, mi.mountSize(), mi.user)
+}
+
+// the current client; nil once unmounted or while the server is down
+func (mi *MountInfo) current() *warp9.Clnt {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	return mi.clnt
+}
+
+// Clnt returns the client connection to the remote object server, or nil
+// once unmounted.
+func (mt *MountPoint) Clnt() *warp9.Clnt {
+	return mt.mi.current()
+}
+
+// Unmount closes the connection to the remote server and stops any
+// remounting.
 func (mt *MountPoint) Unmount() {
-	cli := mt.mi.clnt
-	mt.mi.clnt = nil
+	mi := mt.mi
+	mi.mu.Lock()
+	cli := mi.clnt
+	mi.clnt = nil
+	if mi.mon != nil {
+		close(mi.mon.stop)
+		close(mi.mon.changed)
+		mi.mon = nil
+	}
+	mi.mu.Unlock()
 	if cli != nil {
 		cli.Unmount()
 	}
 }
 
+// the client and remote fid of mt; the mount itself uses the root fid of
+// the current connection
+func (mt *MountPoint) target() (*warp9.Clnt, *warp9.Fid, error) {
+	if mt.root {
+		c := mt.mi.current()
+		if c == nil {
+			return nil, nil, warp9.ErrorCode(warp9.Econn)
+		}
+		return c, c.Root, nil
+	}
+	return mt.fid.Clnt, mt.fid, nil
+}
+
 //
 // implement the Directory interface
 //
 
 func (mt *MountPoint) Name() string {
-	return mt.mi.Aname
+	return mt.Dir.Name
+}
+
+// SetName sets the name the mount has in the local namespace.
+func (mt *MountPoint) SetName(name string) {
+	mt.Dir.Name = name
+}
+
+// a copy of mt for another remote fid
+func (mt *MountPoint) clone(fid *warp9.Fid, depth int) *MountPoint {
+	newmt := *mt
+	newmt.fid = fid
+	newmt.depth = depth
+	newmt.root = false
+	newmt.Qid = fid.Qid
+	return &newmt
+}
+
+// fill mt's Dir from the remote object. If it cannot be read, the Dir is
+// what the qid tells of the object, named name, rather than what mt was
+// cloned from.
+func (mt *MountPoint) stat(clnt *warp9.Clnt, name string) {
+	if d, err := clnt.FStat(mt.fid); err == nil {
+		mt.Dir = *d
+		return
+	}
+	mt.Dir = warp9.Dir{Qid: mt.fid.Qid, Name: name}
+	if mt.Qid.Type&warp9.QTDIR != 0 {
+		mt.Mode = warp9.DMDIR
+	}
+}
+
+// AttachRoot returns the top of the mount on a new fid, with no place in
+// the local namespace: as for a client attached to the remote server
+// directly, ".." at its top stays there.
+func (mt *MountPoint) AttachRoot() (*MountPoint, error) {
+	item, err := mt.Walk(nil)
+	if err != nil {
+		return nil, err
+	}
+	newmt, ok := item.(*MountPoint)
+	if !ok {
+		return nil, warp9.ErrorCode(warp9.Enotdir)
+	}
+	newmt.parent = nil
+	return newmt, nil
 }
 
 // Create a new object in the directory associated with mt. Open the object
-// according to mode and associate the new object with the current fid of mt.
+// according to mode and return it on a new fid; mt is left unchanged.
 func (mt *MountPoint) Create(name string, perm uint32, mode uint8) (Item, error) {
-
-	err := mt.mi.clnt.FCreate(mt.fid, name, perm, mode, "")
+	clnt, fid, err := mt.target()
 	if err != nil {
 		return nil, err
 	}
+	newfid := clnt.FidAlloc()
+	newfid.User = fid.User
+	if _, err := clnt.FWalk(fid, newfid, nil); err != nil {
+		clnt.Clunk(newfid)
+		return nil, err
+	}
+	if err := clnt.FCreate(newfid, name, perm, mode, ""); err != nil {
+		clnt.Clunk(newfid)
+		return nil, err
+	}
 
-	return mt, nil
+	newmt := mt.clone(newfid, mt.depth+1)
+	newmt.stat(clnt, name)
+	return newmt, nil
 }
+
 func (mt *MountPoint) SetMode(mode uint32) {
 	mt.Mode = mode
 }
 
+// Walk walks path on the remote server and returns the result on a new
+// fid. A ".." above the remote root leaves the mount and continues in the
+// local parent directory.
 func (mt *MountPoint) Walk(path []string) (Item, error) {
-	newfid := mt.mi.clnt.FidAlloc()
-	newfid.User = mt.fid.User
-	qid, err := mt.mi.clnt.FWalk(mt.fid, newfid, path)
+	depth := mt.depth
+	wnames := make([]string, 0, len(path))
+	for i, n := range path {
+		if n != ".." {
+			depth++
+		} else if depth > 0 {
+			depth--
+		} else if mt.parent != nil {
+			if len(path[i+1:]) == 0 {
+				return mt.parent.Walked()
+			}
+			return mt.parent.Walk(path[i+1:])
+		} else {
+			// ".." at the top of a detached mount stays there
+			continue
+		}
+		wnames = append(wnames, n)
+	}
+	path = wnames
+
+	clnt, fid, err := mt.target()
 	if err != nil {
 		return nil, err
 	}
-
+	newfid := clnt.FidAlloc()
+	newfid.User = fid.User
+	qid, err := clnt.FWalk(fid, newfid, path)
+	if err != nil {
+		clnt.Clunk(newfid)
+		return nil, err
+	}
 	newfid.Qid = *qid
-	newfid.Iounit = mt.fid.Iounit
+	if len(path) == 0 {
+		// a clone; the reply carries no qid
+		newfid.Qid = fid.Qid
+	}
+	newfid.Iounit = fid.Iounit
 
-	var newmt MountPoint
-	newmt = *mt
-	newmt.Qid = *qid
-	newmt.fid = newfid
-	return &newmt, nil
+	newmt := mt.clone(newfid, depth)
+	if len(path) > 0 {
+		newmt.stat(clnt, path[len(path)-1])
+	}
+	return newmt, nil
 }
 
 //
@@ -171,12 +305,14 @@ func (mt *MountPoint) GetItem() Item {
 }
 
 func (mt *MountPoint) IsDirectory() Directory {
-	if mt.fid != nil && mt.fid.Qid.Type&warp9.QTDIR == 0 {
+	if mt.GetQid().Type&warp9.QTDIR == 0 {
 		return nil
 	}
 	return mt
 }
 
+// Children returns nil; the contents of a mount live on the remote server
+// and are listed by reading the directory.
 func (mt *MountPoint) Children() map[string]Item {
 	return nil
 }
@@ -190,15 +326,23 @@ func (mt *MountPoint) AddDirectory(newDir Directory) {
 func (mt *MountPoint) AddItem(item Item) {
 	return
 }
+
+// RemoveItem removes the object of item's name from the remote directory.
 func (mt *MountPoint) RemoveItem(item Item) error {
-	return nil
+	child, err := mt.Walk([]string{item.GetDir().Name})
+	if err != nil {
+		return err
+	}
+	return child.Remove()
 }
+
 func (mt *MountPoint) Parent() Directory {
 	return mt.parent
 }
 
+// SetParent places the mount in the directory d; a mount has one place.
 func (mt *MountPoint) SetParent(d Directory) error {
-	if mt.parent != nil {
+	if mt.parent != nil && mt.parent != d {
 		return warp9.ErrorCode(warp9.Einval)
 	}
 	mt.parent = d
@@ -206,6 +350,12 @@ func (mt *MountPoint) SetParent(d Directory) error {
 }
 
 func (mt *MountPoint) GetQid() warp9.Qid {
+	if mt.root {
+		if c := mt.mi.current(); c != nil {
+			return c.Root.Qid
+		}
+		return mt.Qid
+	}
 	return mt.fid.Qid
 }
 
@@ -215,7 +365,11 @@ func (mt *MountPoint) Walked() (Item, error) {
 
 func (mt *MountPoint) Read(obuf []byte, off uint64, rcount uint32) (uint32, error) {
 	warp9.Debug("mt.Read:off:%v, rcount:%v", off, rcount)
-	buf, err := mt.mi.clnt.Read(mt.fid, off, rcount)
+	clnt, fid, err := mt.target()
+	if err != nil {
+		return 0, err
+	}
+	buf, err := clnt.Read(fid, off, rcount)
 	if err != nil {
 		return 0, err
 	}
@@ -224,7 +378,11 @@ func (mt *MountPoint) Read(obuf []byte, off uint64, rcount uint32) (uint32, erro
 }
 
 func (mt *MountPoint) Write(ibuf []byte, off uint64, count uint32) (uint32, error) {
-	i, err := mt.mi.clnt.Write(mt.fid, ibuf[:count], off)
+	clnt, fid, err := mt.target()
+	if err != nil {
+		return 0, err
+	}
+	i, err := clnt.Write(fid, ibuf[:count], off)
 	if err != nil {
 		return 0, err
 	}
@@ -233,50 +391,84 @@ func (mt *MountPoint) Write(ibuf []byte, off uint64, count uint32) (uint32, erro
 
 func (mt *MountPoint) Open(mode byte) (uint32, error) {
 	warp9.Debug("mt.Open:")
-	err := mt.mi.clnt.FOpen(mt.fid, mode)
+	clnt, fid, err := mt.target()
 	if err != nil {
 		return 0, err
 	}
-	return mt.fid.Iounit, nil
+	err = clnt.FOpen(fid, mode)
+	if err != nil {
+		return 0, err
+	}
+	return fid.Iounit, nil
 }
 
+// Clunk releases the remote fid. The root fid of the mount is kept until
+// Unmount, and a removed object has no fid left to release.
 func (mt *MountPoint) Clunk() error {
 	warp9.Debug("mt.Clunk:%v, fid#:%v", mt.fid, mt.fid.Fid)
-	err := mt.mi.clnt.Clunk(mt.fid)
+	if mt.root || mt.fid.Fid == warp9.NOFID {
+		return nil
+	}
+	err := mt.fid.Clnt.Clunk(mt.fid)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// Remove removes the remote object. The root of the mount cannot be
+// removed; use Unmount.
 func (mt *MountPoint) Remove() error {
 	warp9.Debug("mt.Remove:%v, name:%s", mt.fid, mt.Name())
-	if err := mt.Parent().RemoveItem(mt); err != nil {
-		return err
+	if mt.root {
+		return warp9.ErrorCode(warp9.Eperm)
 	}
-	return mt.mi.clnt.FRemove(mt.fid)
+	return mt.fid.Clnt.FRemove(mt.fid)
 }
 
+// Stat returns the remote Dir. At the top of the mount the name is the
+// mount's local name. The Dir of a walked MountPoint, a fid's own, is
+// refreshed with it; the mount itself is shared by every session and
+// keeps its Dir.
 func (mt *MountPoint) Stat() (*warp9.Dir, error) {
 	warp9.Info("mt.Stat:fid:%v", mt.fid)
-	d, e := mt.mi.clnt.FStat(mt.fid)
+	clnt, fid, err := mt.target()
+	if err != nil {
+		return nil, err
+	}
+	d, e := clnt.FStat(fid)
 	if e != nil {
 		return nil, e
 	}
-	return d, nil
+	if mt.depth == 0 && mt.Dir.Name != "" {
+		d.Name = mt.Dir.Name
+	}
+	if !mt.root {
+		mt.Dir = *d
+	}
+	nd := *d
+	return &nd, nil
 }
 
 func (mt *MountPoint) WStat(dir *warp9.Dir) error {
-	return mt.mi.clnt.FWstat(mt.fid, dir)
+	clnt, fid, err := mt.target()
+	if err != nil {
+		return err
+	}
+	return clnt.FWstat(fid, dir)
 }
 
 // Debug set the debug level for client actions to target object servers
 // -1=don't change, 0=off, >0=fcall, >1=raw msg bytes
 // return the previous state
 func (mt *MountPoint) Debug(level int) int {
-	past := mt.mi.clnt.Debuglevel
+	clnt := mt.mi.current()
+	if clnt == nil {
+		return 0
+	}
+	past := clnt.Debuglevel
 	if level >= 0 {
-		mt.mi.clnt.Debuglevel = level
+		clnt.Debuglevel = level
 	}
 	return past
 }
@@ -9,6 +9,12 @@ The namespace allows for bind points to be added into the hierarchy that can
 support union object trees.  Each bind point can contain a stackable set of
 object trees with the option of presening a union view of the set.
 
+A single server can also present several independent trees. Besides the
+root given to NewServer, AddTree registers roots selected by the attach
+name (aname) of the client, each with its own TreePolicy. The root may be
+shared by all clients or, with SetNamespaceScope, seen through a private
+Namespace per connection or per user so that binds stay local to it.
+
 A set of specific **Object Typs** are provided to either build more complex
 objects or to provide some __specific__ objects.
 
@@ -37,6 +43,17 @@ sub-components of its tree.  This allows for building an object hierarchy.
 
 **__MountPoint__**: is a concrete directory implementation that allwos for
 mounting remote servers and placing them into the current namespace tree.
+With AutoRemount a MountPoint reconnects by itself when its server fails,
+and its StatusItem reports whether the mount is up.
+
+**__Registry__**: is a directory of the servers that dial in to be reverse
+mounted (see Srv.InitiateConn), one MountPoint per connected server.
+
+**__FSDir__**: is a read-only directory presenting any io/fs file system
+(embed.FS, os.DirFS, zip.Reader) as part of the namespace tree.
+
+**__ExportDir__**: is a read-write directory backed by a directory of the
+host file system, confined to that directory.
 
 Please see documentation generated for each Object type.
 
@@ -0,0 +1,621 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package wkit
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lavaorg/warp/warp9"
+)
+
+type (
+
+	// ExportDir is a Directory backed by a directory of the host file
+	// system, the equivalent of Plan 9's exportfs. It supports walking,
+	// directory reads, create, read, write, remove and wstat (rename within
+	// the same directory, chmod, truncate and mtime).
+	//
+	// Every operation is confined to the exported root: ".." never leaves
+	// the root (except to the Directory the ExportDir was added to) and
+	// symbolic links are resolved and rejected if they point outside of it.
+	//
+	// Qids are derived from the host inode and modification time.
+	ExportDir struct {
+		*BaseItem
+		top    *ExportDir // the directory returned by NewExportDir
+		root   string     // host path of the exported root, symlinks resolved
+		rel    string     // slash separated path below the root; "." at the top
+		buffer []byte     // packed directory contents while open
+	}
+
+	// exportFile is a regular file within an ExportDir tree. Each walk yields
+	// a new exportFile so every fid has its own open os.File.
+	exportFile struct {
+		*BaseItem
+		top    *ExportDir
+		rel    string
+		file   *os.File
+		rclose bool // remove on clunk
+	}
+)
+
+// NewExportDir returns a Directory named name exporting the host directory
+// dir.
+func NewExportDir(name, dir string) (*ExportDir, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	root, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, warp9.ErrorMsg(warp9.Enotdir, root)
+	}
+
+	d := &ExportDir{
+		BaseItem: NewBaseItem(name, true),
+		root:     root,
+		rel:      ".",
+	}
+	d.top = d
+	exportDirFromInfo(&d.Dir, ".", info)
+	d.Dir.Name = name
+	return d, nil
+}
+
+// fill a Dir from the host's FileInfo of rel
+func exportDirFromInfo(d *warp9.Dir, rel string, info fs.FileInfo) {
+	d.Name = info.Name()
+	d.Qid = hostQid(rel, info)
+	d.Mode = warp9.FileModeToPerm(info.Mode())
+	d.Mtime = uint32(info.ModTime().Unix())
+	d.Atime = d.Mtime
+	d.Length = 0
+	if !info.IsDir() {
+		d.Length = uint64(info.Size())
+	}
+}
+
+// convert a host error to a Warp9 error
+func hostError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if werr, ok := err.(*warp9.WarpError); ok {
+		return werr
+	}
+	if errors.Is(err, fs.ErrExist) {
+		return warp9.ErrorCode(warp9.Eexist)
+	}
+	if werr := sysError(err); werr != nil {
+		return werr
+	}
+	return fsError(err)
+}
+
+// a valid single path element
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." &&
+		!strings.ContainsAny(name, `/\`) && filepath.Base(name) == name
+}
+
+// hostPath maps a path below the root to a host path. Symbolic links are
+// resolved and the result must lie within the root.
+func (top *ExportDir) hostPath(rel string) (string, error) {
+	p, err := filepath.EvalSymlinks(filepath.Join(top.root, filepath.FromSlash(rel)))
+	if err != nil {
+		return "", hostError(err)
+	}
+	if !top.contains(p) {
+		return "", warp9.ErrorCode(warp9.Eperm)
+	}
+	return p, nil
+}
+
+// hostNew maps a new name in the directory rel to a host path; the
+// directory is resolved, the name itself must not exist yet.
+func (top *ExportDir) hostNew(rel, name string) (string, error) {
+	if !validName(name) {
+		return "", warp9.ErrorCode(warp9.Ename)
+	}
+	dir, err := top.hostPath(rel)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// hostLink maps rel to a host path without resolving its last element,
+// so operations such as remove and rename act on a link, not its target.
+func (top *ExportDir) hostLink(rel string) (string, error) {
+	return top.hostNew(path.Dir(rel), path.Base(rel))
+}
+
+func (top *ExportDir) contains(p string) bool {
+	return p == top.root || strings.HasPrefix(p, top.root+string(filepath.Separator))
+}
+
+func (top *ExportDir) stat(rel string) (fs.FileInfo, error) {
+	p, err := top.hostPath(rel)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(p)
+	return info, hostError(err)
+}
+
+// build the item for rel
+func (top *ExportDir) lookup(rel string, parent Directory) (Item, error) {
+	info, err := top.stat(rel)
+	if err != nil {
+		return nil, err
+	}
+	return top.newItem(rel, info, parent), nil
+}
+
+func (top *ExportDir) newItem(rel string, info fs.FileInfo, parent Directory) Item {
+	base := NewBaseItem(info.Name(), info.IsDir())
+	exportDirFromInfo(&base.Dir, rel, info)
+	base.parent = parent
+	if info.IsDir() {
+		return &ExportDir{BaseItem: base, top: top, root: top.root, rel: rel}
+	}
+	return &exportFile{BaseItem: base, top: top, rel: rel}
+}
+
+// convert a Warp9 open mode to os.OpenFile flags
+func hostFlags(mode uint8) int {
+	var flags int
+	switch mode & 3 {
+	case warp9.OWRITE:
+		flags = os.O_WRONLY
+	case warp9.ORDWR:
+		flags = os.O_RDWR
+	default:
+		flags = os.O_RDONLY
+	}
+	if mode&warp9.OTRUNC != 0 {
+		flags |= os.O_TRUNC
+	}
+	return flags
+}
+
+//
+// Directory Interface
+//
+
+func (d *ExportDir) Name() string {
+	return d.Dir.Name
+}
+
+// Walk resolves names against the host directory one element at a time.
+// ".." at the top of the tree leaves the export for the parent the
+// ExportDir was added to.
+func (d *ExportDir) Walk(names []string) (Item, error) {
+	if len(names) < 1 {
+		return d.Walked()
+	}
+
+	elem, rest := names[0], names[1:]
+	var item Item
+	switch {
+	case elem == "..":
+		if d.rel == "." {
+			if d.Parent() == nil {
+				return nil, warp9.ErrorCode(warp9.Enotexist)
+			}
+			if len(rest) == 0 {
+				return d.Parent().Walked()
+			}
+			return d.Parent().Walk(rest)
+		}
+		up := path.Dir(d.rel)
+		if up == "." {
+			item = d.top
+			break
+		}
+		parent, err := d.top.lookup(up, nil)
+		if err != nil {
+			return nil, err
+		}
+		item = parent
+	case !validName(elem):
+		return nil, warp9.ErrorCode(warp9.Enotexist)
+	default:
+		var err error
+		item, err = d.top.lookup(path.Join(d.rel, elem), d)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(rest) == 0 {
+		return item.Walked()
+	}
+	dir := item.IsDirectory()
+	if dir == nil {
+		return nil, warp9.ErrorCode(warp9.Enotdir)
+	}
+	return dir.Walk(rest)
+}
+
+// Create makes a new file or, if perm has DMDIR set, a new directory and
+// returns it opened according to mode.
+func (d *ExportDir) Create(name string, perm uint32, mode uint8) (Item, error) {
+	p, err := d.top.hostNew(d.rel, name)
+	if err != nil {
+		return nil, err
+	}
+	rel := path.Join(d.rel, name)
+
+	if perm&warp9.DMDIR != 0 {
+		if err := os.Mkdir(p, os.FileMode(perm&0777)); err != nil {
+			return nil, hostError(err)
+		}
+		item, err := d.top.lookup(rel, d)
+		if err != nil {
+			return nil, err
+		}
+		_, err = item.Open(mode)
+		return item, err
+	}
+
+	file, err := os.OpenFile(p, hostFlags(mode)|os.O_CREATE|os.O_EXCL, os.FileMode(perm&0777))
+	if err != nil {
+		return nil, hostError(err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, hostError(err)
+	}
+	f := d.top.newItem(rel, info, d).(*exportFile)
+	f.file = file
+	f.rclose = mode&warp9.ORCLOSE != 0
+	f.opened = true
+	d.buffer = nil
+	return f, nil
+}
+
+// AddDirectory is not supported; objects are created with Create.
+func (d *ExportDir) AddDirectory(newDir Directory) {
+	warp9.Error("ExportDir %v: cannot add directory %v", d.Dir.Name, newDir.Name())
+}
+
+// AddItem is not supported; objects are created with Create.
+func (d *ExportDir) AddItem(item Item) {
+	warp9.Error("ExportDir %v: cannot add item %v", d.Dir.Name, item.GetDir().Name)
+}
+
+// Children returns the entries of the host directory.
+func (d *ExportDir) Children() map[string]Item {
+	content := make(map[string]Item)
+	for _, item := range d.entries() {
+		content[item.GetDir().Name] = item
+	}
+	return content
+}
+
+// read the host directory; entries that cannot be resolved within the
+// root (dangling or escaping links) are skipped
+func (d *ExportDir) entries() []Item {
+	p, err := d.top.hostPath(d.rel)
+	if err != nil {
+		return nil
+	}
+	ents, err := os.ReadDir(p)
+	if err != nil {
+		warp9.Error("ExportDir readdir %v: %v", d.rel, err)
+		return nil
+	}
+	items := make([]Item, 0, len(ents))
+	for _, ent := range ents {
+		item, err := d.top.lookup(path.Join(d.rel, ent.Name()), d)
+		if err == nil {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// RemoveItem removes the named child from the host directory.
+func (d *ExportDir) RemoveItem(item Item) error {
+	name := item.GetDir().Name
+	if !validName(name) {
+		return warp9.ErrorCode(warp9.Ename)
+	}
+	p, err := d.top.hostNew(d.rel, name)
+	if err != nil {
+		return err
+	}
+	d.buffer = nil
+	return hostError(os.Remove(p))
+}
+
+//
+// Item Interface
+//
+
+// Return the object as the interface type Item.
+func (d *ExportDir) GetItem() Item {
+	return d
+}
+
+// IsDirectory returns itself.
+func (d *ExportDir) IsDirectory() Directory {
+	return d
+}
+
+// Walked returns a copy of the directory so each fid has its own buffer.
+func (d *ExportDir) Walked() (Item, error) {
+	nd := *d
+	base := *d.BaseItem
+	nd.BaseItem = &base
+	nd.buffer = nil
+	return &nd, nil
+}
+
+// Open allows directories to be opened for reading only.
+func (d *ExportDir) Open(mode byte) (uint32, error) {
+	if mode&3 != warp9.OREAD || mode&(warp9.OTRUNC|warp9.ORCLOSE) != 0 {
+		return 0, warp9.ErrorCode(warp9.Eperm)
+	}
+	d.buffer = nil
+	return d.BaseItem.Open(mode)
+}
+
+// Clunk releases the directory buffer.
+func (d *ExportDir) Clunk() error {
+	d.buffer = nil
+	return d.BaseItem.Clunk()
+}
+
+// Read returns the packed Dir entries of the host directory. The entries
+// are read on the first read after the open.
+func (d *ExportDir) Read(obuf []byte, off uint64, rcount uint32) (uint32, error) {
+	if d.buffer == nil {
+		d.buffer = make([]byte, 0, 300)
+		for _, item := range d.entries() {
+			d.buffer = append(d.buffer, warp9.PackDir(item.GetDir())...)
+		}
+	}
+
+	return ReadBuf(obuf, d.buffer, off, rcount), nil
+}
+
+// Write is not supported on a directory.
+func (d *ExportDir) Write(ibuf []byte, off uint64, count uint32) (uint32, error) {
+	return 0, warp9.ErrorCode(warp9.Eperm)
+}
+
+// Remove removes the (empty) host directory. The exported root cannot be
+// removed.
+func (d *ExportDir) Remove() error {
+	if d.rel == "." {
+		return warp9.ErrorCode(warp9.Eperm)
+	}
+	p, err := d.top.hostLink(d.rel)
+	if err != nil {
+		return err
+	}
+	return hostError(os.Remove(p))
+}
+
+// Stat refreshes the Dir from the host.
+func (d *ExportDir) Stat() (*warp9.Dir, error) {
+	info, err := d.top.stat(d.rel)
+	if err != nil {
+		return nil, err
+	}
+	name := d.Dir.Name
+	exportDirFromInfo(&d.Dir, d.rel, info)
+	if d.rel == "." {
+		d.Dir.Name = name
+	}
+	return &d.Dir, nil
+}
+
+// WStat changes the name, permissions or modification time of the
+// directory. The exported root cannot be renamed.
+func (d *ExportDir) WStat(dir *warp9.Dir) error {
+	if dir.Length != ^uint64(0) && dir.Length != 0 {
+		return warp9.ErrorCode(warp9.Eperm)
+	}
+	if dir.Mode != ^uint32(0) && dir.Mode&warp9.DMDIR == 0 {
+		return warp9.ErrorCode(warp9.Edirchange)
+	}
+	rel, err := d.top.wstat(d.rel, dir)
+	if err != nil {
+		return err
+	}
+	d.rel = rel
+	_, err = d.Stat()
+	return err
+}
+
+// apply the fields of a Twstat to rel; the new rel is returned
+func (top *ExportDir) wstat(rel string, dir *warp9.Dir) (string, error) {
+	rename := dir.Name != "" && dir.Name != path.Base(rel)
+	if rename && rel == "." {
+		return rel, warp9.ErrorCode(warp9.Eperm)
+	}
+	p, err := top.hostPath(rel)
+	if err != nil {
+		return rel, err
+	}
+	var op, np string
+	if rename {
+		if op, err = top.hostLink(rel); err != nil {
+			return rel, err
+		}
+		np, err = top.hostNew(path.Dir(rel), dir.Name)
+		if err != nil {
+			return rel, err
+		}
+		if _, err := os.Lstat(np); err == nil {
+			return rel, warp9.ErrorCode(warp9.Eexist)
+		}
+	}
+
+	if dir.Length != ^uint64(0) {
+		info, err := os.Stat(p)
+		if err != nil {
+			return rel, hostError(err)
+		}
+		if !info.IsDir() {
+			if err := os.Truncate(p, int64(dir.Length)); err != nil {
+				return rel, hostError(err)
+			}
+		}
+	}
+	if dir.Mode != ^uint32(0) {
+		if err := os.Chmod(p, os.FileMode(dir.Mode&0777)); err != nil {
+			return rel, hostError(err)
+		}
+	}
+	if dir.Mtime != ^uint32(0) {
+		mtime := time.Unix(int64(dir.Mtime), 0)
+		atime := mtime
+		if dir.Atime != ^uint32(0) {
+			atime = time.Unix(int64(dir.Atime), 0)
+		}
+		if err := os.Chtimes(p, atime, mtime); err != nil {
+			return rel, hostError(err)
+		}
+	}
+	if rename {
+		if err := os.Rename(op, np); err != nil {
+			return rel, hostError(err)
+		}
+		rel = path.Join(path.Dir(rel), dir.Name)
+	}
+	return rel, nil
+}
+
+//
+// exportFile Item Interface
+//
+
+func (f *exportFile) GetItem() Item {
+	return f
+}
+
+// Walked returns a copy of the file so each fid has its own open state.
+func (f *exportFile) Walked() (Item, error) {
+	nf := *f
+	base := *f.BaseItem
+	nf.BaseItem = &base
+	nf.file = nil
+	nf.rclose = false
+	return &nf, nil
+}
+
+// Open opens the host file according to mode.
+func (f *exportFile) Open(mode byte) (uint32, error) {
+	p, err := f.top.hostPath(f.rel)
+	if err != nil {
+		return 0, err
+	}
+	if f.file != nil {
+		f.file.Close()
+	}
+	file, err := os.OpenFile(p, hostFlags(mode), 0)
+	if err != nil {
+		return 0, hostError(err)
+	}
+	f.file = file
+	f.rclose = mode&warp9.ORCLOSE != 0
+	return f.BaseItem.Open(mode)
+}
+
+// Clunk closes the host file, removing it if it was opened with ORCLOSE.
+func (f *exportFile) Clunk() error {
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+		if f.rclose {
+			f.rclose = false
+			f.Remove()
+		}
+	}
+	return f.BaseItem.Clunk()
+}
+
+// Read reads up to rcount bytes at off.
+func (f *exportFile) Read(obuf []byte, off uint64, rcount uint32) (uint32, error) {
+	if f.file == nil {
+		return 0, warp9.ErrorCode(warp9.Enotopen)
+	}
+	if uint32(len(obuf)) < rcount {
+		rcount = uint32(len(obuf))
+	}
+	n, err := f.file.ReadAt(obuf[:rcount], int64(off))
+	if err != nil && err != io.EOF {
+		return 0, hostError(err)
+	}
+	return uint32(n), nil
+}
+
+// Write writes count bytes at off.
+func (f *exportFile) Write(ibuf []byte, off uint64, count uint32) (uint32, error) {
+	if f.file == nil {
+		return 0, warp9.ErrorCode(warp9.Enotopen)
+	}
+	n, err := f.file.WriteAt(ibuf[:count], int64(off))
+	if err != nil {
+		return uint32(n), hostError(err)
+	}
+	return uint32(n), nil
+}
+
+// Remove removes the host file.
+func (f *exportFile) Remove() error {
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+	p, err := f.top.hostLink(f.rel)
+	if err != nil {
+		return err
+	}
+	return hostError(os.Remove(p))
+}
+
+// Stat refreshes the Dir from the host.
+func (f *exportFile) Stat() (*warp9.Dir, error) {
+	info, err := f.top.stat(f.rel)
+	if err != nil {
+		return nil, err
+	}
+	exportDirFromInfo(&f.Dir, f.rel, info)
+	return &f.Dir, nil
+}
+
+// WStat changes the name, permissions, length or modification time of
+// the file.
+func (f *exportFile) WStat(dir *warp9.Dir) error {
+	if dir.Mode != ^uint32(0) && dir.Mode&warp9.DMDIR != 0 {
+		return warp9.ErrorCode(warp9.Edirchange)
+	}
+	rel, err := f.top.wstat(f.rel, dir)
+	if err != nil {
+		return err
+	}
+	f.rel = rel
+	_, err = f.Stat()
+	return err
+}
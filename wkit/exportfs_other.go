@@ -0,0 +1,35 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package wkit
+
+import (
+	"hash/fnv"
+	"io"
+	"io/fs"
+
+	"github.com/lavaorg/warp/warp9"
+)
+
+// hostQid derives a Qid from the path below the export root and the
+// modification time; this host does not report inode numbers.
+func hostQid(rel string, info fs.FileInfo) warp9.Qid {
+	qid := warp9.Qid{Type: warp9.QTOBJ}
+	if info.IsDir() {
+		qid.Type = warp9.QTDIR
+	}
+	h := fnv.New64a()
+	io.WriteString(h, rel)
+	qid.Path = h.Sum64()
+	ns := info.ModTime().UnixNano()
+	qid.Version = uint32(ns) ^ uint32(ns>>32)
+	return qid
+}
+
+// no errno values to map on this host
+func sysError(err error) error {
+	return nil
+}
@@ -0,0 +1,42 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package wkit
+
+import (
+	"errors"
+	"io/fs"
+	"syscall"
+
+	"github.com/lavaorg/warp/warp9"
+)
+
+// hostQid derives a Qid from the inode and the modification time.
+func hostQid(rel string, info fs.FileInfo) warp9.Qid {
+	qid := warp9.Qid{Type: warp9.QTOBJ}
+	if info.IsDir() {
+		qid.Type = warp9.QTDIR
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		qid.Path = uint64(st.Ino)
+	}
+	ns := info.ModTime().UnixNano()
+	qid.Version = uint32(ns) ^ uint32(ns>>32)
+	return qid
+}
+
+// map the errno values without an io/fs equivalent
+func sysError(err error) error {
+	switch {
+	case errors.Is(err, syscall.ENOTEMPTY):
+		return warp9.ErrorCode(warp9.Enotempty)
+	case errors.Is(err, syscall.ENOTDIR):
+		return warp9.ErrorCode(warp9.Enotdir)
+	case errors.Is(err, syscall.EISDIR), errors.Is(err, syscall.EROFS):
+		return warp9.ErrorCode(warp9.Eperm)
+	}
+	return nil
+}
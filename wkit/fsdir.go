@@ -0,0 +1,440 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package wkit
+
+import (
+	"errors"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"path"
+
+	"github.com/lavaorg/warp/warp9"
+)
+
+type (
+
+	// FSDir is a read-only Directory presenting the contents of an io/fs
+	// file system (embed.FS, os.DirFS, zip.Reader, ...). Nothing is read
+	// up front: each walk stats the named entry, reads stream from the
+	// underlying fs.File and directory reads are produced from fs.ReadDir.
+	//
+	// Qids are derived from the entry's path within the file system and its
+	// modification time and size, so they are stable across server restarts.
+	FSDir struct {
+		*BaseItem
+		fsys   fs.FS
+		name   string // fs name of this directory; "." at the top
+		top    *FSDir // the directory returned by NewFSDir
+		buffer []byte // packed directory contents while open
+	}
+
+	// fsFile is a regular file within an FSDir tree. Each walk yields a new
+	// fsFile so every fid has its own open fs.File and offset.
+	fsFile struct {
+		*BaseItem
+		fsys   fs.FS
+		name   string
+		file   fs.File
+		offset int64
+	}
+)
+
+// NewFSDir returns a Directory named name serving the contents of fsys.
+func NewFSDir(name string, fsys fs.FS) Directory {
+	d := &FSDir{
+		BaseItem: NewBaseItem(name, true),
+		fsys:     fsys,
+		name:     ".",
+	}
+	d.top = d
+	if info, err := fs.Stat(fsys, "."); err == nil {
+		fsDirFromInfo(&d.Dir, ".", info)
+	} else {
+		d.Qid = fsQid(".", nil)
+		d.Mode = warp9.DMDIR | uint32(Perms(warp9.DMREAD|warp9.DMUSE, warp9.DMREAD|warp9.DMUSE, warp9.DMREAD|warp9.DMUSE))
+	}
+	d.Dir.Name = name
+	return d
+}
+
+// fill a Dir from an fs.FileInfo; write permission is never granted
+func fsDirFromInfo(d *warp9.Dir, name string, info fs.FileInfo) {
+	d.Name = info.Name()
+	d.Qid = fsQid(name, info)
+	d.Mode = warp9.FileModeToPerm(info.Mode()) &^ (warp9.DMAPPEND | uint32(Perms(warp9.DMWRITE, warp9.DMWRITE, warp9.DMWRITE)))
+	d.Mtime = uint32(info.ModTime().Unix())
+	d.Atime = d.Mtime
+	d.Length = 0
+	if !info.IsDir() {
+		d.Length = uint64(info.Size())
+	}
+}
+
+// fsQid derives a Qid from the fs name and, when known, the entry's
+// modification time and size. The same file system yields the same Qids
+// every time it is served.
+func fsQid(name string, info fs.FileInfo) warp9.Qid {
+	h := fnv.New64a()
+	io.WriteString(h, name)
+	qid := warp9.Qid{Type: warp9.QTOBJ, Path: h.Sum64()}
+	if info == nil {
+		qid.Type = warp9.QTDIR
+		return qid
+	}
+	if info.IsDir() {
+		qid.Type = warp9.QTDIR
+	}
+	if !info.ModTime().IsZero() {
+		v := fnv.New32a()
+		var b [16]byte
+		t, sz := uint64(info.ModTime().UnixNano()), uint64(info.Size())
+		for i := 0; i < 8; i++ {
+			b[i], b[8+i] = byte(t>>(8*i)), byte(sz>>(8*i))
+		}
+		v.Write(b[:])
+		qid.Version = v.Sum32()
+	}
+	return qid
+}
+
+// convert an io/fs error to a Warp9 error
+func fsError(err error) error {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return warp9.ErrorCode(warp9.Enotexist)
+	case errors.Is(err, fs.ErrPermission):
+		return warp9.ErrorCode(warp9.Eperm)
+	case errors.Is(err, fs.ErrInvalid):
+		return warp9.ErrorCode(warp9.Einval)
+	}
+	return warp9.ErrorCode(warp9.Eio)
+}
+
+// build the item for the fs entry name in d's file system
+func (d *FSDir) lookup(name string, parent Directory) (Item, error) {
+	info, err := fs.Stat(d.fsys, name)
+	if err != nil {
+		return nil, fsError(err)
+	}
+	base := NewBaseItem(info.Name(), info.IsDir())
+	fsDirFromInfo(&base.Dir, name, info)
+	if info.IsDir() {
+		nd := &FSDir{BaseItem: base, fsys: d.fsys, name: name, top: d.top}
+		nd.parent = parent
+		return nd, nil
+	}
+	f := &fsFile{BaseItem: base, fsys: d.fsys, name: name}
+	f.parent = parent
+	return f, nil
+}
+
+//
+// Directory Interface
+//
+
+func (d *FSDir) Name() string {
+	return d.Dir.Name
+}
+
+// Walk resolves path against the file system one element at a time.
+// ".." at the top of the tree leaves the file system for the parent the
+// FSDir was added to.
+func (d *FSDir) Walk(names []string) (Item, error) {
+	if len(names) < 1 {
+		return d.Walked()
+	}
+
+	elem, rest := names[0], names[1:]
+	var item Item
+	switch {
+	case elem == "..":
+		if d.name == "." {
+			if d.Parent() == nil {
+				return nil, warp9.ErrorCode(warp9.Enotexist)
+			}
+			if len(rest) == 0 {
+				return d.Parent().Walked()
+			}
+			return d.Parent().Walk(rest)
+		}
+		up := path.Dir(d.name)
+		if up == "." {
+			item = d.top
+			break
+		}
+		parent, err := d.lookup(up, nil)
+		if err != nil {
+			return nil, err
+		}
+		item = parent
+	case elem == "." || elem == "" || !fs.ValidPath(elem) || path.Base(elem) != elem:
+		return nil, warp9.ErrorCode(warp9.Enotexist)
+	default:
+		var err error
+		item, err = d.lookup(path.Join(d.name, elem), d)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(rest) == 0 {
+		return item.Walked()
+	}
+	dir := item.IsDirectory()
+	if dir == nil {
+		return nil, warp9.ErrorCode(warp9.Enotdir)
+	}
+	return dir.Walk(rest)
+}
+
+// AddDirectory is not supported; the tree is read-only.
+func (d *FSDir) AddDirectory(newDir Directory) {
+	warp9.Error("FSDir %v is read-only; directory not added", d.Dir.Name)
+}
+
+// AddItem is not supported; the tree is read-only.
+func (d *FSDir) AddItem(item Item) {
+	warp9.Error("FSDir %v is read-only; item not added", d.Dir.Name)
+}
+
+// Children returns the entries of the directory as read from the file system.
+func (d *FSDir) Children() map[string]Item {
+	content := make(map[string]Item)
+	ents, err := fs.ReadDir(d.fsys, d.name)
+	if err != nil {
+		warp9.Error("FSDir readdir %v: %v", d.name, err)
+		return content
+	}
+	for _, ent := range ents {
+		item, err := d.lookup(path.Join(d.name, ent.Name()), d)
+		if err == nil {
+			content[ent.Name()] = item
+		}
+	}
+	return content
+}
+
+// RemoveItem is not supported; returns Eperm.
+func (d *FSDir) RemoveItem(item Item) error {
+	return warp9.ErrorCode(warp9.Eperm)
+}
+
+//
+// Item Interface
+//
+
+// Return the object as the interface type Item.
+func (d *FSDir) GetItem() Item {
+	return d
+}
+
+// IsDirectory returns itself.
+func (d *FSDir) IsDirectory() Directory {
+	return d
+}
+
+// Walked returns a copy of the directory so each fid has its own buffer.
+func (d *FSDir) Walked() (Item, error) {
+	nd := *d
+	base := *d.BaseItem
+	nd.BaseItem = &base
+	nd.buffer = nil
+	return &nd, nil
+}
+
+// Open only allows reading.
+func (d *FSDir) Open(mode byte) (uint32, error) {
+	if mode&3 != warp9.OREAD || mode&(warp9.OTRUNC|warp9.ORCLOSE) != 0 {
+		return 0, warp9.ErrorCode(warp9.Eperm)
+	}
+	d.buffer = nil
+	return d.BaseItem.Open(mode)
+}
+
+// Clunk releases the directory buffer.
+func (d *FSDir) Clunk() error {
+	d.buffer = nil
+	return d.BaseItem.Clunk()
+}
+
+// Read returns the packed Dir entries of the directory. The entries are
+// read from the file system on the first read after the open.
+func (d *FSDir) Read(obuf []byte, off uint64, rcount uint32) (uint32, error) {
+	if d.buffer == nil {
+		ents, err := fs.ReadDir(d.fsys, d.name)
+		if err != nil {
+			return 0, fsError(err)
+		}
+		d.buffer = make([]byte, 0, 300)
+		for _, ent := range ents {
+			info, err := ent.Info()
+			if err != nil {
+				continue
+			}
+			var dir warp9.Dir
+			fsDirFromInfo(&dir, path.Join(d.name, ent.Name()), info)
+			d.buffer = append(d.buffer, warp9.PackDir(&dir)...)
+		}
+	}
+
+	return ReadBuf(obuf, d.buffer, off, rcount), nil
+}
+
+// Write is not supported; returns Eperm.
+func (d *FSDir) Write(ibuf []byte, off uint64, count uint32) (uint32, error) {
+	return 0, warp9.ErrorCode(warp9.Eperm)
+}
+
+// Remove is not supported; returns Eperm.
+func (d *FSDir) Remove() error {
+	return warp9.ErrorCode(warp9.Eperm)
+}
+
+// Stat refreshes the Dir from the file system.
+func (d *FSDir) Stat() (*warp9.Dir, error) {
+	info, err := fs.Stat(d.fsys, d.name)
+	if err != nil {
+		return nil, fsError(err)
+	}
+	name := d.Dir.Name
+	fsDirFromInfo(&d.Dir, d.name, info)
+	d.Dir.Name = name
+	return &d.Dir, nil
+}
+
+// WStat is not supported; returns Eperm.
+func (d *FSDir) WStat(dir *warp9.Dir) error {
+	return warp9.ErrorCode(warp9.Eperm)
+}
+
+//
+// fsFile Item Interface
+//
+
+func (f *fsFile) GetItem() Item {
+	return f
+}
+
+// Walked returns a copy of the file so each fid has its own open state.
+func (f *fsFile) Walked() (Item, error) {
+	nf := *f
+	base := *f.BaseItem
+	nf.BaseItem = &base
+	nf.file = nil
+	nf.offset = 0
+	return &nf, nil
+}
+
+// Open opens the underlying fs.File; only reading is allowed.
+func (f *fsFile) Open(mode byte) (uint32, error) {
+	if mode&3 != warp9.OREAD || mode&(warp9.OTRUNC|warp9.ORCLOSE) != 0 {
+		return 0, warp9.ErrorCode(warp9.Eperm)
+	}
+	if f.file != nil {
+		f.file.Close()
+	}
+	file, err := f.fsys.Open(f.name)
+	if err != nil {
+		return 0, fsError(err)
+	}
+	f.file = file
+	f.offset = 0
+	return f.BaseItem.Open(mode)
+}
+
+// Clunk closes the underlying fs.File.
+func (f *fsFile) Clunk() error {
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+	return f.BaseItem.Clunk()
+}
+
+// Read reads up to rcount bytes at off. Files implementing io.ReaderAt or
+// io.Seeker are read in place; otherwise the file is read sequentially,
+// reopening it when a client reads backwards.
+func (f *fsFile) Read(obuf []byte, off uint64, rcount uint32) (uint32, error) {
+	if f.file == nil {
+		return 0, warp9.ErrorCode(warp9.Enotopen)
+	}
+	if uint32(len(obuf)) < rcount {
+		rcount = uint32(len(obuf))
+	}
+	buf := obuf[:rcount]
+	ioff := int64(off)
+
+	if ra, ok := f.file.(io.ReaderAt); ok {
+		n, err := ra.ReadAt(buf, ioff)
+		if err != nil && err != io.EOF {
+			return 0, fsError(err)
+		}
+		return uint32(n), nil
+	}
+
+	if ioff != f.offset {
+		if err := f.seek(ioff); err != nil {
+			return 0, err
+		}
+	}
+	n, err := io.ReadFull(f.file, buf)
+	f.offset += int64(n)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return 0, fsError(err)
+	}
+	return uint32(n), nil
+}
+
+// position the sequential reader at off
+func (f *fsFile) seek(off int64) error {
+	if s, ok := f.file.(io.Seeker); ok {
+		if _, err := s.Seek(off, io.SeekStart); err != nil {
+			return fsError(err)
+		}
+		f.offset = off
+		return nil
+	}
+	if off < f.offset {
+		f.file.Close()
+		file, err := f.fsys.Open(f.name)
+		if err != nil {
+			f.file = nil
+			return fsError(err)
+		}
+		f.file = file
+		f.offset = 0
+	}
+	n, err := io.CopyN(io.Discard, f.file, off-f.offset)
+	f.offset += n
+	if err != nil && err != io.EOF {
+		return fsError(err)
+	}
+	return nil
+}
+
+// Write is not supported; returns Eperm.
+func (f *fsFile) Write(ibuf []byte, off uint64, count uint32) (uint32, error) {
+	return 0, warp9.ErrorCode(warp9.Eperm)
+}
+
+// Remove is not supported; returns Eperm.
+func (f *fsFile) Remove() error {
+	return warp9.ErrorCode(warp9.Eperm)
+}
+
+// Stat refreshes the Dir from the file system.
+func (f *fsFile) Stat() (*warp9.Dir, error) {
+	info, err := fs.Stat(f.fsys, f.name)
+	if err != nil {
+		return nil, fsError(err)
+	}
+	fsDirFromInfo(&f.Dir, f.name, info)
+	return &f.Dir, nil
+}
+
+// WStat is not supported; returns Eperm.
+func (f *fsFile) WStat(dir *warp9.Dir) error {
+	return warp9.ErrorCode(warp9.Eperm)
+}
@@ -18,6 +18,20 @@ func NextQid() uint64 {
 	return atomic.AddUint64(&qidpGlob, 1)
 }
 
+// ReadBuf copies to obuf at most rcount bytes of buf from offset off and
+// returns the number copied: none at or past the end of buf. It serves
+// the Read of an object whose contents are held in a buffer.
+func ReadBuf(obuf, buf []byte, off uint64, rcount uint32) uint32 {
+	if off >= uint64(len(buf)) {
+		return 0
+	}
+	data := buf[off:]
+	if uint64(len(data)) > uint64(rcount) {
+		data = data[:rcount]
+	}
+	return uint32(copy(obuf, data))
+}
+
 //
 // private helper functions
 //
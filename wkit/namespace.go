@@ -0,0 +1,272 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package wkit
+
+import (
+	"path"
+	"sync"
+
+	"github.com/lavaorg/warp/warp9"
+)
+
+// NamespaceScope selects who shares the binds made on a server's tree.
+type NamespaceScope int
+
+const (
+	NsShared  NamespaceScope = iota // every client sees one tree (default)
+	NsPerConn                       // each connection has a private namespace
+	NsPerUser                       // each user has a private namespace
+)
+
+// A Namespace is a private view of a shared tree. Binds and mounts made
+// through a Namespace are kept in the namespace and leave the tree itself
+// untouched, so only the clients attached through it see them. The objects
+// of the tree remain shared; writing to an object is seen by everyone.
+//
+// Paths are resolved lexically, as in Plan 9: "a/../b" is "b".
+type Namespace struct {
+	sync.Mutex
+	root  Directory
+	binds map[string]*BindPoint // by clean absolute path
+}
+
+// NewNamespace returns an empty private view of root.
+func NewNamespace(root Directory) *Namespace {
+	return &Namespace{
+		root:  root,
+		binds: make(map[string]*BindPoint),
+	}
+}
+
+// Root returns the root of the namespace.
+func (ns *Namespace) Root() (Directory, error) {
+	item, err := ns.Walk("/")
+	if err != nil {
+		return nil, err
+	}
+	d := item.IsDirectory()
+	if d == nil {
+		return nil, warp9.ErrorCode(warp9.Enotdir)
+	}
+	return d, nil
+}
+
+// Walk returns the object at the absolute path p as seen in the namespace.
+func (ns *Namespace) Walk(p string) (Item, error) {
+	p = path.Clean("/" + p)
+	item, err := ns.resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	if d := item.IsDirectory(); d != nil {
+		nd := &nsDir{Directory: d, ns: ns, path: p}
+		if c, ok := d.(Creator); ok {
+			return &nsCreator{nsDir: nd, c: c}, nil
+		}
+		return nd, nil
+	}
+	return item, nil
+}
+
+// Bind binds item onto the path p of the namespace with Plan 9 semantics;
+// see ServerController.Bind. The object at p must exist.
+func (ns *Namespace) Bind(item Item, p string, flag uint32) error {
+	p = path.Clean("/" + p)
+	var orig Item
+	for {
+		ns.Lock()
+		if bp := ns.binds[p]; bp != nil || orig != nil {
+			if bp == nil {
+				bp = NewBindPoint(orig)
+			}
+			err := bp.Bind(item, flag)
+			if err == nil {
+				ns.binds[p] = bp
+			}
+			ns.Unlock()
+			return err
+		}
+		ns.Unlock()
+
+		// the object is walked without the lock; it may be remote
+		var err error
+		if orig, err = ns.resolve(p); err != nil {
+			return err
+		}
+	}
+}
+
+// Unbind removes item from the union at p. A nil item undoes every bind at
+// p, restoring the object of the shared tree.
+func (ns *Namespace) Unbind(item Item, p string) error {
+	ns.Lock()
+	defer ns.Unlock()
+	p = path.Clean("/" + p)
+	bp := ns.binds[p]
+	if bp == nil {
+		return warp9.ErrorMsg(warp9.Enotexist, p)
+	}
+	if err := bp.Unbind(item); err != nil {
+		return err
+	}
+	if m := bp.Members(); len(m) == 1 && m[0] == bp.orig {
+		delete(ns.binds, p)
+	}
+	return nil
+}
+
+// the object at the clean path p: the deepest bind point along p, walked
+// for the rest of the path. Only the bind point is found under the lock;
+// the walk from it may reach remote mounts and is done without it.
+func (ns *Namespace) resolve(p string) (Item, error) {
+	base, rest := ns.base(p)
+	if len(rest) == 0 {
+		return base.Walked()
+	}
+	d := base.IsDirectory()
+	if d == nil {
+		return nil, warp9.ErrorCode(warp9.Enotdir)
+	}
+	return d.Walk(rest)
+}
+
+// the deepest bind point along the clean path p, or the root, and the
+// names of p below it
+func (ns *Namespace) base(p string) (Item, []string) {
+	ns.Lock()
+	defer ns.Unlock()
+	rest := splitNames(p)
+	for i := len(rest); i >= 0; i-- {
+		prefix := "/" + path.Join(rest[:i]...)
+		if bp, ok := ns.binds[prefix]; ok {
+			return bp, rest[i:]
+		}
+	}
+	return ns.root, rest
+}
+
+// a directory of a Namespace; walks from it are resolved in the namespace
+type nsDir struct {
+	Directory
+	ns   *Namespace
+	path string
+}
+
+func (d *nsDir) Walk(names []string) (Item, error) {
+	return d.ns.Walk(path.Join(append([]string{d.path}, names...)...))
+}
+
+func (d *nsDir) Walked() (Item, error) {
+	return d.ns.Walk(d.path)
+}
+
+func (d *nsDir) IsDirectory() Directory {
+	return d
+}
+
+func (d *nsDir) GetItem() Item {
+	return d
+}
+
+// an nsDir over a directory that can create objects. A directory that
+// cannot is left without Create, so the server's own Create applies.
+type nsCreator struct {
+	*nsDir
+	c Creator
+}
+
+func (d *nsCreator) IsDirectory() Directory {
+	return d
+}
+
+func (d *nsCreator) GetItem() Item {
+	return d
+}
+
+func (d *nsCreator) Create(name string, perm uint32, mode uint8) (Item, error) {
+	return d.c.Create(name, perm, mode)
+}
+
+//
+// private namespaces of a ServerController
+//
+
+// SetNamespaceScope selects whether clients attaching to the server's root
+// share its tree (NsShared) or each get a private Namespace over it, per
+// connection (NsPerConn) or per user (NsPerUser). Named trees added with
+// AddTree are always shared.
+//
+// A server embedding the ServerController can lay out each new connection's
+// namespace in its ConnOpened, using ConnNamespace. A server overriding
+// ConnClosed should call the ServerController's ConnClosed as well.
+func (srv *ServerController) SetNamespaceScope(scope NamespaceScope) {
+	srv.nsMu.Lock()
+	srv.scope = scope
+	srv.nsMu.Unlock()
+}
+
+// ConnNamespace returns the private namespace of conn, creating it on first
+// use. It returns nil unless the scope is NsPerConn.
+func (srv *ServerController) ConnNamespace(conn *warp9.Conn) *Namespace {
+	root := srv.GetRoot()
+	srv.nsMu.Lock()
+	defer srv.nsMu.Unlock()
+	if srv.scope != NsPerConn || root == nil {
+		return nil
+	}
+	ns := srv.connNs[conn]
+	if ns == nil {
+		if srv.connNs == nil {
+			srv.connNs = make(map[*warp9.Conn]*Namespace)
+		}
+		ns = NewNamespace(root)
+		srv.connNs[conn] = ns
+	}
+	return ns
+}
+
+// UserNamespace returns the private namespace of user, creating it on first
+// use. It returns nil unless the scope is NsPerUser.
+func (srv *ServerController) UserNamespace(user warp9.User) *Namespace {
+	root := srv.GetRoot()
+	srv.nsMu.Lock()
+	defer srv.nsMu.Unlock()
+	if srv.scope != NsPerUser || root == nil || user == nil {
+		return nil
+	}
+	ns := srv.userNs[user.Id()]
+	if ns == nil {
+		if srv.userNs == nil {
+			srv.userNs = make(map[uint32]*Namespace)
+		}
+		ns = NewNamespace(root)
+		srv.userNs[user.Id()] = ns
+	}
+	return ns
+}
+
+// the namespace a client attaching to the server's root is given; nil when
+// the tree is shared
+func (srv *ServerController) namespace(req *warp9.SrvReq) *Namespace {
+	srv.nsMu.Lock()
+	scope := srv.scope
+	srv.nsMu.Unlock()
+	switch scope {
+	case NsPerConn:
+		return srv.ConnNamespace(req.Conn)
+	case NsPerUser:
+		return srv.UserNamespace(req.Fid.User)
+	}
+	return nil
+}
+
+// ConnOpened is part of warp9.ConnOps; namespaces are created on first use.
+func (srv *ServerController) ConnOpened(conn *warp9.Conn) {}
+
+// ConnClosed discards the private namespace of conn.
+func (srv *ServerController) ConnClosed(conn *warp9.Conn) {
+	srv.nsMu.Lock()
+	delete(srv.connNs, conn)
+	srv.nsMu.Unlock()
+}
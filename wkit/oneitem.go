@@ -36,6 +36,7 @@ func (o *OneItem) Buffer() []byte {
 //
 func (o *OneItem) SetBuffer(buf []byte) Item {
 	o.buffer = buf
+	o.Length = uint64(len(buf))
 	return o
 }
 
@@ -51,20 +52,7 @@ func (o *OneItem) Walked() (Item, error) {
 
 // Return the requested set of bytes from the object's byte buffer.
 func (o *OneItem) Read(obuf []byte, off uint64, rcount uint32) (uint32, error) {
-	// determine which and how many bytes to return
-	var count uint32
-	switch {
-	case off > uint64(len(o.buffer)):
-		count = 0
-	case uint32(len(o.buffer[off:])) > rcount:
-		count = rcount
-	default:
-		count = uint32(len(o.buffer[off:]))
-	}
-	n := copy(obuf, o.buffer[off:uint32(off)+count])
-	if uint32(n) != count {
-		return 0, warp9.ErrorCode(warp9.Eio)
-	}
+	count := ReadBuf(obuf, o.buffer, off, rcount)
 	warp9.Debug("o.Read:buffer:%v, obuf: %v, off:%v, rcount:%v\n", len(o.buffer), len(obuf), off, count)
 	warp9.Debug("o.Read:%T %p %v", o, o, o.Qid)
 	return count, nil
@@ -74,6 +62,7 @@ func (o *OneItem) Read(obuf []byte, off uint64, rcount uint32) (uint32, error) {
 // Write bytes into the object's byte buffer.
 // The object's byte buffer is smaller than len(max(int))
 func (o *OneItem) Write(ibuf []byte, off uint64, count uint32) (uint32, error) {
+	defer func() { o.Length = uint64(len(o.buffer)) }()
 
 	// our file will not be super large;  convert everything to int
 	ioff := int(off)
@@ -0,0 +1,287 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package wkit
+
+import (
+	"net"
+	"sort"
+	"sync"
+
+	"github.com/lavaorg/warp/warp9"
+)
+
+// A Registry collects the object servers that dial in to us, for example
+// with Srv.InitiateConn from behind a NAT, and presents them as a directory
+// holding one MountPoint per connected server. A server is added when its
+// reverse mount succeeds and removed when its connection goes away.
+//
+// A server is known by a name. It cannot be the server's aname: in a
+// reverse mount the registry is the client, so it chooses the attach name
+// (Aname, the same for every server), and nothing in the protocol lets a
+// calling server send one of its own. The name of the root the server
+// serves under Aname stands in for it: a server names itself with the name
+// it gives its root (e.g. to NewDirItem), and a Namer may name servers
+// otherwise. A server arriving under the name of one still connected is
+// refused, so a server cannot take over the name of another; the name is
+// free again once that server's connection is gone.
+type Registry struct {
+	Aname string     // attach name used to mount each server
+	Msize uint32     // message size to negotiate; 0 for the default
+	User  warp9.User // user attaching to the servers
+
+	// Namer names a newly mounted server; it defaults to RootName.
+	Namer func(c9 *warp9.Clnt) (string, error)
+
+	mu      sync.Mutex
+	servers map[string]*MountPoint
+	dir     *regDir
+}
+
+// NewRegistry returns an empty registry presented as the directory name.
+func NewRegistry(name, aname string, msize uint32, user warp9.User) *Registry {
+	r := &Registry{
+		Aname:   aname,
+		Msize:   msize,
+		User:    user,
+		Namer:   RootName,
+		servers: make(map[string]*MountPoint),
+	}
+	r.dir = &regDir{BaseItem: NewBaseItem(name, true), reg: r}
+	return r
+}
+
+// RootName names a server by the name of the root it serves under the
+// registry's Aname; it is the server's chosen name, in place of an aname.
+func RootName(c9 *warp9.Clnt) (string, error) {
+	d, err := c9.FStat(c9.Root)
+	if err != nil {
+		return "", err
+	}
+	if !validName(d.Name) {
+		return "", warp9.ErrorMsg(warp9.Ename, d.Name)
+	}
+	return d.Name, nil
+}
+
+// Dir returns the directory of the registered servers.
+func (r *Registry) Dir() Directory {
+	return r.dir
+}
+
+// Serve accepts servers calling in on l until l is closed.
+func (r *Registry) Serve(l net.Listener) error {
+	return warp9.ReverseMountServe(l, r.Aname, r.mountSize(), r.User, r.Register, r.mountError)
+}
+
+// Listen accepts servers calling in on the network address in the
+// background; closing the result stops it.
+func (r *Registry) Listen(ntype, addr string) (warp9.RMountCloser, error) {
+	return warp9.ReverseMountListener(ntype, addr, r.Aname, r.mountSize(), r.User, r.Register, r.mountError)
+}
+
+func (r *Registry) mountSize() uint32 {
+	mi := MountInfo{msize: r.Msize}
+	return mi.mountSize()
+}
+
+func (r *Registry) mountError(kind int, err error) {
+	if kind == 1 {
+		warp9.Error("registry: reverse mount failed: %v", err)
+	}
+}
+
+// Register adds a mounted server to the registry and blocks until its
+// connection is gone, then removes it. It is the RMountConn callback used
+// by Serve and Listen.
+func (r *Registry) Register(c9 *warp9.Clnt) {
+	name, err := r.Namer(c9)
+	if err != nil {
+		warp9.Error("registry: cannot name server: %v", err)
+		c9.Unmount()
+		return
+	}
+	mi := &MountInfo{Aname: r.Aname, msize: r.Msize, user: r.User, dialer: &net.Dialer{}, clnt: c9}
+	mt, err := newMountPoint(mi)
+	if err != nil {
+		warp9.Error("registry: %s: %v", name, err)
+		return
+	}
+	mt.SetName(name)
+	mt.SetParent(r.dir)
+
+	r.mu.Lock()
+	old := r.servers[name]
+	if old != nil && connected(old) {
+		r.mu.Unlock()
+		warp9.Error("registry: %s already registered; refused", name)
+		mt.Unmount()
+		return
+	}
+	r.servers[name] = mt
+	r.mu.Unlock()
+	if old != nil {
+		old.Unmount()
+	}
+	warp9.Info("registry: %s registered", name)
+
+	<-c9.Closed()
+	r.mu.Lock()
+	if r.servers[name] == mt {
+		delete(r.servers, name)
+	}
+	r.mu.Unlock()
+	mt.Unmount()
+	warp9.Info("registry: %s gone", name)
+}
+
+// true while the connection of mt is up
+func connected(mt *MountPoint) bool {
+	c9 := mt.Clnt()
+	if c9 == nil {
+		return false
+	}
+	select {
+	case <-c9.Closed():
+		return false
+	default:
+		return true
+	}
+}
+
+// Servers returns the names of the registered servers, sorted.
+func (r *Registry) Servers() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.servers))
+	for name := range r.servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns the mount of the named server, or nil.
+func (r *Registry) Lookup(name string) *MountPoint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.servers[name]
+}
+
+// Close unmounts every server.
+func (r *Registry) Close() {
+	r.mu.Lock()
+	servers := r.servers
+	r.servers = make(map[string]*MountPoint)
+	r.mu.Unlock()
+	for _, mt := range servers {
+		mt.Unmount()
+	}
+}
+
+// the directory of a Registry; each fid has its own listing
+type regDir struct {
+	*BaseItem
+	reg    *Registry
+	buffer []byte
+}
+
+func (d *regDir) Name() string {
+	return d.Dir.Name
+}
+
+func (d *regDir) Walk(path []string) (Item, error) {
+	if len(path) < 1 {
+		return d.Walked()
+	}
+	if path[0] == ".." {
+		parent := d.Parent()
+		if parent == nil {
+			return nil, warp9.ErrorCode(warp9.Enotexist)
+		}
+		if len(path) == 1 {
+			return parent.Walked()
+		}
+		return parent.Walk(path[1:])
+	}
+	mt := d.reg.Lookup(path[0])
+	if mt == nil {
+		return nil, warp9.ErrorCode(warp9.Enotexist)
+	}
+	if len(path) == 1 {
+		return mt.Walked()
+	}
+	return mt.Walk(path[1:])
+}
+
+// AddDirectory does nothing; the entries are the servers that call in.
+func (d *regDir) AddDirectory(newDir Directory) {}
+
+// AddItem does nothing; the entries are the servers that call in.
+func (d *regDir) AddItem(item Item) {}
+
+// Children returns the registered servers at the time of the call.
+func (d *regDir) Children() map[string]Item {
+	d.reg.mu.Lock()
+	defer d.reg.mu.Unlock()
+	content := make(map[string]Item, len(d.reg.servers))
+	for name, mt := range d.reg.servers {
+		content[name] = mt
+	}
+	return content
+}
+
+// RemoveItem is not supported; servers leave by disconnecting.
+func (d *regDir) RemoveItem(item Item) error {
+	return warp9.ErrorCode(warp9.Eperm)
+}
+
+func (d *regDir) GetItem() Item {
+	return d
+}
+
+func (d *regDir) IsDirectory() Directory {
+	return d
+}
+
+// Walked returns a copy so each fid has its own listing.
+func (d *regDir) Walked() (Item, error) {
+	base := *d.BaseItem
+	return &regDir{BaseItem: &base, reg: d.reg}, nil
+}
+
+func (d *regDir) Open(mode byte) (uint32, error) {
+	d.buffer = nil
+	return d.BaseItem.Open(mode)
+}
+
+func (d *regDir) Clunk() error {
+	d.buffer = nil
+	return d.BaseItem.Clunk()
+}
+
+// Read returns a Dir entry per registered server.
+func (d *regDir) Read(obuf []byte, off uint64, rcount uint32) (uint32, error) {
+	if d.buffer == nil || off == 0 {
+		d.buffer = make([]byte, 0, 300)
+		for _, name := range d.reg.Servers() {
+			if mt := d.reg.Lookup(name); mt != nil {
+				d.buffer = append(d.buffer, warp9.PackDir(mt.GetDir())...)
+			}
+		}
+	}
+
+	return ReadBuf(obuf, d.buffer, off, rcount), nil
+}
+
+func (d *regDir) Write(ibuf []byte, off uint64, count uint32) (uint32, error) {
+	return 0, warp9.ErrorCode(warp9.Eperm)
+}
+
+func (d *regDir) Remove() error {
+	return warp9.ErrorCode(warp9.Eperm)
+}
+
+func (d *regDir) WStat(dir *warp9.Dir) error {
+	return warp9.ErrorCode(warp9.Eperm)
+}
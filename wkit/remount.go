@@ -0,0 +1,306 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package wkit
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/lavaorg/warp/warp9"
+)
+
+// MountState is the health of a remounting MountPoint.
+type MountState int
+
+const (
+	MountUp           MountState = iota // connected to the remote server
+	MountDown                           // not connected; waiting to retry
+	MountReconnecting                   // trying to connect
+)
+
+func (s MountState) String() string {
+	switch s {
+	case MountUp:
+		return "up"
+	case MountDown:
+		return "down"
+	case MountReconnecting:
+		return "reconnecting"
+	}
+	return fmt.Sprintf("MountState(%d)", int(s))
+}
+
+// A RemountPolicy sets how a MountPoint watches its server and reconnects.
+type RemountPolicy struct {
+	Probe      time.Duration // interval between probes of the server; 0 disables probing
+	MinBackoff time.Duration // first delay before reconnecting
+	MaxBackoff time.Duration // limit of the doubling delay between attempts
+}
+
+// DefaultRemount probes every 30 seconds and retries between 1 and 60 seconds.
+var DefaultRemount = RemountPolicy{
+	Probe:      30 * time.Second,
+	MinBackoff: time.Second,
+	MaxBackoff: time.Minute,
+}
+
+// the health monitor of a mount
+type monitor struct {
+	policy  RemountPolicy
+	stop    chan struct{}
+	changed chan struct{} // closed, and replaced, when the state changes
+	state   MountState
+	since   time.Time // of the last state change
+	err     error     // why the mount went down
+}
+
+// a monitor in state, with the policy's missing delays filled in
+func newMonitor(policy RemountPolicy, state MountState, err error) *monitor {
+	if policy.MinBackoff <= 0 {
+		policy.MinBackoff = DefaultRemount.MinBackoff
+	}
+	if policy.MaxBackoff < policy.MinBackoff {
+		policy.MaxBackoff = policy.MinBackoff
+	}
+	return &monitor{
+		policy:  policy,
+		stop:    make(chan struct{}),
+		changed: make(chan struct{}),
+		state:   state,
+		since:   time.Now(),
+		err:     err,
+	}
+}
+
+// AutoRemount keeps the mount alive: a connection that fails, or a server
+// that stops answering probes, is replaced by a new connection to the same
+// address, attach name, message size and user. Until the server is back
+// every operation on the mount fails with Econn; objects walked to before
+// the failure belong to the old connection and stay broken.
+//
+// Only mounts made by dialing an address can be remounted. Unmount stops
+// the remounting.
+func (mt *MountPoint) AutoRemount(policy RemountPolicy) error {
+	mi := mt.mi
+	if mi.addr == "" {
+		return warp9.ErrorMsg(warp9.Ebaduse, "mount has no address to dial")
+	}
+
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	if mi.clnt == nil {
+		return warp9.ErrorCode(warp9.Econn)
+	}
+	if mi.mon != nil {
+		return warp9.ErrorCode(warp9.Einuse)
+	}
+	mon := newMonitor(policy, MountUp, nil)
+	mi.mon = mon
+	go mi.watch(mon, mi.clnt)
+	return nil
+}
+
+// MountPointRemount returns a mount of the server at addr that connects in
+// the background and then remounts as AutoRemount does. The server need
+// not be up: the mount starts down, and is retried with the backoff of
+// policy until the first connection is made. Until then the mount is an
+// empty directory and its operations fail with Econn.
+func MountPointRemount(ntype, addr, aname string, msize uint32, user warp9.User, policy RemountPolicy) *MountPoint {
+	mi := &MountInfo{Aname: aname, ntype: ntype, addr: addr, msize: msize, user: user, dialer: &net.Dialer{}}
+	mon := newMonitor(policy, MountReconnecting, warp9.ErrorCode(warp9.Econn))
+	mi.mon = mon
+	mt := &MountPoint{mi: mi, root: true}
+	mt.Qid.Type = warp9.QTDIR
+	mt.Mode = warp9.DMDIR | 0555
+
+	go func() {
+		clnt, err := mi.dial()
+		if err == nil && !mi.setState(mon, MountUp, clnt, nil) {
+			clnt.Unmount() // unmounted while dialing
+			return
+		}
+		if err != nil {
+			warp9.Error("mount %s!%s: %v", mi.ntype, mi.addr, err)
+			if clnt = mi.reconnect(mon, err); clnt == nil {
+				return
+			}
+		}
+		warp9.Info("mount %s!%s mounted", mi.ntype, mi.addr)
+		mi.watch(mon, clnt)
+	}()
+	return mt
+}
+
+// State reports the health of the mount, since when it has been so and,
+// unless up, the error that brought it down. A mount that is not remounting
+// is up until unmounted.
+func (mt *MountPoint) State() (MountState, time.Time, error) {
+	mi := mt.mi
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	if mi.mon != nil {
+		return mi.mon.state, mi.mon.since, mi.mon.err
+	}
+	if mi.clnt == nil {
+		return MountDown, time.Time{}, warp9.ErrorCode(warp9.Econn)
+	}
+	return MountUp, time.Time{}, nil
+}
+
+// StateChanged returns a channel closed at the next change of the mount's
+// state, or by Unmount. It is nil, and never closed, unless the mount is
+// remounting.
+func (mt *MountPoint) StateChanged() <-chan struct{} {
+	mi := mt.mi
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	if mi.mon == nil {
+		return nil
+	}
+	return mi.mon.changed
+}
+
+// StatusItem returns an object reporting the state of the mount, to be
+// placed next to it. Reading it gives one line:
+//
+//	net!addr state since [error]
+func (mt *MountPoint) StatusItem(name string) *BytesItem {
+	return NewBytesItem(name, mountStatus{mt})
+}
+
+type mountStatus struct {
+	mt *MountPoint
+}
+
+func (ms mountStatus) Bytes() []byte {
+	state, since, err := ms.mt.State()
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s!%s %v", ms.mt.mi.ntype, ms.mt.mi.addr, state)
+	if !since.IsZero() {
+		fmt.Fprintf(&b, " %s", since.UTC().Format(time.RFC3339))
+	}
+	if state != MountUp && err != nil {
+		fmt.Fprintf(&b, " %q", err.Error())
+	}
+	b.WriteByte('\n')
+	return b.Bytes()
+}
+
+// record a state change; false once the monitor has been stopped
+func (mi *MountInfo) setState(mon *monitor, state MountState, clnt *warp9.Clnt, err error) bool {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	if mi.mon != mon {
+		return false
+	}
+	mi.clnt = clnt
+	if mon.state != state {
+		mon.since = time.Now()
+		close(mon.changed)
+		mon.changed = make(chan struct{})
+	}
+	mon.state = state
+	mon.err = err
+	return true
+}
+
+// watch clnt until it fails, then reconnect; repeat until stopped
+func (mi *MountInfo) watch(mon *monitor, clnt *warp9.Clnt) {
+	for {
+		err := mi.probe(mon, clnt)
+		if err == nil {
+			return // stopped
+		}
+		clnt.Unmount()
+		warp9.Error("mount %s!%s lost: %v", mi.ntype, mi.addr, err)
+
+		if clnt = mi.reconnect(mon, err); clnt == nil {
+			return
+		}
+		warp9.Info("mount %s!%s remounted", mi.ntype, mi.addr)
+	}
+}
+
+// wait for clnt to fail, probing it; nil if the monitor is stopped first
+func (mi *MountInfo) probe(mon *monitor, clnt *warp9.Clnt) error {
+	var tick <-chan time.Time
+	if mon.policy.Probe > 0 {
+		t := time.NewTicker(mon.policy.Probe)
+		defer t.Stop()
+		tick = t.C
+	}
+	for {
+		select {
+		case <-mon.stop:
+			return nil
+		case <-clnt.Closed():
+			if err := clnt.Err(); err != nil {
+				return err
+			}
+			return warp9.ErrorCode(warp9.Econn)
+		case <-tick:
+			// a server that does not answer within a probe interval is
+			// as good as gone
+			done := make(chan error, 1)
+			go func() {
+				_, err := clnt.FStat(clnt.Root)
+				done <- err
+			}()
+			select {
+			case err := <-done:
+				if err != nil && !isServerError(err, clnt) {
+					return err
+				}
+			case <-time.After(mon.policy.Probe):
+				return warp9.ErrorMsg(warp9.Econn, "probe timed out")
+			case <-mon.stop:
+				return nil
+			}
+		}
+	}
+}
+
+// an error answered by a live server, as opposed to a failed connection
+func isServerError(err error, clnt *warp9.Clnt) bool {
+	select {
+	case <-clnt.Closed():
+		return false
+	default:
+	}
+	_, ok := err.(*warp9.WarpError)
+	return ok
+}
+
+// dial until connected, backing off between attempts; nil if stopped
+func (mi *MountInfo) reconnect(mon *monitor, err error) *warp9.Clnt {
+	backoff := mon.policy.MinBackoff
+	for {
+		if !mi.setState(mon, MountDown, nil, err) {
+			return nil
+		}
+		select {
+		case <-mon.stop:
+			return nil
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > mon.policy.MaxBackoff {
+			backoff = mon.policy.MaxBackoff
+		}
+
+		if !mi.setState(mon, MountReconnecting, nil, err) {
+			return nil
+		}
+		var clnt *warp9.Clnt
+		if clnt, err = mi.dial(); err != nil {
+			continue
+		}
+		if !mi.setState(mon, MountUp, clnt, nil) {
+			clnt.Unmount() // unmounted while dialing
+			return nil
+		}
+		return clnt
+	}
+}
@@ -4,6 +4,8 @@
 package wkit
 
 import (
+	"sync"
+
 	"github.com/lavaorg/warp/warp9"
 )
 
@@ -19,7 +21,16 @@ type (
 	ServerController struct {
 		warp9.Srv
 		stats warp9.StatsOps
-		root  Directory
+
+		treeMu sync.Mutex
+		root   Directory               // the default root; a bind may replace it
+		trees  map[string]*tree        // named roots, by aname
+		afids  map[*warp9.SrvFid]*tree // tree of each auth fid
+
+		nsMu   sync.Mutex
+		scope  NamespaceScope
+		connNs map[*warp9.Conn]*Namespace // private namespaces, NsPerConn
+		userNs map[uint32]*Namespace      // private namespaces, NsPerUser
 	}
 )
 
@@ -39,5 +50,7 @@ func NewServer(id string, debugLevel int, root Directory) *ServerController {
 
 // Return the root object for the server.
 func (srv *ServerController) GetRoot() Directory {
+	srv.treeMu.Lock()
+	defer srv.treeMu.Unlock()
 	return srv.root
 }
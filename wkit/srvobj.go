@@ -12,6 +12,12 @@ import (
 // called when SrvFid is destroyed
 func (srv *ServerController) FidDestroy(sfid *warp9.SrvFid) {
 
+	// auth fids left open when their connection closes
+	if sfid.Type&warp9.QTAUTH != 0 {
+		srv.AuthDestroy(sfid)
+		return
+	}
+
 	// if an Item is found then invoke clunk on it
 	if i, ok := sfid.Aux.(Item); ok {
 		err := i.Clunk()
@@ -23,22 +29,46 @@ func (srv *ServerController) FidDestroy(sfid *warp9.SrvFid) {
 }
 
 // Called when a client attaches to this server.
-// the root is always "/"
-//
-// log the attach
-//
+// The aname selects the tree: "" or "/" is the server's root, any
+// other name a tree added with AddTree. Unknown anames are rejected.
+// With a private namespace scope the root is given through the client's
+// Namespace.
 func (srv *ServerController) Attach(req *warp9.SrvReq) {
-	if req.Afid != nil {
-		req.RespondError(warp9.ErrorCode(warp9.Enoauth))
+	aname := req.Tc.Aname
+	t := srv.tree(aname)
+	if t == nil {
+		req.RespondError(warp9.ErrorMsg(warp9.Enotexist, aname))
 		return
 	}
-	_, err := srv.root.Open(warp9.OREAD)
+	if req.Afid != nil {
+		if _, ok := t.policy.(warp9.AuthOps); !ok {
+			req.RespondError(warp9.ErrorCode(warp9.Enoauth))
+			return
+		}
+	}
+	if t.policy != nil {
+		if err := t.policy.Attach(req.Fid.User, aname); err != nil {
+			req.RespondError(err)
+			return
+		}
+	}
+	root := t.root
+	if root == srv.GetRoot() {
+		if ns := srv.namespace(req); ns != nil {
+			var err error
+			if root, err = ns.Root(); err != nil {
+				req.RespondError(err)
+				return
+			}
+		}
+	}
+	_, err := root.Open(warp9.OREAD)
 	if err != nil {
 		req.RespondError(err)
 		return
 	}
-	req.Fid.Aux = srv.root //associate this server's root with client's fid
-	qid := srv.root.GetQid()
+	req.Fid.Aux = root //associate the tree's root with client's fid
+	qid := root.GetQid()
 
 	req.RespondRattach(&qid)
 }
@@ -49,8 +79,20 @@ func (*ServerController) Flush(req *warp9.SrvReq) {}
 // Ensure fid is a directory and invoke the
 // walk method on that directory.
 // Promote the fid if successfully moved
+// A walk of no names clones any object with its Walked method.
 func (*ServerController) Walk(req *warp9.SrvReq) {
 	warp9.Debug("walk:%v", req)
+	if i, ok := req.Fid.Aux.(Item); ok && len(req.Tc.Wname) == 0 && i.IsDirectory() == nil {
+		item, err := i.Walked()
+		if err != nil {
+			req.RespondError(fsRespondError(err, warp9.ErrorCode(warp9.Enoent)))
+			return
+		}
+		req.Newfid.Aux = item
+		qid := item.GetQid()
+		req.RespondRwalk(&qid)
+		return
+	}
 	d, ok := req.Fid.Aux.(Directory)
 	if !ok {
 		req.RespondError(warp9.ErrorCode(warp9.Enotdir))
@@ -102,12 +144,13 @@ func (*ServerController) Clunk(req *warp9.SrvReq) {
 	req.RespondRclunk()
 }
 
-// Ensure target is a directory and invoke its CreateItem method.
-// Promote the fid to new object if successful.
+// Ensure target is a directory and invoke its Create method if it is a
+// Creator. Promote the fid to new object if successful.
 func (*ServerController) Create(req *warp9.SrvReq) {
 	d, ok := req.Fid.Aux.(Directory)
 	if !ok {
 		req.RespondError(warp9.ErrorCode(warp9.Enotdir))
+		return
 	}
 	if d == nil {
 		req.RespondError(warp9.ErrorCode(warp9.Ebaduse))
@@ -117,6 +160,21 @@ func (*ServerController) Create(req *warp9.SrvReq) {
 	// tc is the incoming message
 	tc := req.Tc
 
+	if c, ok := d.(Creator); ok {
+		item, err := c.Create(tc.Name, tc.Perm, tc.Mode)
+		if err != nil {
+			req.RespondError(fsRespondError(err, warp9.ErrorCode(warp9.Eio)))
+			return
+		}
+		// the fid now refers to the new object
+		if item != Item(d) {
+			d.Clunk()
+		}
+		req.Fid.Aux = item
+		req.RespondRcreate(&item.GetDir().Qid, 0)
+		return
+	}
+
 	item := NewDirItem(tc.Name)
 	item.SetMode(tc.Perm)
 
@@ -157,10 +215,12 @@ func (*ServerController) Write(req *warp9.SrvReq) {
 		return
 	}
 
-	// change the m-time and a-time
+	// change the m-time and a-time; bump the version so clients
+	// caching the object can tell it changed
 	d := item.GetDir()
 	d.Atime = uint32(time.Now().Unix())
 	d.Mtime = d.Atime
+	d.Qid.Version++
 
 	req.RespondRwrite(count)
 	return
@@ -180,8 +240,8 @@ func (*ServerController) Remove(req *warp9.SrvReq) {
 
 // Report the object's current status, reply with meta-data.
 func (*ServerController) Stat(req *warp9.SrvReq) {
-	i := req.Fid.Aux.(Item)
-	if i == nil {
+	i, ok := req.Fid.Aux.(Item)
+	if !ok {
 		req.RespondError(warp9.ErrorCode(warp9.Ebaduse))
 		return
 	}
@@ -194,9 +254,19 @@ func (*ServerController) Stat(req *warp9.SrvReq) {
 	return
 }
 
-// not supported
+// Invoke the object's WStat() method.
 func (u *ServerController) Wstat(req *warp9.SrvReq) {
-	req.RespondError(warp9.ErrorCode(warp9.Enotimpl))
+	i, ok := req.Fid.Aux.(Item)
+	if !ok {
+		req.RespondError(warp9.ErrorCode(warp9.Ebaduse))
+		return
+	}
+	err := i.WStat(&req.Tc.Dir)
+	if err != nil {
+		req.RespondError(fsRespondError(err, warp9.ErrorCode(warp9.Eio)))
+		return
+	}
+	req.RespondRwstat()
 	return
 }
 
@@ -0,0 +1,199 @@
+// Copyright 2026 Larry Rau. All rights reserved
+// See Apache2 LICENSE
+
+package wkit
+
+import (
+	"github.com/lavaorg/warp/warp9"
+)
+
+// A TreePolicy decides who may attach to a named tree. A policy that also
+// implements warp9.AuthOps authenticates the Tauth requests naming its
+// tree; the trees of a policy without AuthOps do not accept an afid.
+type TreePolicy interface {
+	// Attach is called before user is given the root of the tree named
+	// aname. A non-nil error rejects the attach and is sent to the client.
+	Attach(user warp9.User, aname string) error
+}
+
+// PolicyFunc adapts a function to a TreePolicy.
+type PolicyFunc func(user warp9.User, aname string) error
+
+func (f PolicyFunc) Attach(user warp9.User, aname string) error {
+	return f(user, aname)
+}
+
+// AllowUsers returns a policy admitting only the users with the given ids.
+func AllowUsers(uids ...uint32) TreePolicy {
+	allowed := make(map[uint32]bool, len(uids))
+	for _, uid := range uids {
+		allowed[uid] = true
+	}
+	return PolicyFunc(func(user warp9.User, aname string) error {
+		if user == nil || !allowed[user.Id()] {
+			return warp9.ErrorCode(warp9.Eperm)
+		}
+		return nil
+	})
+}
+
+// one root served under an aname
+type tree struct {
+	root   Directory
+	policy TreePolicy // nil admits everyone
+}
+
+// AddTree serves root to the clients attaching with aname. The default
+// root given to NewServer remains reachable with an empty aname or "/".
+func (srv *ServerController) AddTree(aname string, root Directory, policy TreePolicy) error {
+	if aname == "" || aname == "/" {
+		return warp9.ErrorMsg(warp9.Ename, aname)
+	}
+	if root == nil {
+		return warp9.ErrorCode(warp9.Einval)
+	}
+	srv.treeMu.Lock()
+	defer srv.treeMu.Unlock()
+	if _, ok := srv.trees[aname]; ok {
+		return warp9.ErrorMsg(warp9.Eexist, aname)
+	}
+	if srv.trees == nil {
+		srv.trees = make(map[string]*tree)
+	}
+	srv.trees[aname] = &tree{root: root, policy: policy}
+	return nil
+}
+
+// RemoveTree stops serving the tree named aname. Clients already attached
+// keep their fids.
+func (srv *ServerController) RemoveTree(aname string) error {
+	srv.treeMu.Lock()
+	defer srv.treeMu.Unlock()
+	if _, ok := srv.trees[aname]; !ok {
+		return warp9.ErrorMsg(warp9.Enotexist, aname)
+	}
+	delete(srv.trees, aname)
+	return nil
+}
+
+// Tree returns the root served under aname, or nil.
+func (srv *ServerController) Tree(aname string) Directory {
+	if t := srv.tree(aname); t != nil {
+		return t.root
+	}
+	return nil
+}
+
+// Trees returns the anames of the named trees.
+func (srv *ServerController) Trees() []string {
+	srv.treeMu.Lock()
+	defer srv.treeMu.Unlock()
+	names := make([]string, 0, len(srv.trees))
+	for aname := range srv.trees {
+		names = append(names, aname)
+	}
+	return names
+}
+
+// the tree selected by aname; nil if there is none
+func (srv *ServerController) tree(aname string) *tree {
+	srv.treeMu.Lock()
+	defer srv.treeMu.Unlock()
+	if aname == "" || aname == "/" {
+		if srv.root == nil {
+			return nil
+		}
+		return &tree{root: srv.root}
+	}
+	return srv.trees[aname]
+}
+
+//
+// warp9.AuthOps; each request is handed to the policy of the tree it names
+//
+
+func (srv *ServerController) AuthInit(afid *warp9.SrvFid, aname string) (*warp9.Qid, error) {
+	t := srv.tree(aname)
+	if t == nil {
+		return nil, warp9.ErrorMsg(warp9.Enotexist, aname)
+	}
+	aop, ok := t.policy.(warp9.AuthOps)
+	if !ok {
+		return nil, warp9.ErrorCode(warp9.Enoauth)
+	}
+	qid, err := aop.AuthInit(afid, aname)
+	if err != nil {
+		return nil, err
+	}
+	srv.treeMu.Lock()
+	if srv.afids == nil {
+		srv.afids = make(map[*warp9.SrvFid]*tree)
+	}
+	srv.afids[afid] = t
+	srv.treeMu.Unlock()
+	return qid, nil
+}
+
+func (srv *ServerController) AuthDestroy(afid *warp9.SrvFid) {
+	if aop := srv.authOps(afid, true); aop != nil {
+		aop.AuthDestroy(afid)
+	}
+}
+
+// Unknown anames pass here so that Attach can reject them by name.
+func (srv *ServerController) AuthCheck(fid *warp9.SrvFid, afid *warp9.SrvFid, aname string) error {
+	t := srv.tree(aname)
+	if t == nil {
+		return nil
+	}
+	aop, ok := t.policy.(warp9.AuthOps)
+	if !ok {
+		if afid != nil {
+			return warp9.ErrorCode(warp9.Enoauth)
+		}
+		return nil
+	}
+	if afid != nil && srv.authTree(afid) != t {
+		// authenticated for a different tree
+		return warp9.ErrorCode(warp9.Eperm)
+	}
+	return aop.AuthCheck(fid, afid, aname)
+}
+
+func (srv *ServerController) AuthRead(afid *warp9.SrvFid, offset uint64, data []byte) (int, error) {
+	aop := srv.authOps(afid, false)
+	if aop == nil {
+		return 0, warp9.ErrorCode(warp9.Ebaduse)
+	}
+	return aop.AuthRead(afid, offset, data)
+}
+
+func (srv *ServerController) AuthWrite(afid *warp9.SrvFid, offset uint64, data []byte) (int, error) {
+	aop := srv.authOps(afid, false)
+	if aop == nil {
+		return 0, warp9.ErrorCode(warp9.Ebaduse)
+	}
+	return aop.AuthWrite(afid, offset, data)
+}
+
+// the tree an auth fid was opened for
+func (srv *ServerController) authTree(afid *warp9.SrvFid) *tree {
+	srv.treeMu.Lock()
+	defer srv.treeMu.Unlock()
+	return srv.afids[afid]
+}
+
+// the AuthOps handling afid; forget afid if drop is set
+func (srv *ServerController) authOps(afid *warp9.SrvFid, drop bool) warp9.AuthOps {
+	srv.treeMu.Lock()
+	defer srv.treeMu.Unlock()
+	t, ok := srv.afids[afid]
+	if !ok {
+		return nil
+	}
+	if drop {
+		delete(srv.afids, afid)
+	}
+	aop, _ := t.policy.(warp9.AuthOps)
+	return aop
+}
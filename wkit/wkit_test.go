@@ -6,12 +6,21 @@ package wkit
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/lavaorg/warp/tools"
@@ -257,3 +266,1345 @@ func testCat(mount *warp9.Clnt) error {
 	fmt.Println("\n---- done ----")
 	return nil
 }
+
+// TestGetBatch pipelines walk/open/read/clunk of many objects and checks
+// the results come back in submission order.
+func TestGetBatch(t *testing.T) {
+	batch := NewDirItem("batch")
+	paths := make([]string, 0, 50)
+	for i := 0; i < 50; i++ {
+		name := fmt.Sprintf("obj%d", i)
+		item := NewItem(name)
+		item.SetBuffer([]byte(name))
+		batch.AddItem(item)
+		paths = append(paths, "/batch/"+name)
+	}
+	paths = append(paths, "/batch/missing")
+	getRoot().AddDirectory(batch)
+
+	c9, err := mountServer()
+	if err != nil {
+		t.Fatalf("mount failed: %v", err)
+	}
+	defer c9.Unmount()
+
+	i := 0
+	for res := range c9.GetBatch(paths, 8) {
+		if res.Path != paths[i] {
+			t.Fatalf("result %d out of order: got %s want %s", i, res.Path, paths[i])
+		}
+		if i == len(paths)-1 {
+			if res.Err == nil {
+				t.Errorf("expected error for %s", res.Path)
+			}
+		} else if res.Err != nil || string(res.Data) != fmt.Sprintf("obj%d", i) {
+			t.Errorf("%s: data %q err %v", res.Path, res.Data, res.Err)
+		}
+		i++
+	}
+	if i != len(paths) {
+		t.Errorf("got %d results, want %d", i, len(paths))
+	}
+}
+
+// TestGetBatchAbandoned stops reading a batch early; the batch must
+// still run to completion rather than block on its results.
+func TestGetBatchAbandoned(t *testing.T) {
+	batch := NewDirItem("abandoned")
+	var paths []string
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("obj%d", i)
+		item := NewItem(name)
+		item.SetBuffer([]byte(name))
+		batch.AddItem(item)
+		paths = append(paths, "/abandoned/"+name)
+	}
+	getRoot().AddDirectory(batch)
+
+	c9, err := mountServer()
+	if err != nil {
+		t.Fatalf("mount failed: %v", err)
+	}
+	defer c9.Unmount()
+
+	before := runtime.NumGoroutine()
+	results := c9.GetBatch(paths, 4)
+	<-results
+	for wait := 0; runtime.NumGoroutine() > before; wait++ {
+		if wait == 100 {
+			t.Fatalf("batch blocked: %d goroutines, %d before", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := len(results); n != len(paths)-1 {
+		t.Errorf("%d results waiting, want %d", n, len(paths)-1)
+	}
+}
+
+// TestReadPastEnd reads objects and directories far past their end.
+func TestReadPastEnd(t *testing.T) {
+	tree := NewDirItem("pastend")
+	item := NewItem("obj")
+	item.SetBuffer([]byte("data"))
+	tree.AddItem(item)
+	tree.AddDirectory(NewFSDir("fs", fstest.MapFS{"readme": {Data: []byte("hello")}}))
+	getRoot().AddDirectory(tree)
+
+	c9, err := mountServer()
+	if err != nil {
+		t.Fatalf("mount failed: %v", err)
+	}
+	defer c9.Unmount()
+
+	for _, path := range []string{"/pastend", "/pastend/obj", "/pastend/fs"} {
+		obj, err := c9.Open(path, warp9.OREAD)
+		if err != nil {
+			t.Fatalf("open %v: %v", path, err)
+		}
+		buf := make([]byte, 64)
+		for _, off := range []uint64{1 << 20, 1 << 40} {
+			if n, err := obj.Readn(buf, off); n != 0 || (err != nil && err != io.EOF) {
+				t.Errorf("read %v at %d: %d bytes, %v", path, off, n, err)
+			}
+		}
+		obj.Close()
+	}
+	if n := ReadBuf(make([]byte, 8), []byte("data"), 1<<40, 8); n != 0 {
+		t.Errorf("ReadBuf past end: %d bytes", n)
+	}
+	if n := ReadBuf(make([]byte, 8), []byte("data"), 1, 2); n != 2 {
+		t.Errorf("ReadBuf: %d bytes, want 2", n)
+	}
+}
+
+// TestClntFS reads a served tree through the io/fs adapter.
+func TestClntFS(t *testing.T) {
+	tree := NewDirItem("fstree")
+	sub := NewDirItem("sub")
+	for _, n := range []string{"a", "b"} {
+		item := NewItem(n)
+		item.SetBuffer([]byte("contents of " + n))
+		sub.AddItem(item)
+	}
+	tree.AddDirectory(sub)
+	top := NewItem("top")
+	top.SetBuffer([]byte("top"))
+	tree.AddItem(top)
+	getRoot().AddDirectory(tree)
+
+	c9, err := mountServer()
+	if err != nil {
+		t.Fatalf("mount failed: %v", err)
+	}
+	defer c9.Unmount()
+
+	fsys, err := fs.Sub(warp9.NewFS(c9), "fstree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(fsys, "top", "sub/a", "sub/b"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := fs.ReadFile(fsys, "sub/b")
+	if err != nil || string(data) != "contents of b" {
+		t.Errorf("ReadFile: %q, %v", data, err)
+	}
+	if _, err := fs.Stat(fsys, "sub/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat missing: want ErrNotExist, got %v", err)
+	}
+}
+
+// TestFSDir serves an fs.FS through FSDir and reads it back.
+func TestFSDir(t *testing.T) {
+	mtime := time.Unix(1500000000, 0)
+	mfs := fstest.MapFS{
+		"readme":       {Data: []byte("hello"), ModTime: mtime},
+		"conf/a.json":  {Data: []byte(`{"a":1}`), ModTime: mtime},
+		"conf/b/deep":  {Data: []byte(strings.Repeat("x", 10000)), ModTime: mtime},
+		"conf/b/empty": {ModTime: mtime},
+	}
+	getRoot().AddDirectory(NewFSDir("assets", mfs))
+
+	c9, err := mountServer()
+	if err != nil {
+		t.Fatalf("mount failed: %v", err)
+	}
+	defer c9.Unmount()
+
+	fsys, err := fs.Sub(warp9.NewFS(c9), "assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(fsys, "readme", "conf/a.json", "conf/b/deep", "conf/b/empty"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := fs.ReadFile(fsys, "conf/b/deep")
+	if err != nil || len(data) != 10000 {
+		t.Errorf("ReadFile: %d bytes, %v", len(data), err)
+	}
+
+	// qids depend only on the tree, not on the server instance
+	d1, err := c9.Stat("/assets/conf/a.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewFSDir("other", mfs).Walk([]string{"conf", "a.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d1.Qid != other.GetQid() {
+		t.Errorf("unstable qid: %v != %v", d1.Qid, other.GetQid())
+	}
+
+	if _, err := c9.Stat("/assets/conf/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat missing: want ErrNotExist, got %v", err)
+	}
+	if _, err := c9.Open("/assets/readme", warp9.OWRITE); err == nil {
+		t.Error("opened read-only file for writing")
+	}
+}
+
+// TestExportDir exercises a read-write export of a host directory.
+func TestExportDir(t *testing.T) {
+	host := t.TempDir()
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret"), filepath.Join(host, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	exp, err := NewExportDir("export", host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	getRoot().AddDirectory(exp)
+
+	c9, err := mountServer()
+	if err != nil {
+		t.Fatalf("mount failed: %v", err)
+	}
+	defer c9.Unmount()
+
+	// create, write and read back
+	obj, err := c9.Create("/export/f", 0644, warp9.ORDWR)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := obj.Write([]byte("hello world")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	obj.Close()
+	if data, err := os.ReadFile(filepath.Join(host, "f")); err != nil || string(data) != "hello world" {
+		t.Fatalf("host contents: %q, %v", data, err)
+	}
+	if data, _, err := c9.Get("/export/f", 0); err != nil || string(data) != "hello world" {
+		t.Errorf("get: %q, %v", data, err)
+	}
+
+	// directories
+	if _, err := c9.Create("/export/sub", warp9.DMDIR|0755, warp9.OREAD); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	dirs, err := c9.ReadDir("/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, d := range dirs {
+		names = append(names, d.Name)
+	}
+	if strings.Join(names, ",") != "f,sub" {
+		t.Errorf("listing: %v", names)
+	}
+
+	// wstat: truncate, chmod and rename
+	fid, err := c9.Walk("/export/f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nd := warp9.NullDir()
+	nd.Length = 5
+	nd.Mode = 0600
+	nd.Name = "g"
+	if err := c9.FWstat(fid, nd); err != nil {
+		t.Fatalf("wstat: %v", err)
+	}
+	c9.Clunk(fid)
+	fi, err := os.Stat(filepath.Join(host, "g"))
+	if err != nil || fi.Size() != 5 || fi.Mode().Perm() != 0600 {
+		t.Errorf("after wstat: %v, %v", fi, err)
+	}
+
+	// confinement
+	if _, err := c9.Stat("/export/escape"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("symlink escape: want ErrPermission, got %v", err)
+	}
+	if d, err := c9.Stat("/export/sub/../.."); err != nil || d.Qid != getRoot().GetQid() {
+		t.Errorf(".. left the export for %v, %v", d, err)
+	}
+
+	// remove
+	if err := c9.Remove("/export/g"); err != nil {
+		t.Errorf("remove: %v", err)
+	}
+	if err := c9.Remove("/export/sub"); err != nil {
+		t.Errorf("remove dir: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(host, "g")); !os.IsNotExist(err) {
+		t.Errorf("file still on host: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "secret")); err != nil {
+		t.Errorf("outside file touched: %v", err)
+	}
+}
+
+// TestBind builds a union directory and checks walks, reads and creates.
+func TestBind(t *testing.T) {
+	bt := NewDirItem("bindtest")
+	a := NewDirItem("a")
+	b := NewDirItem("b")
+	for _, f := range []struct {
+		dir        Directory
+		name, data string
+	}{
+		{a, "x", "a-x"}, {a, "y", "a-y"}, {b, "y", "b-y"}, {b, "z", "b-z"},
+	} {
+		item := NewItem(f.name)
+		item.SetBuffer([]byte(f.data))
+		f.dir.AddItem(item)
+	}
+	bt.AddDirectory(a)
+	getRoot().AddDirectory(bt)
+	host := t.TempDir()
+	c, err := NewExportDir("c", host)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a controller over the tree served by the test server
+	ctl := NewServer("bind", tracelevel, getRoot())
+	if err := ctl.Bind(b, "/bindtest/a", warp9.MBEFORE); err != nil {
+		t.Fatalf("bind before: %v", err)
+	}
+	if err := ctl.Bind(c, "/bindtest/a", warp9.MAFTER|warp9.MCREATE); err != nil {
+		t.Fatalf("bind after: %v", err)
+	}
+
+	c9, err := mountServer()
+	if err != nil {
+		t.Fatalf("mount failed: %v", err)
+	}
+	defer c9.Unmount()
+
+	listing := func() string {
+		dirs, err := c9.ReadDir("/bindtest/a")
+		if err != nil {
+			t.Fatalf("readdir: %v", err)
+		}
+		names := []string{}
+		for _, d := range dirs {
+			names = append(names, d.Name)
+		}
+		sort.Strings(names)
+		return strings.Join(names, ",")
+	}
+	get := func(path string) string {
+		data, _, err := c9.Get(path, 0)
+		if err != nil {
+			return err.Error()
+		}
+		return string(data)
+	}
+
+	if got := listing(); got != "x,y,z" {
+		t.Errorf("union listing: %v", got)
+	}
+	if got := get("/bindtest/a/y"); got != "b-y" {
+		t.Errorf("earlier bind should hide later: %q", got)
+	}
+	if got := get("/bindtest/a/x"); got != "a-x" {
+		t.Errorf("walk through union: %q", got)
+	}
+
+	// creates go to the MCREATE member
+	obj, err := c9.Create("/bindtest/a/new", 0644, warp9.OWRITE)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	obj.Close()
+	if _, err := os.Stat(filepath.Join(host, "new")); err != nil {
+		t.Errorf("created object not in create member: %v", err)
+	}
+	if got := listing(); got != "new,x,y,z" {
+		t.Errorf("listing after create: %v", got)
+	}
+
+	// replace a file
+	r := NewItem("x")
+	r.SetBuffer([]byte("replaced"))
+	if err := ctl.Bind(r, "/bindtest/a/x", warp9.MREPL); err != nil {
+		t.Fatalf("bind replace: %v", err)
+	}
+	if got := get("/bindtest/a/x"); got != "replaced" {
+		t.Errorf("replaced file: %q", got)
+	}
+	if err := ctl.Bind(b, "/bindtest/a/x", warp9.MAFTER); err == nil {
+		t.Error("union onto a file succeeded")
+	}
+
+	// undo
+	if err := ctl.Unbind(nil, "/bindtest/a"); err != nil {
+		t.Fatalf("unbind: %v", err)
+	}
+	if got := listing(); got != "x,y" {
+		t.Errorf("listing after unbind: %v", got)
+	}
+	if got := get("/bindtest/a/y"); got != "a-y" {
+		t.Errorf("after unbind: %q", got)
+	}
+
+	// undoing every bind drops the merged entries of an open bind point
+	u := NewBindPoint(NewDirItem("u"))
+	u.Bind(b, warp9.MAFTER)
+	if _, err := u.Open(warp9.OREAD); err != nil {
+		t.Fatal(err)
+	}
+	u.Unbind(nil)
+	if n, _ := u.Read(make([]byte, 512), 0, 512); n != 0 {
+		t.Errorf("read %d bytes after unbind", n)
+	}
+
+	// the root may be bound onto while attaches look it up
+	srv := NewServer("bindroot", 0, NewDirItem("/"))
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			srv.Tree("")
+		}
+		done <- true
+	}()
+	if err := srv.Bind(NewDirItem("extra"), "/", warp9.MAFTER); err != nil {
+		t.Errorf("bind onto root: %v", err)
+	}
+	<-done
+	if _, ok := srv.GetRoot().(*BindPoint); !ok {
+		t.Errorf("root %T is not a bind point", srv.GetRoot())
+	}
+}
+
+func TestTrees(t *testing.T) {
+	tree := func(name, file, data string) Directory {
+		d := NewDirItem(name)
+		item := NewItem(file)
+		item.SetBuffer([]byte(data))
+		d.AddItem(item)
+		return d
+	}
+	srv := NewServer("trees", tracelevel, tree("/", "hello", "default"))
+	if err := srv.AddTree("data", tree("data", "hello", "data"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.AddTree("ctl", tree("ctl", "hello", "ctl"), AllowUsers(2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.AddTree("data", NewDirItem("data"), nil); err == nil {
+		t.Error("duplicate tree added")
+	}
+	if err := srv.AddTree("/", NewDirItem("x"), nil); err == nil {
+		t.Error("default root replaced")
+	}
+	if !srv.Start(srv) {
+		t.Fatal("unable to start server")
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go srv.StartListener(l)
+
+	attach := func(aname string, uid uint32) (string, error) {
+		c9, err := warp9.Mount("tcp", l.Addr().String(), aname, 8192, warp9.Identity.User(uid))
+		if err != nil {
+			return "", err
+		}
+		defer c9.Unmount()
+		data, _, err := c9.Get("/hello", 0)
+		return string(data), err
+	}
+	for _, tt := range []struct {
+		aname string
+		uid   uint32
+		want  string
+	}{
+		{"", 1, "default"}, {"/", 1, "default"}, {"data", 1, "data"}, {"ctl", 2, "ctl"},
+	} {
+		got, err := attach(tt.aname, tt.uid)
+		if err != nil || got != tt.want {
+			t.Errorf("attach %q: %q, %v", tt.aname, got, err)
+		}
+	}
+	if _, err := attach("ctl", 1); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("policy not applied: %v", err)
+	}
+	if _, err := attach("debug", 1); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("unknown aname: %v", err)
+	}
+
+	// a tree without an auth policy needs no authentication
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c9, err := warp9.Connect(c, 8192)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c9.Auth(warp9.Identity.User(1), "data"); !errors.Is(err, warp9.ErrorCode(warp9.Enoauth)) {
+		t.Errorf("auth without a policy: %v", err)
+	}
+	c9.Unmount()
+	if err := srv.RemoveTree("data"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := attach("data", 1); err == nil {
+		t.Error("removed tree still served")
+	}
+}
+
+// a server giving each connection its own session directory
+type sessionServer struct {
+	*ServerController
+	sync.Mutex
+	n int
+}
+
+func (s *sessionServer) ConnOpened(conn *warp9.Conn) {
+	s.Lock()
+	s.n++
+	id := strconv.Itoa(s.n)
+	s.Unlock()
+	sess := NewDirItem("session")
+	item := NewItem("id")
+	item.SetBuffer([]byte(id))
+	sess.AddItem(item)
+	if err := s.ConnNamespace(conn).Bind(sess, "/session", warp9.MREPL); err != nil {
+		warp9.Error("session bind: %v", err)
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	nsroot := NewDirItem("/")
+	shared := NewItem("shared")
+	shared.SetBuffer([]byte("shared"))
+	nsroot.AddItem(shared)
+	nsroot.AddDirectory(NewDirItem("session"))
+
+	s := &sessionServer{ServerController: NewServer("ns", tracelevel, nsroot)}
+	s.SetNamespaceScope(NsPerConn)
+	if !s.Start(s) {
+		t.Fatal("unable to start server")
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go s.StartListener(l)
+
+	mount := func(uid uint32) *warp9.Clnt {
+		c9, err := warp9.Mount("tcp", l.Addr().String(), "", 8192, warp9.Identity.User(uid))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c9
+	}
+	get := func(c9 *warp9.Clnt, p string) string {
+		data, _, err := c9.Get(p, 0)
+		if err != nil {
+			return err.Error()
+		}
+		return string(data)
+	}
+
+	c1 := mount(1)
+	defer c1.Unmount()
+	c2 := mount(1)
+	defer c2.Unmount()
+	if got := get(c1, "/session/id"); got != "1" {
+		t.Errorf("first connection: %q", got)
+	}
+	if got := get(c2, "/session/id"); got != "2" {
+		t.Errorf("second connection: %q", got)
+	}
+	if got := get(c2, "/session/../shared"); got != "shared" {
+		t.Errorf("shared object: %q", got)
+	}
+	if _, ok := nsroot.Children()["session"].IsDirectory().Children()["id"]; ok {
+		t.Error("private bind changed the shared tree")
+	}
+
+	// per user; a bind made before the user attaches
+	u := NewServer("nsuser", tracelevel, nsroot)
+	u.SetNamespaceScope(NsPerUser)
+	other := NewItem("other")
+	other.SetBuffer([]byte("user 2"))
+	if err := u.UserNamespace(warp9.Identity.User(2)).Bind(other, "/shared", warp9.MREPL); err != nil {
+		t.Fatal(err)
+	}
+	if !u.Start(u) {
+		t.Fatal("unable to start server")
+	}
+	lu, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lu.Close()
+	go u.StartListener(lu)
+	l = lu
+	c3 := mount(2)
+	defer c3.Unmount()
+	c4 := mount(1)
+	defer c4.Unmount()
+	if got := get(c3, "/shared"); got != "user 2" {
+		t.Errorf("user namespace: %q", got)
+	}
+	if got := get(c4, "/shared"); got != "shared" {
+		t.Errorf("other user: %q", got)
+	}
+	if err := u.UserNamespace(warp9.Identity.User(2)).Unbind(nil, "/shared"); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(c3, "/shared"); got != "shared" {
+		t.Errorf("after unbind: %q", got)
+	}
+
+	// only directories that can create are Creators in a namespace
+	plain, err := NewNamespace(NewDirItem("/")).Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := plain.(Creator); ok {
+		t.Error("namespace of a DirItem is a Creator")
+	}
+	exp, err := NewExportDir("exp", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := NewNamespace(exp).Root(); err != nil {
+		t.Fatal(err)
+	} else if _, ok := d.(Creator); !ok {
+		t.Error("namespace of an ExportDir is not a Creator")
+	}
+
+	// a slow walk does not hold up the namespace
+	slow := &slowDir{Directory: NewDirItem("/"), entered: make(chan bool, 1), release: make(chan bool)}
+	sns := NewNamespace(slow)
+	go sns.Walk("/x")
+	defer close(slow.release)
+	<-slow.entered
+	done := make(chan error)
+	go func() { done <- sns.Unbind(nil, "/x") }()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Error("namespace locked during a walk")
+	}
+}
+
+// a directory whose walks wait to be released
+type slowDir struct {
+	Directory
+	entered chan bool
+	release chan bool
+}
+
+func (d *slowDir) Walk(names []string) (Item, error) {
+	d.entered <- true
+	<-d.release
+	return d.Directory.Walk(names)
+}
+
+func (d *slowDir) IsDirectory() Directory {
+	return d
+}
+
+// start a server for root on a local port
+// wait up to 5 seconds for cond, failing the test if it never holds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func listenServer(t *testing.T, id string, root Directory) net.Listener {
+	srv := NewServer(id, tracelevel, root)
+	if !srv.Start(srv) {
+		t.Fatal("unable to start server")
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.StartListener(l)
+	return l
+}
+
+func TestMountPoint(t *testing.T) {
+	host := t.TempDir()
+	export, err := NewExportDir("/", host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lb := listenServer(t, "backend", export)
+	defer lb.Close()
+
+	user := warp9.Identity.User(1)
+	mt, err := MountPointDial("tcp", lb.Addr().String(), "", 0, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mt.Unmount()
+	mt.SetName("mnt")
+	front := NewDirItem("/")
+	other := NewItem("other")
+	other.SetBuffer([]byte("local"))
+	front.AddItem(other)
+	front.AddDirectory(mt)
+	lf := listenServer(t, "front", front)
+	defer lf.Close()
+
+	c9, err := warp9.Mount("tcp", lf.Addr().String(), "", 8192, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c9.Unmount()
+
+	// create through the proxy; the mount itself is not changed
+	obj, err := c9.Create("/mnt/new", 0644, warp9.OWRITE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := obj.Write([]byte("proxied")); err != nil {
+		t.Fatal(err)
+	}
+	obj.Close()
+	if data, err := os.ReadFile(filepath.Join(host, "new")); err != nil || string(data) != "proxied" {
+		t.Errorf("created file: %q, %v", data, err)
+	}
+	if obj, err = c9.Create("/mnt/sub", warp9.DMDIR|0755, warp9.OREAD); err != nil {
+		t.Fatal(err)
+	}
+	obj.Close()
+	if d, err := c9.Stat("/mnt"); err != nil || d.Name != "mnt" || d.Qid.Type&warp9.QTDIR == 0 {
+		t.Errorf("mount stat: %v, %v", d, err)
+	}
+
+	// walked objects have their own attributes, not the mount's
+	for _, w := range []struct {
+		name   string
+		length uint64
+		mode   uint32
+	}{{"new", 7, 0644}, {"sub", 0, warp9.DMDIR | 0755}} {
+		item, err := mt.Walk([]string{w.name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := item.GetDir()
+		if d.Name != w.name || d.Mode&(warp9.DMDIR|0777) != w.mode || (w.length != 0 && d.Length != w.length) {
+			t.Errorf("walked %s: %v", w.name, d)
+		}
+		item.Clunk()
+	}
+
+	// listings come from the remote side
+	dirs, err := c9.ReadDir("/mnt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, d := range dirs {
+		names = append(names, d.Name)
+	}
+	sort.Strings(names)
+	if strings.Join(names, " ") != "new sub" {
+		t.Errorf("remote listing: %v", names)
+	}
+
+	// ".." leaves the mount
+	if data, _, err := c9.Get("/mnt/sub/../../other", 0); err != nil || string(data) != "local" {
+		t.Errorf("walk out of the mount: %q, %v", data, err)
+	}
+
+	// remove and error codes pass through
+	if err := c9.Remove("/mnt/new"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(host, "new")); !os.IsNotExist(err) {
+		t.Errorf("remote object not removed: %v", err)
+	}
+	if _, err := c9.Stat("/mnt/new"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("stat removed object: %v", err)
+	}
+	if _, ok := front.Children()["mnt"]; !ok {
+		t.Error("removing a remote object removed the mount")
+	}
+	if err := c9.Remove("/mnt"); err == nil {
+		t.Error("mount root removed")
+	}
+}
+
+// serve root on l, keeping the connections so they can be dropped
+type droppable struct {
+	sync.Mutex
+	conns []net.Conn
+}
+
+func serveDroppable(t *testing.T, l net.Listener, root Directory) *droppable {
+	srv := NewServer("droppable", tracelevel, root)
+	if !srv.Start(srv) {
+		t.Fatal("unable to start server")
+	}
+	d := &droppable{}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			d.Lock()
+			d.conns = append(d.conns, c)
+			d.Unlock()
+			srv.NewConn(c)
+		}
+	}()
+	return d
+}
+
+func (d *droppable) drop() {
+	d.Lock()
+	for _, c := range d.conns {
+		c.Close()
+	}
+	d.conns = nil
+	d.Unlock()
+}
+
+func TestRemount(t *testing.T) {
+	broot := NewDirItem("/")
+	hello := NewItem("hello")
+	hello.SetBuffer([]byte("remote"))
+	broot.AddItem(hello)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := serveDroppable(t, l, broot)
+
+	user := warp9.Identity.User(1)
+	mt, err := MountPointDial("tcp", l.Addr().String(), "", 0, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mt.Unmount()
+	err = mt.AutoRemount(RemountPolicy{Probe: 50 * time.Millisecond, MinBackoff: 10 * time.Millisecond, MaxBackoff: 40 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mt.SetName("mnt")
+	front := NewDirItem("/")
+	front.AddDirectory(mt)
+	front.AddItem(mt.StatusItem("mnt.status"))
+	lf := listenServer(t, "front", front)
+	defer lf.Close()
+
+	c9, err := warp9.Mount("tcp", lf.Addr().String(), "", 8192, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c9.Unmount()
+	get := func(p string) string {
+		data, _, err := c9.Get(p, 0)
+		if err != nil {
+			return err.Error()
+		}
+		return string(data)
+	}
+
+	if got := get("/mnt/hello"); got != "remote" {
+		t.Fatalf("before failure: %q", got)
+	}
+	if got := get("/mnt.status"); !strings.HasPrefix(got, "tcp!"+l.Addr().String()+" up ") {
+		t.Errorf("status: %q", got)
+	}
+
+	// the backend restarts; the mount comes back by itself
+	old := mt.Clnt()
+	backend.drop()
+	waitFor(t, "the remount", func() bool { c := mt.Clnt(); return c != nil && c != old })
+	if got := get("/mnt/hello"); got != "remote" {
+		t.Errorf("after remount: %q", got)
+	}
+
+	// the backend goes away for good
+	l.Close()
+	backend.drop()
+	waitFor(t, "the mount to go down", func() bool { state, _, _ := mt.State(); return state == MountDown })
+	if got := get("/mnt.status"); !strings.Contains(got, " down ") {
+		t.Errorf("status while down: %q", got)
+	}
+	if _, err := c9.Stat("/mnt/hello"); err == nil {
+		t.Error("walk into a down mount succeeded")
+	}
+
+	mt.Unmount()
+	if state, _, _ := mt.State(); state != MountDown {
+		t.Errorf("state after unmount: %v", state)
+	}
+
+	// a mount made before its server is up connects once it is
+	ll, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ll.Addr().String()
+	ll.Close()
+	late := MountPointRemount("tcp", addr, "", 0, user, RemountPolicy{MinBackoff: 10 * time.Millisecond, MaxBackoff: 10 * time.Millisecond})
+	defer late.Unmount()
+	if late.IsDirectory() == nil {
+		t.Error("down mount is not a directory")
+	}
+	if _, err := late.Walk([]string{"hello"}); err == nil {
+		t.Error("walk into a mount not yet up succeeded")
+	}
+	if ll, err = net.Listen("tcp", addr); err != nil {
+		t.Skip(err)
+	}
+	defer ll.Close()
+	serveDroppable(t, ll, broot)
+	deadline := time.Now().Add(5 * time.Second)
+	for state, _, _ := late.State(); state != MountUp; state, _, _ = late.State() {
+		select {
+		case <-late.StateChanged():
+		case <-time.After(time.Until(deadline)):
+			t.Fatalf("late mount not up: %v", state)
+		}
+	}
+	if item, err := late.Walk([]string{"hello"}); err != nil {
+		t.Errorf("walk after connecting: %v", err)
+	} else {
+		item.Clunk()
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	user := warp9.Identity.User(1)
+	reg := NewRegistry("devices", "", 0, user)
+	defer reg.Close()
+	lr, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lr.Close()
+	go reg.Serve(lr)
+
+	front := NewDirItem("/")
+	front.AddDirectory(reg.Dir())
+	lf := listenServer(t, "front", front)
+	defer lf.Close()
+
+	// edge servers dial in to the registry
+	edge := func(name string) net.Conn {
+		root := NewDirItem(name)
+		id := NewItem("id")
+		id.SetBuffer([]byte(name))
+		root.AddItem(id)
+		srv := NewServer(name, tracelevel, root)
+		if !srv.Start(srv) {
+			t.Fatal("unable to start edge server")
+		}
+		c, err := net.Dial("tcp", lr.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.NewConn(c)
+		return c
+	}
+	waitServers := func(want string) {
+		t.Helper()
+		waitFor(t, "servers "+want, func() bool { return strings.Join(reg.Servers(), " ") == want })
+	}
+	e1 := edge("edge1")
+	defer e1.Close()
+	e2 := edge("edge2")
+	defer e2.Close()
+	waitServers("edge1 edge2")
+
+	c9, err := warp9.Mount("tcp", lf.Addr().String(), "", 8192, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c9.Unmount()
+	for _, name := range []string{"edge1", "edge2"} {
+		if data, _, err := c9.Get("/devices/"+name+"/id", 0); err != nil || string(data) != name {
+			t.Errorf("%s: %q, %v", name, data, err)
+		}
+	}
+	dirs, err := c9.ReadDir("/devices")
+	if err != nil || len(dirs) != 2 {
+		t.Errorf("listing: %v, %v", dirs, err)
+	}
+
+	// a server that disconnects leaves the registry
+	e1.Close()
+	waitServers("edge2")
+	if _, err := c9.Stat("/devices/edge1"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("departed server: %v", err)
+	}
+	if data, _, err := c9.Get("/devices/edge2/../edge2/id", 0); err != nil || string(data) != "edge2" {
+		t.Errorf("remaining server: %q, %v", data, err)
+	}
+}
+
+type connectorServer struct {
+	*ServerController
+	sync.Mutex
+	opened, failed int
+}
+
+func (s *connectorServer) ConnOpened(conn *warp9.Conn) {
+	s.Lock()
+	s.opened++
+	s.Unlock()
+}
+
+func (s *connectorServer) ConnectFailed(c *warp9.Connector, err error, wait time.Duration) {
+	s.Lock()
+	s.failed++
+	s.Unlock()
+}
+
+func (s *connectorServer) counts() (int, int) {
+	s.Lock()
+	defer s.Unlock()
+	return s.opened, s.failed
+}
+
+func TestConnector(t *testing.T) {
+	// an address with nothing listening yet
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	root := NewDirItem("edge")
+	s := &connectorServer{ServerController: NewServer("edge", tracelevel, root)}
+	if !s.Start(s) {
+		t.Fatal("unable to start server")
+	}
+	c := s.NewConnector("tcp", addr)
+	c.MinBackoff = 10 * time.Millisecond
+	c.MaxBackoff = 50 * time.Millisecond
+	c.Start()
+	defer c.Stop()
+
+	waitFor(t, "failed dials", func() bool { _, failed := s.counts(); return failed >= 2 })
+	if state, err := c.State(); state == warp9.ConnectorConnected || err == nil {
+		t.Errorf("state with no listener: %v, %v", state, err)
+	}
+
+	reg := NewRegistry("devices", "", 0, warp9.Identity.User(1))
+	defer reg.Close()
+	l, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go reg.Serve(l)
+	waitFor(t, "first connection", func() bool { return reg.Lookup("edge") != nil })
+	if state, _ := c.State(); state != warp9.ConnectorConnected {
+		t.Errorf("state: %v", state)
+	}
+
+	// the connector dials again when the connection is lost
+	reg.Lookup("edge").Unmount()
+	waitFor(t, "reconnection", func() bool {
+		opened, _ := s.counts()
+		return opened >= 2 && reg.Lookup("edge") != nil
+	})
+
+	c.Stop()
+	if state, _ := c.State(); state != warp9.ConnectorStopped {
+		t.Errorf("state after Stop: %v", state)
+	}
+	waitFor(t, "disconnection", func() bool { return reg.Lookup("edge") == nil })
+}
+
+func TestClientNamespace(t *testing.T) {
+	user := warp9.Identity.User(1)
+	serve := func(id string, files map[string]string) (net.Listener, string) {
+		host := t.TempDir()
+		for name, data := range files {
+			p := filepath.Join(host, name)
+			if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		export, err := NewExportDir("/", host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return listenServer(t, id, export), host
+	}
+	la, _ := serve("a", map[string]string{"conf": "a conf", "info/a.txt": "a from a"})
+	defer la.Close()
+	lb, hostb := serve("b", map[string]string{"info/a.txt": "a from b", "info/b.txt": "b from b"})
+	defer lb.Close()
+
+	ns := warp9.NewNamespace(user, 8192)
+	defer ns.Close()
+	if err := ns.MountAddr("tcp", la.Addr().String(), "", "/svc/a", warp9.MREPL); err != nil {
+		t.Fatal(err)
+	}
+	if err := ns.MountAddr("tcp", lb.Addr().String(), "", "/svc/b", warp9.MREPL); err != nil {
+		t.Fatal(err)
+	}
+	get := func(p string) string {
+		obj, err := ns.Open(p, warp9.OREAD)
+		if err != nil {
+			return err.Error()
+		}
+		defer obj.Close()
+		buf := make([]byte, 64)
+		n, err := obj.Read(buf)
+		if err != nil {
+			return err.Error()
+		}
+		return string(buf[:n])
+	}
+	names := func(p string) string {
+		dirs, err := ns.ReadDir(p)
+		if err != nil {
+			return err.Error()
+		}
+		var s []string
+		for _, d := range dirs {
+			s = append(s, d.Name)
+		}
+		sort.Strings(s)
+		return strings.Join(s, " ")
+	}
+
+	// directories above the mount points lead to them
+	if s := names("/"); s != "svc" {
+		t.Errorf("root: %q", s)
+	}
+	if s := names("/svc"); s != "a b" {
+		t.Errorf("/svc: %q", s)
+	}
+	if d, err := ns.Stat("/svc/a"); err != nil || d.Name != "a" || d.Qid.Type&warp9.QTDIR == 0 {
+		t.Errorf("mount point stat: %v, %v", d, err)
+	}
+	if s := get("/svc/a/conf"); s != "a conf" {
+		t.Errorf("conf: %q", s)
+	}
+	if fid, err := ns.Walk("/svc/b/info/b.txt"); err != nil {
+		t.Error(err)
+	} else {
+		fid.Clnt.Clunk(fid)
+	}
+	if _, err := ns.Stat("/svc/c"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing: %v", err)
+	}
+
+	// a union: the first member holding a name provides it
+	if err := ns.Bind("/svc/a/info", "/info", warp9.MREPL); err != nil {
+		t.Fatal(err)
+	}
+	if err := ns.Bind("/svc/b/info", "/info", warp9.MAFTER|warp9.MCREATE); err != nil {
+		t.Fatal(err)
+	}
+	if s := names("/info"); s != "a.txt b.txt" {
+		t.Errorf("union: %q", s)
+	}
+	if s := get("/info/a.txt"); s != "a from a" {
+		t.Errorf("union a.txt: %q", s)
+	}
+	if s := get("/info/b.txt"); s != "b from b" {
+		t.Errorf("union b.txt: %q", s)
+	}
+	obj, err := ns.Create("/info/new", 0644, warp9.OWRITE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj.Close()
+	if _, err := os.Stat(filepath.Join(hostb, "info", "new")); err != nil {
+		t.Errorf("created in the MCREATE member: %v", err)
+	}
+	if err := ns.Bind("/svc/b/info", "/info", warp9.MBEFORE); err != nil {
+		t.Fatal(err)
+	}
+	if s := get("/info/a.txt"); s != "a from b" {
+		t.Errorf("union after MBEFORE: %q", s)
+	}
+
+	if err := ns.Unmount("/info"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ns.Stat("/info"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("after unmount: %v", err)
+	}
+	if err := ns.Unmount("/info"); err == nil {
+		t.Error("second unmount succeeded")
+	}
+	if err := ns.Unmount("/svc/b"); err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.Join(ns.Mounts(), " "); s != "/svc/a" {
+		t.Errorf("mounts: %q", s)
+	}
+
+	// the same layout from a namespace description
+	ns2 := warp9.NewNamespace(user, 8192)
+	defer ns2.Close()
+	err = ns2.Load(strings.NewReader("mount " + la.Addr().String() + " /svc/a\nbind /svc/a/info /info\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := ns2.Stat("/info/a.txt"); err != nil || d.Name != "a.txt" {
+		t.Errorf("loaded namespace: %v, %v", d, err)
+	}
+	if err := ns2.Load(strings.NewReader("bind /nowhere /x")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("bind of a missing path: %v", err)
+	}
+}
+
+// a client cache in front of a live server: lookups are served from it
+// and changes made through the client drop what they affect
+func TestClientCache(t *testing.T) {
+	host := t.TempDir()
+	for _, name := range []string{"f", "g"} {
+		if err := os.WriteFile(filepath.Join(host, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	export, err := NewExportDir("/", host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := listenServer(t, "cached", export)
+	defer l.Close()
+	c9, err := warp9.Mount("tcp", l.Addr().String(), "", 8192, warp9.Identity.User(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c9.Unmount()
+	c := warp9.NewCache(time.Hour, 1024)
+	c9.SetCache(c)
+
+	// the second lookup of each kind is a hit
+	hits := func() uint64 { return c.Stats().Hits }
+	names := func() string {
+		dirs, err := c9.ReadDir("/")
+		if err != nil {
+			return err.Error()
+		}
+		var s []string
+		for _, d := range dirs {
+			s = append(s, fmt.Sprintf("%s:%d", d.Name, d.Length))
+		}
+		sort.Strings(s)
+		return strings.Join(s, " ")
+	}
+	if s := names(); s != "f:1 g:1" {
+		t.Fatalf("listing: %q", s)
+	}
+	h := hits()
+	if s := names(); s != "f:1 g:1" || hits() == h {
+		t.Errorf("listing again: %q, %d hits", s, hits())
+	}
+	// the listing gave the stats of the entries
+	h = hits()
+	if d, err := c9.Stat("/f"); err != nil || d.Length != 1 || hits() == h {
+		t.Errorf("stat: %v, %v, %d hits", d, err, hits())
+	}
+	fid, err := c9.Walk("/f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c9.Clunk(fid)
+	h = hits()
+	if _, err := c9.Stat("/f"); err != nil || hits() == h {
+		t.Errorf("stat after a walk: %v, %d hits", err, hits())
+	}
+
+	// a write drops the object and its directory
+	obj, err := c9.Open("/f", warp9.OWRITE|warp9.OTRUNC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := obj.Write([]byte("four")); err != nil {
+		t.Fatal(err)
+	}
+	obj.Close()
+	if d, err := c9.Stat("/f"); err != nil || d.Length != 4 {
+		t.Errorf("stat after a write: %v, %v", d, err)
+	}
+	if s := names(); s != "f:4 g:1" {
+		t.Errorf("listing after a write: %q", s)
+	}
+
+	// and so does a remove
+	if err := c9.Remove("/g"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c9.Stat("/g"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("stat after a remove: %v", err)
+	}
+	if s := names(); s != "f:4" {
+		t.Errorf("listing after a remove: %q", s)
+	}
+}
+
+// TestCloneWalk clones the fid of an object that is not a directory.
+func TestCloneWalk(t *testing.T) {
+	item := NewItem("clone")
+	item.SetBuffer([]byte("cloned"))
+	getRoot().AddItem(item)
+
+	c9, err := mountServer()
+	if err != nil {
+		t.Fatalf("mount failed: %v", err)
+	}
+	defer c9.Unmount()
+
+	fid, err := c9.Walk("/clone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c9.Clunk(fid)
+	newfid := c9.FidAlloc()
+	if _, err := c9.FWalk(fid, newfid, nil); err != nil {
+		t.Fatalf("clone: %v", err)
+	}
+	defer c9.Clunk(newfid)
+	if err := c9.FOpen(newfid, warp9.OREAD); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := c9.Read(newfid, 0, 100); err != nil || string(data) != "cloned" {
+		t.Errorf("read: %q, %v", data, err)
+	}
+}